@@ -0,0 +1,8 @@
+package orderv1
+
+import _ "embed"
+
+// OrderJSONSchema - JSON Schema сообщения с заказом (Kafka, POST /orders), версия 1
+//
+//go:embed order.schema.json
+var OrderJSONSchema []byte
@@ -0,0 +1,8 @@
+package orderv2
+
+import _ "embed"
+
+// OrderJSONSchema - JSON Schema сообщения с заказом (Kafka, POST /orders), версия 2
+//
+//go:embed order.schema.json
+var OrderJSONSchema []byte
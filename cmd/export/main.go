@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/folivorra/get_order/internal/adapter/export"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/encryption"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/storage"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	format := flag.String("format", export.FormatCSV, "output format: csv, jsonl or parquet")
+	out := flag.String("out", "", "output file, stdout if empty")
+	dateFrom := flag.String("date-from", "", "date_created lower bound, RFC3339 (inclusive)")
+	dateTo := flag.String("date-to", "", "date_created upper bound, RFC3339 (exclusive)")
+	deliveryService := flag.String("delivery-service", "", "filter by delivery_service")
+	region := flag.String("region", "", "filter by delivery region")
+	currency := flag.String("currency", "", "filter by payment currency")
+	phone := flag.String("phone", "", "filter by recipient phone")
+	email := flag.String("email", "", "filter by recipient email")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// логи в stderr, чтобы не смешиваться с выгрузкой в stdout
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	cfg := config.NewConfig(logger)
+
+	filter, err := mapper.ConvertFilterToDomain(&mapper.OrderFilterDTO{
+		DateFrom:        *dateFrom,
+		DateTo:          *dateTo,
+		DeliveryService: *deliveryService,
+		Region:          *region,
+		Currency:        *currency,
+		Phone:           *phone,
+		Email:           *email,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			_ = w.Close()
+		}()
+	}
+
+	keyring, err := encryption.NewKeyringFromConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pgClient := storage.NewPgClient(ctx, cfg)
+	defer func() {
+		_ = pgClient.Close()
+	}()
+	pgRepo := postgres.NewPgOrderRepo(logger, pgClient, cfg, keyring)
+
+	count, err := export.Export(ctx, pgRepo.Stream, filter, *format, w, nil)
+	if err != nil {
+		logger.Error("failed to export orders",
+			slog.Int("exported", count),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	logger.Info("orders exported",
+		slog.String("format", *format),
+		slog.Int("exported", count),
+	)
+}
@@ -2,16 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"github.com/brianvoe/gofakeit/v7"
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
 	"github.com/folivorra/get_order/internal/adapter/cache/inmemory"
 	"github.com/folivorra/get_order/internal/adapter/consumer/kafka"
+	"github.com/folivorra/get_order/internal/adapter/controller/graphql"
+	"github.com/folivorra/get_order/internal/adapter/controller/grpc"
 	"github.com/folivorra/get_order/internal/adapter/controller/rest"
+	"github.com/folivorra/get_order/internal/adapter/masking"
 	"github.com/folivorra/get_order/internal/adapter/middleware"
+	pubsub "github.com/folivorra/get_order/internal/adapter/pubsub/inmemory"
+	"github.com/folivorra/get_order/internal/adapter/schemaregistry"
 	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/encryption"
 	"github.com/folivorra/get_order/internal/repository/postgres"
 	"github.com/folivorra/get_order/internal/storage"
 	"github.com/folivorra/get_order/internal/usecase"
 	"github.com/gorilla/mux"
+	googlegrpc "google.golang.org/grpc"
 	"log/slog"
 	"net/http"
 	"os"
@@ -28,30 +37,93 @@ func main() {
 	_ = gofakeit.Seed(0)
 
 	// logger
-	logger := slog.New(
-		slog.NewTextHandler(
-			os.Stdout, &slog.HandlerOptions{
-				Level:     slog.LevelDebug,
-				AddSource: true,
-			},
-		),
-	)
+	logOpts := &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}
+	logger := slog.New(middleware.NewContextHandler(slog.NewTextHandler(os.Stdout, logOpts)))
 
 	// config
 	cfg := config.NewConfig(logger)
 
+	// pii masking | logs + api responses
+	maskPolicy, err := masking.ParsePolicy(cfg.MaskPolicy)
+	if err != nil {
+		logger.Error("failed to parse masking policy",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	revealRole, err := middleware.ParseRole(cfg.MaskRevealRole)
+	if err != nil {
+		logger.Error("failed to parse masking reveal role",
+			slog.String("role", cfg.MaskRevealRole),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	masker := masking.NewMasker(maskPolicy, revealRole)
+
+	if cfg.MaskLogs {
+		logOpts.ReplaceAttr = maskPolicy.ReplaceAttr
+		logger = slog.New(middleware.NewContextHandler(slog.NewTextHandler(os.Stdout, logOpts)))
+	}
+
+	// encryption | delivery pii at rest
+	keyring, err := encryption.NewKeyringFromConfig(cfg)
+	if err != nil {
+		logger.Error("failed to load encryption keys",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	if keyring == nil {
+		logger.Warn("delivery pii encryption is disabled")
+	}
+
 	// postgres | repo
 	pgClient := storage.NewPgClient(ctx, cfg)
 	defer func() {
 		_ = pgClient.Close()
 	}()
-	pgRepo := postgres.NewPgOrderRepo(pgClient, cfg)
+	pgRepo := postgres.NewPgOrderRepo(logger, pgClient, cfg, keyring)
 
 	// inmemory | cache
 	inMemCache := inmemory.NewInMemOrderCache(logger, cfg.CacheCapacity)
 
+	// inmemory | new orders feed
+	orderHub := pubsub.NewInMemOrderHub(logger, cfg.FeedSubscriberBuffer)
+
+	// service layer | validation rules
+	validator, err := usecase.NewOrderValidatorFromConfig(cfg)
+	if err != nil {
+		logger.Error("failed to load validation rules",
+			slog.String("file", cfg.ValidationRulesFile),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	// service layer | reporting currency
+	converter, err := usecase.NewCurrencyConverterFromConfig(cfg)
+	if err != nil {
+		logger.Error("failed to load exchange rates",
+			slog.String("file", cfg.MoneyRatesFile),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
 	// service layer
-	service := usecase.NewOrderService(logger, cfg, pgRepo, inMemCache)
+	service := usecase.NewOrderService(logger, cfg, pgRepo, inMemCache, orderHub, validator, converter)
+	idempotencyRepo := postgres.NewPgIdempotencyRepo(logger, pgClient, cfg)
+	submitter := usecase.NewOrderSubmitter(logger, service, idempotencyRepo)
+	quarantineRepo := postgres.NewPgQuarantineRepo(logger, pgClient, cfg, keyring)
+	quarantine := usecase.NewQuarantineService(logger, quarantineRepo, service)
+	itemRepo := postgres.NewPgItemRepo(logger, pgClient, cfg)
+	items := usecase.NewItemService(logger, itemRepo, service)
+	statsRepo := postgres.NewPgStatsRepo(logger, pgClient, cfg)
+	stats := usecase.NewStatsService(logger, cfg, statsRepo, converter)
 
 	// warmup cache
 	if err := service.WarmUpCache(ctx, cfg.CacheWarmUpSize); err != nil {
@@ -60,9 +132,23 @@ func main() {
 		)
 	}
 
+	// stats | rollup refresh
+	if cfg.StatsRollupEnabled {
+		go stats.RunRollup(ctx)
+	}
+
+	// kafka | schema registry for Avro and Protobuf messages
+	registry, err := schemaregistry.NewRegistryFromConfig(cfg)
+	if err != nil {
+		logger.Error("failed to init schema registry",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
 	// kafka
 	kafkaReader := kafka.NewReader(cfg)
-	kafkaConsumer := kafka.NewConsumer(logger, cfg, kafkaReader, service)
+	kafkaConsumer := kafka.NewConsumer(logger, cfg, kafkaReader, service, quarantine, kafka.NewWireDecoder(registry))
 	go kafkaConsumer.Start(ctx)
 	defer func() {
 		_ = kafkaReader.Close()
@@ -72,27 +158,126 @@ func main() {
 	router := mux.NewRouter()
 	router.Use(middleware.LoggingMiddleware(logger))
 
+	// grpc | interceptors
+	unaryInterceptors := []googlegrpc.UnaryServerInterceptor{middleware.LoggingUnaryInterceptor(logger)}
+	streamInterceptors := []googlegrpc.StreamServerInterceptor{middleware.LoggingStreamInterceptor(logger)}
+
+	// http, grpc | auth
+	var auth *middleware.Auth
+	if cfg.AuthEnabled {
+		authenticators, err := newAuthenticators(cfg)
+		if err != nil {
+			logger.Error("failed to configure auth",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		auth = middleware.NewAuth(logger, middleware.RoutePolicy{
+			"/templates/":                  middleware.RoleAnonymous,
+			"GET /schemas/order/{version}": middleware.RoleAnonymous,
+			"GET /order/{uid}":             middleware.RoleViewer,
+			"GET /orders/stream":           middleware.RoleViewer,
+			"GET /items/{nm_id}":           middleware.RoleViewer,
+			"GET /items/{nm_id}/orders":    middleware.RoleViewer,
+			"POST /graphql":                middleware.RoleViewer,
+			"POST /orders":                 middleware.RoleSupport,
+			"GET /orders/export":           middleware.RoleSupport,
+			"GET /stats/orders":            middleware.RoleSupport,
+			"POST /orders/import":          middleware.RoleAdmin,
+			"/quarantine":                  middleware.RoleSupport,
+			"/quarantine/{id}":             middleware.RoleSupport,
+			"/quarantine/{id}/approve":     middleware.RoleSupport,
+			"/quarantine/{id}/reject":      middleware.RoleSupport,
+		}, authenticators...)
+
+		grpcAuth := middleware.NewGRPCAuth(logger, middleware.MethodPolicy{
+			orderv1.OrderService_GetOrder_FullMethodName:        middleware.RoleViewer,
+			orderv1.OrderService_ListOrders_FullMethodName:      middleware.RoleViewer,
+			orderv1.OrderService_StreamNewOrders_FullMethodName: middleware.RoleViewer,
+			orderv1.OrderService_SubmitOrder_FullMethodName:     middleware.RoleSupport,
+		}, authenticators...)
+		unaryInterceptors = append(unaryInterceptors, grpcAuth.UnaryInterceptor())
+		streamInterceptors = append(streamInterceptors, grpcAuth.StreamInterceptor())
+	} else {
+		logger.Warn("http and grpc auth is disabled")
+	}
+
+	// http | rate limit - до аутентификации, чтобы перебор учетных данных тоже ограничивался
+	if cfg.RateLimitEnabled {
+		rateLimiter, err := newRateLimiter(cfg, auth, logger)
+		if err != nil {
+			logger.Error("failed to configure rate limit",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		router.Use(rateLimiter.Middleware)
+	}
+
+	// http | auth
+	if auth != nil {
+		router.Use(auth.Middleware)
+	}
+
+	// http | per-route timeouts
+	routeTimeouts, err := middleware.ParseRouteTimeouts(cfg.ServerHTTPTimeoutRoutes)
+	if err != nil {
+		logger.Error("failed to configure http timeouts",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	router.Use(middleware.TimeoutMiddleware(cfg.ServerHTTPTimeoutDefault, routeTimeouts))
+
+	// http | request id, recovery, security headers, cors, compression - для всех запросов, включая 404
+	httpMiddleware, err := newHTTPMiddleware(cfg, logger)
+	if err != nil {
+		logger.Error("failed to configure http middleware",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
 	// html ui
 	fs := http.FileServer(http.Dir("/templates"))
 	router.PathPrefix("/templates/").Handler(http.StripPrefix("/templates/", fs))
 
 	// http | controller
-	controller := rest.NewController(service, cfg, logger)
+	controller := rest.NewController(service, submitter, masker, cfg, logger)
 	controller.RegisterRoutes(router)
 
+	// http | quarantine review
+	quarantineController := rest.NewQuarantineController(quarantine, cfg, logger)
+	quarantineController.RegisterRoutes(router)
+
+	// http | item catalog
+	itemController := rest.NewItemController(items, masker, cfg, logger)
+	itemController.RegisterRoutes(router)
+
+	// http | order stats
+	statsController := rest.NewStatsController(stats, cfg, logger)
+	statsController.RegisterRoutes(router)
+
+	// http | new orders feed (sse, websocket)
+	feedController := rest.NewFeedController(orderHub, masker, cfg, logger)
+	feedController.RegisterRoutes(router)
+
+	// http | graphql
+	graphqlHandler := graphql.NewHandler(service, masker, cfg, logger)
+	graphqlHandler.RegisterRoutes(router)
+
 	// http | server
-	server := rest.NewServer(
-		&http.Server{
-			Addr:              ":" + cfg.ServerHTTPPort,
-			Handler:           router,
-			ReadHeaderTimeout: cfg.ServerHTTPReadHeaderTimeout,
-			ReadTimeout:       cfg.ServerHTTPReadTimeout,
-			WriteTimeout:      cfg.ServerHTTPWriteTimeout,
-			IdleTimeout:       cfg.ServerHTTPIdleTimeout,
-		},
-		cfg,
-		logger,
-	)
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.ServerHTTPPort,
+		Handler:           httpMiddleware(router),
+		ReadHeaderTimeout: cfg.ServerHTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.ServerHTTPReadTimeout,
+		WriteTimeout:      cfg.ServerHTTPWriteTimeout,
+		IdleTimeout:       cfg.ServerHTTPIdleTimeout,
+	}
+	httpServer.RegisterOnShutdown(feedController.Close)
+
+	server := rest.NewServer(httpServer, cfg, logger)
 
 	go func() {
 		if err := server.Run(); err != nil {
@@ -104,9 +289,135 @@ func main() {
 	}()
 	defer server.Stop(ctx)
 
+	// grpc | handler + server
+	grpcServer := grpc.NewServer(
+		googlegrpc.NewServer(
+			googlegrpc.ChainUnaryInterceptor(unaryInterceptors...),
+			googlegrpc.ChainStreamInterceptor(streamInterceptors...),
+		),
+		cfg,
+		logger,
+	)
+	orderv1.RegisterOrderServiceServer(
+		grpcServer.Registrar(),
+		grpc.NewOrderHandler(service, submitter, orderHub, masker, cfg, logger),
+	)
+
+	go func() {
+		if err := grpcServer.Run(); err != nil {
+			logger.Error("failed to start grpc server",
+				slog.String("port", cfg.GRPCPort),
+				slog.String("err", err.Error()),
+			)
+		}
+	}()
+	defer grpcServer.Stop(ctx)
+
 	// graceful shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 	<-shutdown
 	cancel()
 }
+
+func newAuthenticators(cfg config.Config) ([]middleware.Authenticator, error) {
+	var authenticators []middleware.Authenticator
+
+	if len(cfg.AuthAPIKeys) > 0 {
+		apiKeys, err := middleware.NewAPIKeyAuthenticator(cfg.AuthAPIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, apiKeys)
+	}
+
+	if cfg.AuthJWTHMACSecret != "" || cfg.AuthJWKSFile != "" {
+		jwtAuth, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{
+			HMACSecret: cfg.AuthJWTHMACSecret,
+			JWKSFile:   cfg.AuthJWKSFile,
+			Issuer:     cfg.AuthJWTIssuer,
+			Audience:   cfg.AuthJWTAudience,
+			RoleClaim:  cfg.AuthJWTRoleClaim,
+			Leeway:     cfg.AuthJWTLeeway,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuth)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("AUTH_ENABLED=true requires AUTH_API_KEYS, AUTH_JWT_HMAC_SECRET or AUTH_JWKS_FILE")
+	}
+
+	return authenticators, nil
+}
+
+// newHTTPMiddleware собирает middleware, которые оборачивают весь роутер: они не зависят от маршрута
+// и должны срабатывать и для запросов, не совпавших ни с одним маршрутом (404, preflight CORS)
+func newHTTPMiddleware(cfg config.Config, logger *slog.Logger) (func(http.Handler) http.Handler, error) {
+	stack := []func(http.Handler) http.Handler{middleware.RequestIDMiddleware()}
+
+	if cfg.ServerHTTPRecoveryEnabled {
+		stack = append(stack, middleware.RecoveryMiddleware(logger))
+	}
+
+	if cfg.ServerHTTPSecurityHeaders {
+		stack = append(stack, middleware.SecurityHeadersMiddleware(middleware.SecurityHeadersOptions{
+			ContentSecurityPolicy: cfg.ServerHTTPCSP,
+			HSTSMaxAge:            cfg.ServerHTTPHSTSMaxAge,
+		}))
+	}
+
+	if len(cfg.ServerHTTPCORSOrigins) > 0 {
+		stack = append(stack, middleware.CORSMiddleware(middleware.CORSOptions{
+			AllowedOrigins:   cfg.ServerHTTPCORSOrigins,
+			AllowedMethods:   cfg.ServerHTTPCORSMethods,
+			AllowedHeaders:   cfg.ServerHTTPCORSHeaders,
+			ExposedHeaders:   cfg.ServerHTTPCORSExposed,
+			AllowCredentials: cfg.ServerHTTPCORSCredentials,
+			MaxAge:           cfg.ServerHTTPCORSMaxAge,
+		}))
+	}
+
+	if cfg.ServerHTTPCompressEnabled {
+		compression, err := middleware.CompressionMiddleware(middleware.CompressionOptions{
+			Encodings: cfg.ServerHTTPCompressEncodings,
+			MinSize:   cfg.ServerHTTPCompressMinSize,
+			Types:     cfg.ServerHTTPCompressTypes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, compression)
+	}
+
+	return middleware.Chain(stack...), nil
+}
+
+func newRateLimiter(cfg config.Config, auth *middleware.Auth, logger *slog.Logger) (*middleware.RateLimiter, error) {
+	defaultLimit, err := middleware.ParseLimit(cfg.RateLimitDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := middleware.ParseRouteLimits(cfg.RateLimitRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := middleware.RateLimitOptions{
+		Default:           defaultLimit,
+		Routes:            routes,
+		TrustProxy:        cfg.RateLimitTrustProxy,
+		IdleTTL:           cfg.RateLimitIdleTTL,
+		NotFoundThreshold: cfg.RateLimitNotFoundThreshold,
+		NotFoundWindow:    cfg.RateLimitNotFoundWindow,
+		BlockDuration:     cfg.RateLimitBlockDuration,
+	}
+	if auth != nil {
+		opts.Identify = auth.Identify
+	}
+
+	return middleware.NewRateLimiter(logger, opts), nil
+}
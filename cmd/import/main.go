@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/folivorra/get_order/internal/adapter/cache/inmemory"
+	"github.com/folivorra/get_order/internal/adapter/importer"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/encryption"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/storage"
+	"github.com/folivorra/get_order/internal/usecase"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	file := flag.String("file", "", "input file, stdin if empty")
+	format := flag.String("format", "", "input format: jsonl or csv (by file extension if empty)")
+	mappingFlag := flag.String("mapping", "", "csv column mapping: field=column,field=column")
+	dryRun := flag.Bool("dry-run", false, "validate only, do not write to the database")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// логи в stderr, в stdout идет построчный отчет
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	cfg := config.NewConfig(logger)
+
+	mapping, err := importer.ParseMapping(*mappingFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in := os.Stdin
+	if *file != "" {
+		in, err = os.Open(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+
+		if *format == "" {
+			*format = strings.TrimPrefix(filepath.Ext(*file), ".")
+		}
+	}
+	if *format == "" {
+		*format = importer.FormatJSONL
+	}
+
+	reader, err := importer.NewReader(*format, in, mapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keyring, err := encryption.NewKeyringFromConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	validator, err := usecase.NewOrderValidatorFromConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pgClient := storage.NewPgClient(ctx, cfg)
+	defer func() {
+		_ = pgClient.Close()
+	}()
+	pgRepo := postgres.NewPgOrderRepo(logger, pgClient, cfg, keyring)
+	inMemCache := inmemory.NewInMemOrderCache(logger, cfg.CacheCapacity)
+	service := usecase.NewOrderService(logger, cfg, pgRepo, inMemCache, nil, validator, nil)
+
+	enc := json.NewEncoder(os.Stdout)
+	imp := importer.NewImporter(logger, service, cfg.ImportBatchSize)
+
+	summary, err := imp.Run(ctx, reader, *dryRun, func(result importer.Result) error {
+		return enc.Encode(result)
+	})
+	if err != nil {
+		logger.Error("failed to import orders",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	if summary.Rejected > 0 {
+		os.Exit(2)
+	}
+}
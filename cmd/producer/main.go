@@ -6,13 +6,16 @@ import (
 	"github.com/folivorra/get_order/internal/adapter/mapper"
 	"log"
 	"sync"
-	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 )
 
+// currencies - валюты заказов, для всех есть курс в rates/exchange_rates.yaml.
+// Суммы в минимальных единицах, у JPY дробной части нет
+var currencies = []string{"RUB", "USD", "EUR", "CNY", "KZT", "BYN", "JPY"}
+
 func main() {
 	_ = gofakeit.Seed(0)
 	ctx := context.Background()
@@ -51,13 +54,11 @@ func main() {
 				Payment: mapper.PaymentIntoDomainDTO{
 					Transaction:  uuid.New().String(),
 					RequestID:    gofakeit.DigitN(10),
-					Currency:     gofakeit.CurrencyShort(),
+					Currency:     gofakeit.RandomString(currencies),
 					Provider:     gofakeit.CreditCardType(),
-					Amount:       gofakeit.Number(1, 100),
-					PaymentDT:    gofakeit.Number(1, 100),
+					PaymentDT:    mapper.NewTimestamp(gofakeit.PastDate()),
 					Bank:         gofakeit.BankType(),
 					DeliveryCost: gofakeit.Number(1, 100),
-					GoodsTotal:   gofakeit.Number(1, 100),
 					CustomFee:    gofakeit.Number(1, 100),
 				},
 				Items: []mapper.ItemIntoDomainDTO{
@@ -65,12 +66,11 @@ func main() {
 						ItemUID:     uuid.New(),
 						ChrtID:      gofakeit.Number(1, 100),
 						TrackNumber: uuid.New().String(),
-						Price:       gofakeit.Number(1, 100),
+						Price:       gofakeit.Number(100, 10000),
 						Rid:         uuid.New().String(),
 						Name:        gofakeit.ProductName(),
-						Sale:        gofakeit.Number(1, 100),
+						Sale:        gofakeit.Number(0, 90),
 						Size:        gofakeit.DigitN(2),
-						TotalPrice:  gofakeit.Number(1, 100),
 						NmID:        gofakeit.Number(1, 100),
 						Brand:       gofakeit.Company(),
 						Status:      gofakeit.HTTPStatusCode(),
@@ -80,12 +80,11 @@ func main() {
 						ItemUID:     uuid.New(),
 						ChrtID:      gofakeit.Number(1, 100),
 						TrackNumber: uuid.New().String(),
-						Price:       gofakeit.Number(1, 100),
+						Price:       gofakeit.Number(100, 10000),
 						Rid:         uuid.New().String(),
 						Name:        gofakeit.ProductName(),
-						Sale:        gofakeit.Number(1, 100),
+						Sale:        gofakeit.Number(0, 90),
 						Size:        gofakeit.DigitN(2),
-						TotalPrice:  gofakeit.Number(1, 100),
 						NmID:        gofakeit.Number(1, 100),
 						Brand:       gofakeit.Company(),
 						Status:      gofakeit.HTTPStatusCode(),
@@ -95,12 +94,11 @@ func main() {
 						ItemUID:     uuid.New(),
 						ChrtID:      gofakeit.Number(1, 100),
 						TrackNumber: uuid.New().String(),
-						Price:       gofakeit.Number(1, 100),
+						Price:       gofakeit.Number(100, 10000),
 						Rid:         uuid.New().String(),
 						Name:        gofakeit.ProductName(),
-						Sale:        gofakeit.Number(1, 100),
+						Sale:        gofakeit.Number(0, 90),
 						Size:        gofakeit.DigitN(2),
-						TotalPrice:  gofakeit.Number(1, 100),
 						NmID:        gofakeit.Number(1, 100),
 						Brand:       gofakeit.Company(),
 						Status:      gofakeit.HTTPStatusCode(),
@@ -113,17 +111,27 @@ func main() {
 				DeliveryService:   gofakeit.LetterN(5),
 				Shardkey:          gofakeit.Digit(),
 				SmID:              gofakeit.Number(1, 100),
-				DateCreated:       gofakeit.PastDate().Format(time.RFC3339),
+				DateCreated:       mapper.NewTimestamp(gofakeit.PastDate()),
 				OofShard:          gofakeit.Digit(),
 			}
 
+			// суммы считаются из позиций, иначе консьюмер отправит заказ в карантин
+			for j := range order.Items {
+				item := &order.Items[j]
+				item.TotalPrice = item.Price * item.Quantity * (100 - item.Sale) / 100
+				order.Payment.GoodsTotal += item.TotalPrice
+			}
+			order.Payment.Amount = order.Payment.GoodsTotal + order.Payment.DeliveryCost + order.Payment.CustomFee
+
 			b, err := json.Marshal(order)
 			if err != nil {
 				log.Println("fail to marshal order")
 			}
 
+			// заказ в текущем формате: quantity указано у каждой позиции
 			err = writer.WriteMessages(ctx, kafka.Message{
-				Value: b,
+				Headers: []kafka.Header{{Key: "order-schema-version", Value: []byte("2")}},
+				Value:   b,
 			})
 			if err != nil {
 				log.Println("fail to write messages", err)
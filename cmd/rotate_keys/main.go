@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/encryption"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/storage"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	all := flag.Bool("all", false, "re-encrypt every row, not only ones under other keys or in plaintext")
+	batch := flag.Int("batch", 0, "rows per transaction, CRYPTO_ROTATE_BATCH_SIZE if 0")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	cfg := config.NewConfig(logger)
+	if *batch <= 0 {
+		*batch = cfg.CryptoRotateBatchSize
+	}
+
+	keyring, err := encryption.NewKeyringFromConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if keyring == nil {
+		log.Fatal("no encryption keys configured: set CRYPTO_KEYFILE or CRYPTO_KEYS")
+	}
+
+	pgClient := storage.NewPgClient(ctx, cfg)
+	defer func() {
+		_ = pgClient.Close()
+	}()
+	pgRepo := postgres.NewPgOrderRepo(logger, pgClient, cfg, keyring)
+
+	done, err := pgRepo.ReencryptDeliveries(ctx, *all, *batch, func(done int) {
+		logger.Info("batch re-encrypted",
+			slog.Int("done", done),
+		)
+	})
+	if err != nil {
+		logger.Error("failed to re-encrypt deliveries",
+			slog.Int("done", done),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	logger.Info("deliveries re-encrypted",
+		slog.String("key_id", keyring.ActiveKeyID()),
+		slog.Int("done", done),
+	)
+
+	quarantineRepo := postgres.NewPgQuarantineRepo(logger, pgClient, cfg, keyring)
+
+	done, err = quarantineRepo.ReencryptPayloads(ctx, *all, *batch, func(done int) {
+		logger.Info("quarantine batch re-encrypted",
+			slog.Int("done", done),
+		)
+	})
+	if err != nil {
+		logger.Error("failed to re-encrypt quarantined orders",
+			slog.Int("done", done),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	logger.Info("quarantined orders re-encrypted",
+		slog.String("key_id", keyring.ActiveKeyID()),
+		slog.Int("done", done),
+	)
+}
@@ -6,27 +6,37 @@ import (
 	"errors"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/schemaregistry"
 	"github.com/folivorra/get_order/internal/config"
 	"github.com/folivorra/get_order/internal/repository/postgres"
 	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 	"log/slog"
 	"time"
 )
 
+// SchemaVersionHeader - заголовок сообщения с версией формата заказа ("2" или "v2").
+// Без заголовка версия берется из конверта или VALIDATION_SCHEMA_DEFAULT_VERSION
+const SchemaVersionHeader = "order-schema-version"
+
 type Consumer struct {
-	logger *slog.Logger
-	cfg    config.Config
-	reader *kafka.Reader
-	srv    *usecase.OrderService
+	logger     *slog.Logger
+	cfg        config.Config
+	reader     *kafka.Reader
+	srv        *usecase.OrderService
+	quarantine *usecase.QuarantineService
+	wire       *WireDecoder
 }
 
-func NewConsumer(logger *slog.Logger, cfg config.Config, reader *kafka.Reader, srv *usecase.OrderService) *Consumer {
+func NewConsumer(logger *slog.Logger, cfg config.Config, reader *kafka.Reader, srv *usecase.OrderService, quarantine *usecase.QuarantineService, wire *WireDecoder) *Consumer {
 	return &Consumer{
-		logger: logger,
-		cfg:    cfg,
-		reader: reader,
-		srv:    srv,
+		logger:     logger,
+		cfg:        cfg,
+		reader:     reader,
+		srv:        srv,
+		quarantine: quarantine,
+		wire:       wire,
 	}
 }
 
@@ -70,25 +80,66 @@ func (c *Consumer) Start(ctx context.Context) {
 				continue
 			}
 
-			var orderDTO mapper.OrderIntoDomainDTO
+			payload := msg.Value
+			version, err := usecase.ParseOrderVersion(header(msg, SchemaVersionHeader))
+			if err != nil {
+				c.logger.Error("failed to parse schema version",
+					slog.String("error", err.Error()),
+				)
+				if err = c.toQuarantine(ctx, msg, payload, uuid.Nil, version, err); err != nil {
+					return
+				}
+				c.commit(ctx, msg)
+				continue
+			}
 
-			if err = json.Unmarshal(msg.Value, &orderDTO); err != nil {
-				c.logger.Error("failed to unmarshal message",
+			if IsWireFormat(payload) {
+				var wireVersion int
+				payload, wireVersion, err = c.decodeWire(ctx, msg)
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				if err != nil {
+					c.logger.Error("failed to decode wire format message",
+						slog.String("error", err.Error()),
+					)
+					if err = c.toQuarantine(ctx, msg, msg.Value, uuid.Nil, version, err); err != nil {
+						return
+					}
+					c.commit(ctx, msg)
+					continue
+				}
+				// версию бинарного сообщения определяет его схема, JSON Schema - заголовок или конверт
+				if wireVersion != 0 {
+					version = wireVersion
+				}
+			}
+
+			orderDTO, err := c.srv.DecodeOrder(payload, version)
+			if err != nil {
+				c.logger.Error("failed to decode message",
 					slog.String("error", err.Error()),
 				)
+				if err = c.toQuarantine(ctx, msg, payload, uuid.Nil, version, err); err != nil {
+					return
+				}
 				c.commit(ctx, msg)
 				continue
 			}
 
-			if err = usecase.ValidateOrder(&orderDTO); err != nil {
+			if err = c.srv.ValidateOrder(ctx, orderDTO); err != nil {
 				c.logger.Error("failed to validate order",
+					slog.String("uuid", orderDTO.OrderUID.String()),
 					slog.String("error", err.Error()),
 				)
+				if err = c.toQuarantine(ctx, msg, payload, orderDTO.OrderUID, version, err); err != nil {
+					return
+				}
 				c.commit(ctx, msg)
 				continue
 			}
 
-			order := mapper.ConvertToDomain(&orderDTO)
+			order := mapper.ConvertToDomain(orderDTO)
 			err = c.srv.ProcessIncomingOrder(ctx, order)
 
 			switch {
@@ -117,6 +168,75 @@ func (c *Consumer) Start(ctx context.Context) {
 	}
 }
 
+// decodeWire переводит сообщение в wire format в JSON, пока реестр схем недоступен - повторяет попытки
+func (c *Consumer) decodeWire(ctx context.Context, msg kafka.Message) ([]byte, int, error) {
+	for {
+		payload, version, err := c.wire.Decode(ctx, msg.Value)
+		if !errors.Is(err, schemaregistry.ErrRegistryUnavailable) {
+			return payload, version, err
+		}
+
+		c.logger.Warn("schema registry is unavailable, retrying",
+			slog.Int("partition", msg.Partition),
+			slog.Int64("offset", msg.Offset),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(c.cfg.KafkaBackoff):
+		}
+	}
+}
+
+// toQuarantine сохраняет отклоненное сообщение для ручного разбора. payload - исходное сообщение
+// или JSON, в который переведено бинарное, чтобы его можно было исправить и одобрить.
+// Пока запись не сохранена, сообщение нельзя коммитить, поэтому попытки повторяются до отмены ctx
+func (c *Consumer) toQuarantine(ctx context.Context, msg kafka.Message, payload []byte, orderUID uuid.UUID, version int, cause error) error {
+	source := usecase.QuarantineSource{
+		Source:        "kafka",
+		Topic:         msg.Topic,
+		Partition:     msg.Partition,
+		Offset:        msg.Offset,
+		SchemaVersion: version,
+	}
+
+	for {
+		entry, err := c.quarantine.Quarantine(ctx, source, orderUID, payload, cause)
+		if err == nil {
+			c.logger.Warn("order quarantined",
+				slog.String("id", entry.ID.String()),
+				slog.String("uuid", orderUID.String()),
+				slog.String("reason", entry.Reason),
+			)
+			return nil
+		}
+
+		c.logger.Error("failed to quarantine message, retrying",
+			slog.Int("partition", msg.Partition),
+			slog.Int64("offset", msg.Offset),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.cfg.KafkaBackoff):
+		}
+	}
+}
+
+// header - значение заголовка сообщения, пустая строка если его нет
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
 func (c *Consumer) commit(ctx context.Context, msg kafka.Message) {
 	if err := c.reader.CommitMessages(ctx, msg); err != nil {
 		c.logger.Error("failed to commit message",
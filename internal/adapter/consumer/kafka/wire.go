@@ -0,0 +1,239 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/schemaregistry"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Confluent wire format: магический байт 0, id схемы (4 байта big endian), затем данные.
+// У Protobuf перед данными идут индексы сообщения в файле схемы
+const (
+	wireMagicByte  = 0
+	wireHeaderSize = 5
+)
+
+var (
+	protoPackage = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	protoComment = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	protoMessage = regexp.MustCompile(`\bmessage\s+(\w+)\s*\{`)
+)
+
+// IsWireFormat - сообщение в Confluent wire format, JSON не может начинаться с байта 0
+func IsWireFormat(data []byte) bool {
+	return len(data) >= wireHeaderSize && data[0] == wireMagicByte
+}
+
+// WireDecoder переводит сообщения в Confluent wire format (Avro, Protobuf, JSON Schema) в JSON заказа,
+// схемы берутся из реестра по id
+type WireDecoder struct {
+	registry schemaregistry.Registry
+
+	mu    sync.RWMutex
+	avros map[int]avro.Schema
+}
+
+// NewWireDecoder - registry nil: реестр не настроен, любое сообщение в wire format отклоняется
+func NewWireDecoder(registry schemaregistry.Registry) *WireDecoder {
+	return &WireDecoder{
+		registry: registry,
+		avros:    make(map[int]avro.Schema),
+	}
+}
+
+// Decode возвращает JSON заказа и версию его формата для OrderService.DecodeOrder: Avro-контракт
+// api/order/v2/order.avsc - текущая версия, Protobuf - usecase.ProtoOrderSchema, как в gRPC SubmitOrder,
+// JSON Schema - версия из заголовка или конверта (0). Ошибки разбора оборачивают usecase.ErrOrderEncoding,
+// недоступность реестра - schemaregistry.ErrRegistryUnavailable, такое сообщение можно разобрать повторно
+func (d *WireDecoder) Decode(ctx context.Context, data []byte) ([]byte, int, error) {
+	if !IsWireFormat(data) {
+		return nil, 0, fmt.Errorf("%w: not a wire format message", usecase.ErrOrderEncoding)
+	}
+	if d.registry == nil {
+		return nil, 0, fmt.Errorf("%w: schema registry is not configured", usecase.ErrOrderEncoding)
+	}
+
+	id := int(binary.BigEndian.Uint32(data[1:wireHeaderSize]))
+	schema, err := d.registry.SchemaByID(ctx, id)
+	if errors.Is(err, schemaregistry.ErrRegistryUnavailable) {
+		return nil, 0, err
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", usecase.ErrOrderEncoding, err)
+	}
+
+	payload := data[wireHeaderSize:]
+
+	var (
+		order   []byte
+		version int
+	)
+	switch schema.Type {
+	case schemaregistry.SchemaAvro:
+		order, err = d.decodeAvro(schema, payload)
+		version = usecase.CurrentOrderSchema
+	case schemaregistry.SchemaProtobuf:
+		order, err = decodeProtobuf(schema, payload)
+		version = usecase.ProtoOrderSchema
+	case schemaregistry.SchemaJSON:
+		order = payload
+	default:
+		err = fmt.Errorf("unsupported schema type %s", schema.Type)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: schema %d: %w", usecase.ErrOrderEncoding, id, err)
+	}
+
+	return order, version, nil
+}
+
+func (d *WireDecoder) decodeAvro(schema *schemaregistry.Schema, payload []byte) ([]byte, error) {
+	d.mu.RLock()
+	parsed, ok := d.avros[schema.ID]
+	d.mu.RUnlock()
+
+	if !ok {
+		var err error
+		// свой кэш: разные версии схемы с одним именем записи не должны пересекаться
+		if parsed, err = avro.ParseWithCache(schema.Schema, "", &avro.SchemaCache{}); err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		d.avros[schema.ID] = parsed
+		d.mu.Unlock()
+	}
+
+	var doc any
+	if err := avro.Unmarshal(parsed, payload, &doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(normalizeAvro(doc))
+}
+
+// normalizeAvro - null в nullable-полях означает отсутствие поля, пустой массив Avro декодируется в nil
+func normalizeAvro(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if field == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = normalizeAvro(field)
+		}
+		return v
+	case []any:
+		if v == nil {
+			return []any{}
+		}
+		for i := range v {
+			v[i] = normalizeAvro(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// decodeProtobuf находит сообщение по индексам из заголовка в схеме реестра и разбирает его
+// сгенерированным типом. Поддерживается order.v1.Order из api/order/v1/order.proto
+func decodeProtobuf(schema *schemaregistry.Schema, payload []byte) ([]byte, error) {
+	indexes, payload, err := readMessageIndexes(payload)
+	if err != nil {
+		return nil, err
+	}
+	name, err := protoMessageName(schema.Schema, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("message %s: %w", name, err)
+	}
+	message := messageType.New().Interface()
+	if err = proto.Unmarshal(payload, message); err != nil {
+		return nil, err
+	}
+
+	order, ok := message.(*orderv1.Order)
+	if !ok {
+		return nil, fmt.Errorf("message %s is not an order", name)
+	}
+
+	return json.Marshal(mapper.ConvertProtoToDTO(order))
+}
+
+// readMessageIndexes читает индексы сообщения: количество и индексы в zigzag varint,
+// один байт 0 - первое сообщение файла
+func readMessageIndexes(payload []byte) ([]int, []byte, error) {
+	reader := bytes.NewReader(payload)
+
+	count, err := binary.ReadVarint(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read message indexes: %w", err)
+	}
+	if count == 0 {
+		return []int{0}, payload[len(payload)-reader.Len():], nil
+	}
+	if count < 0 || count > int64(reader.Len()) {
+		return nil, nil, fmt.Errorf("read message indexes: invalid count %d", count)
+	}
+
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, err := binary.ReadVarint(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read message indexes: %w", err)
+		}
+		indexes[i] = int(index)
+	}
+
+	return indexes, payload[len(payload)-reader.Len():], nil
+}
+
+// protoMessageName - полное имя сообщения верхнего уровня по индексу в тексте .proto.
+// Вложенные сообщения не поддерживаются
+func protoMessageName(schema string, indexes []int) (protoreflect.FullName, error) {
+	if len(indexes) != 1 {
+		return "", fmt.Errorf("nested message indexes %v are not supported", indexes)
+	}
+
+	schema = protoComment.ReplaceAllString(schema, "")
+
+	var names []string
+	for _, match := range protoMessage.FindAllStringSubmatchIndex(schema, -1) {
+		// сообщение верхнего уровня - перед ним все фигурные скобки закрыты
+		prefix := schema[:match[0]]
+		if strings.Count(prefix, "{") == strings.Count(prefix, "}") {
+			names = append(names, schema[match[2]:match[3]])
+		}
+	}
+
+	index := indexes[0]
+	if index < 0 || index >= len(names) {
+		return "", fmt.Errorf("message index %d is out of range, schema has %d messages", index, len(names))
+	}
+
+	name := names[index]
+	if pkg := protoPackage.FindStringSubmatch(schema); pkg != nil {
+		name = pkg[1] + "." + name
+	}
+
+	return protoreflect.FullName(name), nil
+}
@@ -0,0 +1,188 @@
+package kafka_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	orderv2 "github.com/folivorra/get_order/api/order/v2"
+	"github.com/folivorra/get_order/internal/adapter/consumer/kafka"
+	"github.com/folivorra/get_order/internal/adapter/schemaregistry"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// id схем в локальном реестре
+const (
+	avroSchemaID  = 1
+	protoSchemaID = 2
+	jsonSchemaID  = 3
+)
+
+// newLocalRegistry - файловый реестр с опубликованными контрактами из api/order
+func newLocalRegistry(t *testing.T) schemaregistry.Registry {
+	t.Helper()
+
+	dir := t.TempDir()
+	copyFile := func(src, name string) {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+	copyFile("../../../../api/order/v2/order.avsc", "1.avsc")
+	copyFile("../../../../api/order/v1/order.proto", "2.proto")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "3.json"), orderv2.OrderJSONSchema, 0o600))
+
+	registry, err := schemaregistry.NewFileRegistry(dir)
+	require.NoError(t, err)
+	return registry
+}
+
+func wireMessage(schemaID int, payload []byte) []byte {
+	msg := []byte{0}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(schemaID))
+	return append(msg, payload...)
+}
+
+func newOrderDecoder(t *testing.T) *usecase.OrderDecoder {
+	t.Helper()
+	decoder, err := usecase.NewOrderDecoder(false, usecase.OrderSchemaV1)
+	require.NoError(t, err)
+	return decoder
+}
+
+func avroOrder(orderUID uuid.UUID) map[string]any {
+	return map[string]any{
+		"order_uid":    orderUID.String(),
+		"track_number": "WBILMTESTTRACK",
+		"entry":        "WBIL",
+		"delivery": map[string]any{
+			"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+			"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com",
+		},
+		"payment": map[string]any{
+			"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+			"amount": int64(2134), "payment_dt": int64(1637907727), "bank": "alpha",
+			"delivery_cost": int64(1500), "goods_total": int64(634), "custom_fee": int64(0),
+		},
+		"items": []any{map[string]any{
+			"item_uid": nil, "chrt_id": int64(9934930), "track_number": "WBILMTESTTRACK", "price": int64(453),
+			"rid": "ab4219087a764ae0btest", "name": "Mascaras", "sale": 30, "size": "0", "total_price": int64(634),
+			"nm_id": int64(2389212), "brand": "Vivienne Sabo", "status": 202, "quantity": 2,
+		}},
+		"locale":             "en",
+		"internal_signature": "",
+		"customer_id":        "test",
+		"delivery_service":   "meest",
+		"shardkey":           "9",
+		"sm_id":              99,
+		"date_created":       "2021-11-26T06:22:19Z",
+		"oof_shard":          "1",
+	}
+}
+
+func TestWireDecoder_Avro(t *testing.T) {
+	registry := newLocalRegistry(t)
+	schema, err := registry.SchemaByID(context.Background(), avroSchemaID)
+	require.NoError(t, err)
+
+	orderUID := uuid.New()
+	payload, err := avro.Marshal(avro.MustParse(schema.Schema), avroOrder(orderUID))
+	require.NoError(t, err)
+
+	order, version, err := kafka.NewWireDecoder(registry).Decode(context.Background(), wireMessage(avroSchemaID, payload))
+	require.NoError(t, err)
+	assert.Equal(t, usecase.CurrentOrderSchema, version)
+
+	orderDTO, err := newOrderDecoder(t).Decode(order, version)
+	require.NoError(t, err)
+	assert.Equal(t, orderUID, orderDTO.OrderUID)
+	assert.Equal(t, 2134, orderDTO.Payment.Amount)
+	require.Len(t, orderDTO.Items, 1)
+	assert.Equal(t, uuid.Nil, orderDTO.Items[0].ItemUID)
+	assert.Equal(t, 2, orderDTO.Items[0].Quantity)
+	assert.Equal(t, "Vivienne Sabo", orderDTO.Items[0].Brand)
+}
+
+func TestWireDecoder_Protobuf(t *testing.T) {
+	orderUID := uuid.New()
+	payload, err := proto.Marshal(&orderv1.Order{
+		OrderUid:        orderUID.String(),
+		TrackNumber:     "WBILMTESTTRACK",
+		DeliveryService: "meest",
+		DateCreated:     "2021-11-26T06:22:19Z",
+		Payment:         &orderv1.Payment{Amount: 1817, GoodsTotal: 317, DeliveryCost: 1500, PaymentDt: 1637907727},
+		Items: []*orderv1.OrderItem{{
+			Item:       &orderv1.Item{NmId: 2389212, Name: "Mascaras"},
+			Price:      453,
+			Sale:       30,
+			TotalPrice: 317,
+		}},
+	})
+	require.NoError(t, err)
+
+	// Order - пятое сообщение в order.proto: количество индексов 1, индекс 4
+	indexes := binary.AppendVarint(binary.AppendVarint(nil, 1), 4)
+	msg := wireMessage(protoSchemaID, append(indexes, payload...))
+
+	order, version, err := kafka.NewWireDecoder(newLocalRegistry(t)).Decode(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, usecase.OrderSchemaV1, version)
+
+	orderDTO, err := newOrderDecoder(t).Decode(order, version)
+	require.NoError(t, err)
+	assert.Equal(t, orderUID, orderDTO.OrderUID)
+	require.Len(t, orderDTO.Items, 1)
+	assert.Equal(t, 2389212, orderDTO.Items[0].NmID)
+	assert.Equal(t, 1, orderDTO.Items[0].Quantity)
+
+	// индекс 0 - Delivery, это не заказ
+	_, _, err = kafka.NewWireDecoder(newLocalRegistry(t)).Decode(context.Background(), wireMessage(protoSchemaID, append([]byte{0}, payload...)))
+	assert.ErrorIs(t, err, usecase.ErrOrderEncoding)
+}
+
+func TestWireDecoder_JSONSchema(t *testing.T) {
+	order := []byte(`{"schema_version": 2, "order": {}}`)
+
+	decoded, version, err := kafka.NewWireDecoder(newLocalRegistry(t)).Decode(context.Background(), wireMessage(jsonSchemaID, order))
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.Equal(t, order, decoded)
+}
+
+type unavailableRegistry struct{}
+
+func (unavailableRegistry) SchemaByID(context.Context, int) (*schemaregistry.Schema, error) {
+	return nil, errors.Join(schemaregistry.ErrRegistryUnavailable, errors.New("connection refused"))
+}
+
+func TestWireDecoder_Errors(t *testing.T) {
+	ctx := context.Background()
+	msg := wireMessage(42, []byte{1, 2, 3})
+
+	assert.True(t, kafka.IsWireFormat(msg))
+	assert.False(t, kafka.IsWireFormat([]byte(`{"order_uid": "x"}`)))
+
+	_, _, err := kafka.NewWireDecoder(nil).Decode(ctx, msg)
+	assert.ErrorIs(t, err, usecase.ErrOrderEncoding)
+
+	_, _, err = kafka.NewWireDecoder(newLocalRegistry(t)).Decode(ctx, msg)
+	assert.ErrorIs(t, err, usecase.ErrOrderEncoding)
+	assert.ErrorIs(t, err, schemaregistry.ErrSchemaNotFound)
+
+	_, _, err = kafka.NewWireDecoder(newLocalRegistry(t)).Decode(ctx, wireMessage(avroSchemaID, []byte{1, 2, 3}))
+	assert.ErrorIs(t, err, usecase.ErrOrderEncoding)
+
+	// недоступность реестра не делает сообщение битым
+	_, _, err = kafka.NewWireDecoder(unavailableRegistry{}).Decode(ctx, msg)
+	assert.ErrorIs(t, err, schemaregistry.ErrRegistryUnavailable)
+	assert.NotErrorIs(t, err, usecase.ErrOrderEncoding)
+}
@@ -0,0 +1,47 @@
+package graphql
+
+import (
+	_ "embed"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/gorilla/mux"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"log/slog"
+	"net/http"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+type Handler struct {
+	service *usecase.OrderService
+	relay   *relay.Handler
+	logger  *slog.Logger
+	cfg     config.Config
+}
+
+func NewHandler(service *usecase.OrderService, masker *masking.Masker, cfg config.Config, logger *slog.Logger) *Handler {
+	schema := graphql.MustParseSchema(schemaSDL, &Resolver{service: service, masker: masker, cfg: cfg, logger: logger},
+		graphql.MaxDepth(cfg.GraphQLMaxDepth),
+	)
+
+	return &Handler{
+		service: service,
+		relay:   &relay.Handler{Schema: schema},
+		logger:  logger,
+		cfg:     cfg,
+	}
+}
+
+// ServeHTTP кладет в контекст загрузчик заказов на время одного запроса
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	loader := NewOrderLoader(h.service.GetOrders, h.cfg.GraphQLLoaderWait, h.cfg.GraphQLMaxPageSize)
+
+	h.relay.ServeHTTP(w, r.WithContext(withLoader(r.Context(), loader)))
+}
+
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.Handle("/graphql", h).Methods("POST")
+}
@@ -0,0 +1,149 @@
+package graphql
+
+import (
+	"context"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type fetchOrders func(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error)
+
+type orderBatch struct {
+	uids   []uuid.UUID
+	orders map[uuid.UUID]*domain.Order
+	err    error
+	done   chan struct{}
+	once   sync.Once
+}
+
+// OrderLoader собирает запросы заказов по uid, пришедшие в течение wait, в один вызов fetch.
+// Живет в пределах одного GraphQL-запроса и запоминает уже загруженные заказы
+type OrderLoader struct {
+	fetch    fetchOrders
+	wait     time.Duration
+	maxBatch int
+
+	mu     sync.Mutex
+	batch  *orderBatch
+	loaded map[uuid.UUID]*domain.Order
+}
+
+func NewOrderLoader(fetch fetchOrders, wait time.Duration, maxBatch int) *OrderLoader {
+	return &OrderLoader{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		loaded:   make(map[uuid.UUID]*domain.Order),
+	}
+}
+
+// Load возвращает заказ или nil, если его нет
+func (l *OrderLoader) Load(ctx context.Context, uid uuid.UUID) (*domain.Order, error) {
+	orders, err := l.LoadMany(ctx, []uuid.UUID{uid})
+	if err != nil {
+		return nil, err
+	}
+
+	return orders[0], nil
+}
+
+// LoadMany возвращает заказы в порядке uids, на месте отсутствующих - nil
+func (l *OrderLoader) LoadMany(ctx context.Context, uids []uuid.UUID) ([]*domain.Order, error) {
+	l.mu.Lock()
+
+	var batches []*orderBatch
+	for _, uid := range uids {
+		if _, ok := l.loaded[uid]; ok {
+			continue
+		}
+		batches = append(batches, l.enqueue(ctx, uid))
+	}
+
+	l.mu.Unlock()
+
+	for _, b := range batches {
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if b.err != nil {
+			return nil, b.err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	orders := make([]*domain.Order, len(uids))
+	for i, uid := range uids {
+		orders[i] = l.loaded[uid]
+	}
+
+	return orders, nil
+}
+
+// Prime кладет уже загруженный заказ, чтобы последующий Load не ходил в репозиторий
+func (l *OrderLoader) Prime(order *domain.Order) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.loaded[order.OrderUID] = order
+}
+
+// enqueue вызывается под l.mu
+func (l *OrderLoader) enqueue(ctx context.Context, uid uuid.UUID) *orderBatch {
+	b := l.batch
+	if b == nil {
+		b = &orderBatch{done: make(chan struct{})}
+		l.batch = b
+
+		time.AfterFunc(l.wait, func() {
+			l.dispatch(ctx, b)
+		})
+	}
+
+	for _, queued := range b.uids {
+		if queued == uid {
+			return b
+		}
+	}
+	b.uids = append(b.uids, uid)
+
+	if len(b.uids) >= l.maxBatch {
+		l.batch = nil
+		go l.dispatch(ctx, b)
+	}
+
+	return b
+}
+
+func (l *OrderLoader) dispatch(ctx context.Context, b *orderBatch) {
+	b.once.Do(func() {
+		l.mu.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		uids := b.uids
+		l.mu.Unlock()
+
+		b.orders, b.err = l.fetch(ctx, uids)
+
+		l.mu.Lock()
+		for uid, order := range b.orders {
+			l.loaded[uid] = order
+		}
+		// отсутствующие тоже запоминаем, чтобы не запрашивать их повторно
+		for _, uid := range uids {
+			if _, ok := l.loaded[uid]; !ok && b.err == nil {
+				l.loaded[uid] = nil
+			}
+		}
+		l.mu.Unlock()
+
+		close(b.done)
+	})
+}
@@ -0,0 +1,314 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+	"log/slog"
+)
+
+var (
+	ErrLoaderMissing = errors.New("order loader is missing in context")
+)
+
+type loaderKey struct{}
+
+func withLoader(ctx context.Context, loader *OrderLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFrom(ctx context.Context) (*OrderLoader, error) {
+	loader, ok := ctx.Value(loaderKey{}).(*OrderLoader)
+	if !ok {
+		return nil, ErrLoaderMissing
+	}
+	return loader, nil
+}
+
+// Resolver - корневой резолвер Query
+type Resolver struct {
+	service *usecase.OrderService
+	masker  *masking.Masker
+	cfg     config.Config
+	logger  *slog.Logger
+}
+
+// queryError - ошибка в errors ответа: код в extensions.code тот же, что code в problem+json REST
+type queryError struct {
+	message   string
+	code      problem.Code
+	requestID string
+}
+
+func (e *queryError) Error() string {
+	return e.message
+}
+
+func (e *queryError) Extensions() map[string]any {
+	extensions := map[string]any{"code": e.code}
+	if e.requestID != "" {
+		extensions["request_id"] = e.requestID
+	}
+	return extensions
+}
+
+func newQueryError(ctx context.Context, code problem.Code, message string) error {
+	return &queryError{message: message, code: code, requestID: middleware.RequestIDFromContext(ctx)}
+}
+
+// serviceError переводит ошибку через problem.FromServiceError. Неизвестная ошибка пишется в лог,
+// а клиенту уходит только код internal
+func (r *Resolver) serviceError(ctx context.Context, err error) error {
+	p, ok := problem.FromServiceError(err)
+	if !ok {
+		r.logger.ErrorContext(ctx, "internal error",
+			slog.String("error", err.Error()),
+		)
+		return newQueryError(ctx, p.Code, "internal error")
+	}
+	return newQueryError(ctx, p.Code, p.Detail)
+}
+
+type filterInput struct {
+	DateFrom        *string
+	DateTo          *string
+	DeliveryService *string
+	Region          *string
+	Currency        *string
+	Phone           *string
+	Email           *string
+}
+
+func (r *Resolver) Order(ctx context.Context, args struct{ UID graphql.ID }) (*orderResolver, error) {
+	uid, err := uuid.Parse(string(args.UID))
+	if err != nil {
+		return nil, newQueryError(ctx, problem.CodeInvalidUID, "uid must be a valid UUID")
+	}
+
+	loader, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, r.serviceError(ctx, err)
+	}
+
+	order, err := loader.Load(ctx, uid)
+	if err != nil {
+		return nil, r.serviceError(ctx, err)
+	}
+	if order == nil {
+		return nil, nil
+	}
+
+	return &orderResolver{order: r.masker.Order(ctx, order)}, nil
+}
+
+func (r *Resolver) OrdersByUid(ctx context.Context, args struct{ UIDs []graphql.ID }) ([]*orderResolver, error) {
+	uids := make([]uuid.UUID, len(args.UIDs))
+	for i, id := range args.UIDs {
+		uid, err := uuid.Parse(string(id))
+		if err != nil {
+			return nil, newQueryError(ctx, problem.CodeInvalidUID, "uids must be valid UUIDs")
+		}
+		uids[i] = uid
+	}
+
+	loader, err := loaderFrom(ctx)
+	if err != nil {
+		return nil, r.serviceError(ctx, err)
+	}
+
+	orders, err := loader.LoadMany(ctx, uids)
+	if err != nil {
+		return nil, r.serviceError(ctx, err)
+	}
+
+	resolvers := make([]*orderResolver, len(orders))
+	for i, order := range orders {
+		if order != nil {
+			resolvers[i] = &orderResolver{order: r.masker.Order(ctx, order)}
+		}
+	}
+
+	return resolvers, nil
+}
+
+func (r *Resolver) Orders(ctx context.Context, args struct {
+	Filter *filterInput
+	First  int32
+	After  *string
+}) (*connectionResolver, error) {
+	var dto mapper.OrderFilterDTO
+	if f := args.Filter; f != nil {
+		dto = mapper.OrderFilterDTO{
+			DateFrom:        deref(f.DateFrom),
+			DateTo:          deref(f.DateTo),
+			DeliveryService: deref(f.DeliveryService),
+			Region:          deref(f.Region),
+			Currency:        deref(f.Currency),
+			Phone:           deref(f.Phone),
+			Email:           deref(f.Email),
+		}
+	}
+
+	filter, err := mapper.ConvertFilterToDomain(&dto)
+	if err != nil {
+		return nil, newQueryError(ctx, problem.CodeInvalidRequest, err.Error())
+	}
+
+	page, err := mapper.DecodePageToken(deref(args.After))
+	if err != nil {
+		return nil, newQueryError(ctx, problem.CodeInvalidRequest, err.Error())
+	}
+
+	page.Limit = int(args.First)
+	if page.Limit <= 0 || page.Limit > r.cfg.GraphQLMaxPageSize {
+		page.Limit = r.cfg.GraphQLMaxPageSize
+	}
+
+	orders, err := r.service.ListOrders(ctx, filter, page)
+	if err != nil {
+		return nil, r.serviceError(ctx, err)
+	}
+
+	// заказы страницы уже загружены целиком, повторно в репозиторий за ними не ходим
+	if loader, err := loaderFrom(ctx); err == nil {
+		for _, order := range orders {
+			loader.Prime(order)
+		}
+	}
+
+	return &connectionResolver{orders: r.masker.Orders(ctx, orders), limit: page.Limit}, nil
+}
+
+type connectionResolver struct {
+	orders []*domain.Order
+	limit  int
+}
+
+func (c *connectionResolver) Nodes() []*orderResolver {
+	nodes := make([]*orderResolver, len(c.orders))
+	for i, order := range c.orders {
+		nodes[i] = &orderResolver{order: order}
+	}
+	return nodes
+}
+
+func (c *connectionResolver) EndCursor() *string {
+	if len(c.orders) == 0 {
+		return nil
+	}
+	cursor := mapper.EncodePageToken(c.orders[len(c.orders)-1])
+	return &cursor
+}
+
+func (c *connectionResolver) HasNextPage() bool {
+	return len(c.orders) == c.limit
+}
+
+type orderResolver struct {
+	order *domain.Order
+}
+
+func (o *orderResolver) UID() graphql.ID           { return graphql.ID(o.order.OrderUID.String()) }
+func (o *orderResolver) TrackNumber() string       { return o.order.TrackNumber }
+func (o *orderResolver) Entry() string             { return o.order.Entry }
+func (o *orderResolver) Locale() string            { return o.order.Locale }
+func (o *orderResolver) InternalSignature() string { return o.order.InternalSignature }
+func (o *orderResolver) CustomerId() string        { return o.order.CustomerID }
+func (o *orderResolver) DeliveryService() string   { return o.order.DeliveryService }
+func (o *orderResolver) ShardKey() string          { return o.order.ShardKey }
+func (o *orderResolver) SmId() int32               { return int32(o.order.SmID) }
+func (o *orderResolver) DateCreated() string       { return mapper.FormatTime(o.order.DateCreated) }
+func (o *orderResolver) OofShard() string          { return o.order.OofShard }
+
+func (o *orderResolver) Delivery() *deliveryResolver {
+	return &deliveryResolver{delivery: &o.order.Delivery}
+}
+
+func (o *orderResolver) Payment() *paymentResolver {
+	return &paymentResolver{payment: &o.order.Payment}
+}
+
+func (o *orderResolver) Items() []*orderItemResolver {
+	items := make([]*orderItemResolver, len(o.order.Items))
+	for i := range o.order.Items {
+		items[i] = &orderItemResolver{item: &o.order.Items[i]}
+	}
+	return items
+}
+
+type deliveryResolver struct {
+	delivery *domain.Delivery
+}
+
+func (d *deliveryResolver) UID() graphql.ID { return graphql.ID(d.delivery.DeliveryUID.String()) }
+func (d *deliveryResolver) Name() string    { return d.delivery.Name }
+func (d *deliveryResolver) Phone() string   { return d.delivery.Phone }
+func (d *deliveryResolver) Zip() string     { return d.delivery.Zip }
+func (d *deliveryResolver) City() string    { return d.delivery.City }
+func (d *deliveryResolver) Address() string { return d.delivery.Address }
+func (d *deliveryResolver) Region() string  { return d.delivery.Region }
+func (d *deliveryResolver) Email() string   { return d.delivery.Email }
+
+type paymentResolver struct {
+	payment *domain.Payment
+}
+
+func (p *paymentResolver) UID() graphql.ID     { return graphql.ID(p.payment.PaymentUID.String()) }
+func (p *paymentResolver) Transaction() string { return p.payment.Transaction }
+func (p *paymentResolver) RequestId() string   { return p.payment.RequestID }
+func (p *paymentResolver) Currency() string    { return string(p.payment.Currency) }
+func (p *paymentResolver) Provider() string    { return p.payment.Provider }
+func (p *paymentResolver) Amount() Long        { return Long(p.payment.Amount.Amount) }
+func (p *paymentResolver) PaymentDt() graphql.Time {
+	return graphql.Time{Time: p.payment.PaymentDT.UTC()}
+}
+func (p *paymentResolver) Bank() string       { return p.payment.Bank }
+func (p *paymentResolver) DeliveryCost() Long { return Long(p.payment.DeliveryCost.Amount) }
+func (p *paymentResolver) GoodsTotal() Long   { return Long(p.payment.GoodsTotal.Amount) }
+func (p *paymentResolver) CustomFee() Long    { return Long(p.payment.CustomFee.Amount) }
+
+type orderItemResolver struct {
+	item *domain.OrderItem
+}
+
+func (i *orderItemResolver) UID() graphql.ID  { return graphql.ID(i.item.OrderItemUID.String()) }
+func (i *orderItemResolver) Price() Long      { return Long(i.item.Price.Amount) }
+func (i *orderItemResolver) Sale() Long       { return Long(i.item.Sale) }
+func (i *orderItemResolver) TotalPrice() Long { return Long(i.item.TotalPrice.Amount) }
+func (i *orderItemResolver) Quantity() int32  { return int32(i.item.Quantity) }
+
+func (i *orderItemResolver) Item() *itemResolver {
+	if i.item.Item == nil {
+		return &itemResolver{item: &domain.Item{ItemUID: i.item.ItemUID}}
+	}
+	return &itemResolver{item: i.item.Item}
+}
+
+type itemResolver struct {
+	item *domain.Item
+}
+
+func (i *itemResolver) UID() graphql.ID     { return graphql.ID(i.item.ItemUID.String()) }
+func (i *itemResolver) ChrtId() int32       { return int32(i.item.ChrtID) }
+func (i *itemResolver) TrackNumber() string { return i.item.TrackNumber }
+func (i *itemResolver) Rid() string         { return i.item.RID }
+func (i *itemResolver) Name() string        { return i.item.Name }
+func (i *itemResolver) Size() string        { return i.item.Size }
+func (i *itemResolver) NmId() int32         { return int32(i.item.NmID) }
+func (i *itemResolver) Brand() string       { return i.item.Brand }
+func (i *itemResolver) Status() int32       { return int32(i.item.Status) }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,150 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_BatchesOrderLookups(t *testing.T) {
+	first := &domain.Order{
+		OrderUID:    uuid.New(),
+		TrackNumber: "T1",
+		Items:       []domain.OrderItem{{Price: domain.NewMoney(10, "RUB"), Item: &domain.Item{Name: "first"}}},
+	}
+	second := &domain.Order{OrderUID: uuid.New(), TrackNumber: "T2"}
+
+	var calls atomic.Int32
+	loader := NewOrderLoader(func(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+		calls.Add(1)
+		orders := make(map[uuid.UUID]*domain.Order)
+		for _, uid := range uids {
+			switch uid {
+			case first.OrderUID:
+				orders[uid] = first
+			case second.OrderUID:
+				orders[uid] = second
+			}
+		}
+		return orders, nil
+	}, 5*time.Millisecond, 100)
+
+	schema := graphql.MustParseSchema(schemaSDL, &Resolver{})
+	query := `query($a: ID!, $b: ID!, $c: ID!) {
+		a: order(uid: $a) { trackNumber items { price item { name } } }
+		b: order(uid: $b) { trackNumber }
+		c: order(uid: $c) { trackNumber }
+		many: ordersByUid(uids: [$a, $b]) { trackNumber }
+	}`
+	vars := map[string]any{
+		"a": first.OrderUID.String(),
+		"b": second.OrderUID.String(),
+		"c": uuid.NewString(),
+	}
+
+	resp := schema.Exec(withLoader(context.Background(), loader), query, "", vars)
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		A struct {
+			TrackNumber string
+			Items       []struct {
+				Price int
+				Item  struct{ Name string }
+			}
+		}
+		B    struct{ TrackNumber string }
+		C    *struct{ TrackNumber string }
+		Many []struct{ TrackNumber string }
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+
+	assert.Equal(t, "T1", data.A.TrackNumber)
+	assert.Equal(t, "first", data.A.Items[0].Item.Name)
+	assert.Equal(t, "T2", data.B.TrackNumber)
+	assert.Nil(t, data.C)
+	assert.Len(t, data.Many, 2)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestOrderLoader_SplitsByMaxBatch(t *testing.T) {
+	var calls atomic.Int32
+	loader := NewOrderLoader(func(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+		calls.Add(1)
+		assert.LessOrEqual(t, len(uids), 2)
+		return map[uuid.UUID]*domain.Order{}, nil
+	}, time.Millisecond, 2)
+
+	orders, err := loader.LoadMany(context.Background(), []uuid.UUID{uuid.New(), uuid.New(), uuid.New()})
+
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.Order{nil, nil, nil}, orders)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestQuery_LongAmountsAndTime(t *testing.T) {
+	order := &domain.Order{
+		OrderUID: uuid.New(),
+		Payment: domain.Payment{
+			Amount:    domain.NewMoney(5_000_000_000, "RUB"),
+			PaymentDT: time.Date(2040, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		Items: []domain.OrderItem{{TotalPrice: domain.NewMoney(3_000_000_000, "RUB")}},
+	}
+	loader := NewOrderLoader(func(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+		return map[uuid.UUID]*domain.Order{order.OrderUID: order}, nil
+	}, time.Millisecond, 100)
+
+	schema := graphql.MustParseSchema(schemaSDL, &Resolver{})
+	resp := schema.Exec(withLoader(context.Background(), loader),
+		`query($uid: ID!) { order(uid: $uid) { payment { amount paymentDt } items { totalPrice } } }`, "",
+		map[string]any{"uid": order.OrderUID.String()})
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		Order struct {
+			Payment struct {
+				Amount    int64
+				PaymentDt string
+			}
+			Items []struct{ TotalPrice int64 }
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	assert.Equal(t, int64(5_000_000_000), data.Order.Payment.Amount)
+	assert.Equal(t, "2040-01-02T03:04:05Z", data.Order.Payment.PaymentDt)
+	assert.Equal(t, int64(3_000_000_000), data.Order.Items[0].TotalPrice)
+}
+
+func TestQuery_ErrorsHaveStableCodes(t *testing.T) {
+	loader := NewOrderLoader(func(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+		return nil, errors.New(`pq: relation "orders" does not exist`)
+	}, time.Millisecond, 100)
+
+	schema := graphql.MustParseSchema(schemaSDL, &Resolver{logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	ctx := middleware.WithRequestID(withLoader(context.Background(), loader), "req-1")
+
+	resp := schema.Exec(ctx, `query($uid: ID!) { order(uid: $uid) { trackNumber } }`, "",
+		map[string]any{"uid": uuid.NewString()})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "internal error", resp.Errors[0].Message)
+	assert.Equal(t, problem.CodeInternal, resp.Errors[0].Extensions["code"])
+	assert.Equal(t, "req-1", resp.Errors[0].Extensions["request_id"])
+
+	resp = schema.Exec(ctx, `{ order(uid: "not-a-uuid") { trackNumber } }`, "", nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, problem.CodeInvalidUID, resp.Errors[0].Extensions["code"])
+}
@@ -0,0 +1,30 @@
+package graphql
+
+import (
+	"fmt"
+	"math"
+)
+
+// Long - 64-битное целое для сумм в минимальных единицах валюты: Int в GraphQL 32-битный
+type Long int64
+
+func (Long) ImplementsGraphQLType(name string) bool {
+	return name == "Long"
+}
+
+func (l *Long) UnmarshalGraphQL(input any) error {
+	switch v := input.(type) {
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v > math.MaxInt64 {
+			return fmt.Errorf("%v is not a 64-bit integer", v)
+		}
+		*l = Long(v)
+	default:
+		return fmt.Errorf("wrong type for Long: %T", input)
+	}
+	return nil
+}
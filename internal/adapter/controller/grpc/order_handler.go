@@ -0,0 +1,200 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/adapter/pubsub/inmemory"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+)
+
+const defaultPageSize = 50
+
+type OrderHandler struct {
+	orderv1.UnimplementedOrderServiceServer
+
+	service   *usecase.OrderService
+	submitter *usecase.OrderSubmitter
+	hub       *inmemory.InMemOrderHub
+	masker    *masking.Masker
+	logger    *slog.Logger
+	cfg       config.Config
+}
+
+var _ orderv1.OrderServiceServer = (*OrderHandler)(nil)
+
+func NewOrderHandler(
+	service *usecase.OrderService,
+	submitter *usecase.OrderSubmitter,
+	hub *inmemory.InMemOrderHub,
+	masker *masking.Masker,
+	cfg config.Config,
+	logger *slog.Logger,
+) *OrderHandler {
+	return &OrderHandler{
+		service:   service,
+		submitter: submitter,
+		hub:       hub,
+		masker:    masker,
+		logger:    logger,
+		cfg:       cfg,
+	}
+}
+
+func (h *OrderHandler) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.GetOrderResponse, error) {
+	uid, err := uuid.Parse(req.GetOrderUid())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	order, err := h.service.GetOrder(ctx, uid)
+	if err != nil {
+		return nil, h.toStatus(ctx, err)
+	}
+
+	return &orderv1.GetOrderResponse{Order: mapper.ConvertToProto(h.masker.Order(ctx, order))}, nil
+}
+
+func (h *OrderHandler) ListOrders(ctx context.Context, req *orderv1.ListOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	filter, err := mapper.ConvertFilterToDomain(&mapper.OrderFilterDTO{
+		DateFrom:        req.GetFilter().GetDateFrom(),
+		DateTo:          req.GetFilter().GetDateTo(),
+		DeliveryService: req.GetFilter().GetDeliveryService(),
+		Region:          req.GetFilter().GetRegion(),
+		Currency:        req.GetFilter().GetCurrency(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := mapper.DecodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page.Limit = int(req.GetPageSize())
+	if page.Limit <= 0 {
+		page.Limit = defaultPageSize
+	}
+	if page.Limit > h.cfg.GRPCMaxPageSize {
+		page.Limit = h.cfg.GRPCMaxPageSize
+	}
+
+	orders, err := h.service.ListOrders(ctx, filter, page)
+	if err != nil {
+		return nil, h.toStatus(ctx, err)
+	}
+
+	resp := &orderv1.ListOrdersResponse{
+		Orders: make([]*orderv1.Order, len(orders)),
+	}
+	for i, order := range h.masker.Orders(ctx, orders) {
+		resp.Orders[i] = mapper.ConvertToProto(order)
+	}
+
+	if len(orders) == page.Limit {
+		resp.NextPageToken = mapper.EncodePageToken(orders[len(orders)-1])
+	}
+
+	return resp, nil
+}
+
+func (h *OrderHandler) StreamNewOrders(_ *orderv1.StreamNewOrdersRequest, stream orderv1.OrderService_StreamNewOrdersServer) error {
+	sub := h.hub.Subscribe(nil)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case order, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(mapper.ConvertToProto(h.masker.Order(stream.Context(), order))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (h *OrderHandler) SubmitOrder(ctx context.Context, req *orderv1.SubmitOrderRequest) (*orderv1.SubmitOrderResponse, error) {
+	// тот же разбор, что у Protobuf из Kafka: схема v1 и подъем до текущей версии
+	orderDTO, err := h.service.DecodeProtoOrder(req.GetOrder())
+	if err == nil {
+		err = h.service.ValidateOrder(ctx, orderDTO)
+	}
+	if usecase.ValidationViolations(err) != nil {
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, violation := range usecase.ValidationViolations(err) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: violation.Path, Description: violation.Message})
+		}
+		st, _ := status.New(codes.InvalidArgument, err.Error()).WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+		return nil, st.Err()
+	}
+	if err != nil {
+		return nil, h.toStatus(ctx, err)
+	}
+
+	order := mapper.ConvertToDomain(orderDTO)
+	uid, replayed, err := h.submitter.Submit(ctx, req.GetIdempotencyKey(), requestHash(req.GetOrder()), order)
+	if err != nil {
+		return nil, h.toStatus(ctx, err)
+	}
+
+	if !replayed {
+		h.logger.InfoContext(ctx, "order has been saved in db",
+			slog.String("uuid", uid.String()),
+			slog.String("source", "grpc"),
+		)
+	}
+
+	return &orderv1.SubmitOrderResponse{OrderUid: uid.String(), Replayed: replayed}, nil
+}
+
+// requestHash - хэш заказа для проверки повторного использования ключа идемпотентности
+func requestHash(order *orderv1.Order) string {
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(order)
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+// toStatus переводит ошибку сервиса в статус gRPC. Текст непредвиденных ошибок уходит только в лог
+// с request id, клиент получает общий Internal
+func (h *OrderHandler) toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, postgres.ErrOrderDoesNotExists):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, postgres.ErrOrderAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, usecase.ErrIdempotencyKeyReused):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, postgres.ErrMaxRetryAttemptsExceeded):
+		// база недоступна, клиент может повторить вызов
+		h.logger.WarnContext(ctx, "storage unavailable",
+			slog.String("error", err.Error()),
+		)
+		return status.Error(codes.Unavailable, "storage unavailable")
+	default:
+		h.logger.ErrorContext(ctx, "internal error",
+			slog.String("error", err.Error()),
+		)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,299 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	"github.com/folivorra/get_order/internal/adapter/controller/grpc"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/pubsub/inmemory"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// MockRepo - остальные методы OrderRepo обработчиком не используются
+type MockRepo struct {
+	usecase.OrderRepo
+	mock.Mock
+}
+
+func (m *MockRepo) Get(ctx context.Context, uid uuid.UUID) (*domain.Order, error) {
+	args := m.Called(ctx, uid)
+	order, _ := args.Get(0).(*domain.Order)
+	return order, args.Error(1)
+}
+
+func (m *MockRepo) Save(ctx context.Context, order *domain.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SaveWithIdempotencyKey(ctx context.Context, order *domain.Order, key *domain.IdempotencyKey) error {
+	args := m.Called(ctx, order, key)
+	return args.Error(0)
+}
+
+func (m *MockRepo) List(ctx context.Context, filter domain.OrderFilter, page domain.OrderPage) ([]*domain.Order, error) {
+	args := m.Called(ctx, filter, page)
+	return args.Get(0).([]*domain.Order), args.Error(1)
+}
+
+type MockCache struct {
+	mock.Mock
+}
+
+func (m *MockCache) Get(uid uuid.UUID) (*domain.Order, error) {
+	args := m.Called(uid)
+	order, _ := args.Get(0).(*domain.Order)
+	return order, args.Error(1)
+}
+
+func (m *MockCache) Set(order *domain.Order) {
+	m.Called(order)
+}
+
+type MockIdempotencyRepo struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepo) Get(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	args := m.Called(ctx, key)
+	record, _ := args.Get(0).(*domain.IdempotencyKey)
+	return record, args.Error(1)
+}
+
+type testServer struct {
+	client orderv1.OrderServiceClient
+	repo   *MockRepo
+	cache  *MockCache
+	keys   *MockIdempotencyRepo
+	hub    *inmemory.InMemOrderHub
+}
+
+// newTestServer поднимает gRPC-сервер с обработчиком заказов в памяти
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.NewConfig(logger)
+	cfg.GRPCMaxPageSize = 2
+
+	validator, err := usecase.NewOrderValidator(usecase.DefaultRuleSets())
+	require.NoError(t, err)
+
+	ts := &testServer{
+		repo:  new(MockRepo),
+		cache: new(MockCache),
+		keys:  new(MockIdempotencyRepo),
+		hub:   inmemory.NewInMemOrderHub(logger, cfg.FeedSubscriberBuffer),
+	}
+	service := usecase.NewOrderService(logger, cfg, ts.repo, ts.cache, ts.hub, validator, nil)
+	submitter := usecase.NewOrderSubmitter(logger, service, ts.keys)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := googlegrpc.NewServer()
+	orderv1.RegisterOrderServiceServer(srv, grpc.NewOrderHandler(service, submitter, ts.hub, nil, cfg, logger))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	conn, err := googlegrpc.NewClient("passthrough:///bufnet",
+		googlegrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		googlegrpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.Stop()
+	})
+
+	ts.client = orderv1.NewOrderServiceClient(conn)
+	return ts
+}
+
+func testOrder(dateCreated time.Time) *domain.Order {
+	return &domain.Order{
+		OrderUID:    uuid.New(),
+		TrackNumber: "TRACK123",
+		Entry:       "WBIL",
+		Delivery: domain.Delivery{
+			Name:  "Test User",
+			City:  "Test City",
+			Phone: "+79720000000",
+			Email: "test@gmail.com",
+		},
+		Payment: domain.Payment{
+			Amount:    domain.NewMoney(100, "RUB"),
+			Currency:  "RUB",
+			PaymentDT: dateCreated,
+		},
+		Items: []domain.OrderItem{
+			{Item: &domain.Item{NmID: 1}, Price: domain.NewMoney(100, "RUB"), TotalPrice: domain.NewMoney(100, "RUB"), Quantity: 1},
+		},
+		DeliveryService: "meest",
+		DateCreated:     dateCreated,
+	}
+}
+
+func TestGetOrder_NotFound(t *testing.T) {
+	ts := newTestServer(t)
+	uid := uuid.New()
+
+	ts.cache.On("Get", uid).Return(nil, errors.New("cache miss"))
+	ts.repo.On("Get", mock.Anything, uid).Return(nil, postgres.ErrOrderDoesNotExists)
+
+	_, err := ts.client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: uid.String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGetOrder_InternalErrorIsHidden(t *testing.T) {
+	ts := newTestServer(t)
+	uid := uuid.New()
+
+	ts.cache.On("Get", uid).Return(nil, errors.New("cache miss"))
+	ts.repo.On("Get", mock.Anything, uid).Return(nil, errors.New(`pq: relation "orders" does not exist`))
+
+	_, err := ts.client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: uid.String()})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "relation")
+}
+
+func TestGetOrder_StorageUnavailable(t *testing.T) {
+	ts := newTestServer(t)
+	uid := uuid.New()
+
+	ts.cache.On("Get", uid).Return(nil, errors.New("cache miss"))
+	ts.repo.On("Get", mock.Anything, uid).Return(nil, fmt.Errorf("%w: %w", postgres.ErrMaxRetryAttemptsExceeded, errors.New("connection refused")))
+
+	_, err := ts.client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: uid.String()})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "connection refused")
+}
+
+func TestListOrders_PageTokenRoundTrip(t *testing.T) {
+	ts := newTestServer(t)
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	first, second, third := testOrder(start), testOrder(start.Add(time.Minute)), testOrder(start.Add(2*time.Minute))
+
+	ts.repo.On("List", mock.Anything, mock.Anything, domain.OrderPage{Limit: 2}).
+		Return([]*domain.Order{first, second}, nil).Once()
+	ts.repo.On("List", mock.Anything, mock.Anything, domain.OrderPage{
+		Limit:            2,
+		AfterDateCreated: second.DateCreated,
+		AfterOrderUID:    second.OrderUID,
+	}).Return([]*domain.Order{third}, nil).Once()
+
+	// размер страницы ограничен GRPC_MAX_PAGE_SIZE
+	resp, err := ts.client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{PageSize: 10})
+	require.NoError(t, err)
+	require.Len(t, resp.GetOrders(), 2)
+	require.NotEmpty(t, resp.GetNextPageToken())
+
+	resp, err = ts.client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{PageSize: 10, PageToken: resp.GetNextPageToken()})
+	require.NoError(t, err)
+	require.Len(t, resp.GetOrders(), 1)
+	assert.Equal(t, third.OrderUID.String(), resp.GetOrders()[0].GetOrderUid())
+	assert.Empty(t, resp.GetNextPageToken())
+
+	ts.repo.AssertExpectations(t)
+}
+
+func TestListOrders_InvalidPageToken(t *testing.T) {
+	ts := newTestServer(t)
+
+	_, err := ts.client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{PageToken: "not-a-token"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSubmitOrder_Replay(t *testing.T) {
+	ts := newTestServer(t)
+	order := mapper.ConvertToProto(testOrder(time.Now().UTC().Truncate(time.Second)))
+	stored := uuid.New()
+
+	var hash string
+	ts.keys.On("Get", mock.Anything, "key").Return(nil, usecase.ErrIdempotencyKeyNotFound).Once()
+	ts.repo.On("SaveWithIdempotencyKey", mock.Anything, mock.AnythingOfType("*domain.Order"), mock.MatchedBy(func(record *domain.IdempotencyKey) bool {
+		hash = record.RequestHash
+		return record.Key == "key"
+	})).Return(nil).Once()
+
+	resp, err := ts.client.SubmitOrder(context.Background(), &orderv1.SubmitOrderRequest{IdempotencyKey: "key", Order: order})
+	require.NoError(t, err)
+	assert.False(t, resp.GetReplayed())
+	require.NotEmpty(t, hash)
+
+	// повтор с тем же заказом отдает сохраненный uid и не пишет заказ заново
+	ts.keys.On("Get", mock.Anything, "key").Return(&domain.IdempotencyKey{Key: "key", RequestHash: hash, OrderUID: stored}, nil)
+
+	resp, err = ts.client.SubmitOrder(context.Background(), &orderv1.SubmitOrderRequest{IdempotencyKey: "key", Order: order})
+	require.NoError(t, err)
+	assert.True(t, resp.GetReplayed())
+	assert.Equal(t, stored.String(), resp.GetOrderUid())
+
+	// тот же ключ с другим заказом
+	order.TrackNumber = "TRACK999"
+	_, err = ts.client.SubmitOrder(context.Background(), &orderv1.SubmitOrderRequest{IdempotencyKey: "key", Order: order})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	ts.repo.AssertNumberOfCalls(t, "SaveWithIdempotencyKey", 1)
+}
+
+func TestSubmitOrder_UpcastsLikeKafka(t *testing.T) {
+	ts := newTestServer(t)
+	order := mapper.ConvertToProto(testOrder(time.Now().UTC().Truncate(time.Second)))
+	order.Items[0].Quantity = 0
+
+	var saved *domain.Order
+	ts.repo.On("Save", mock.Anything, mock.AnythingOfType("*domain.Order")).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*domain.Order)
+	}).Return(nil).Once()
+
+	// в order.v1 нулевое quantity означает одну штуку, как у Protobuf из Kafka
+	_, err := ts.client.SubmitOrder(context.Background(), &orderv1.SubmitOrderRequest{Order: order})
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, 1, saved.Items[0].Quantity)
+}
+
+func TestStreamNewOrders(t *testing.T) {
+	ts := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := ts.client.StreamNewOrders(ctx, &orderv1.StreamNewOrdersRequest{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return ts.hub.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+	order := testOrder(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	ts.hub.Publish(order)
+
+	got, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, order.OrderUID.String(), got.GetOrderUid())
+
+	// отмена стрима клиентом снимает подписку
+	cancel()
+	require.Eventually(t, func() bool { return ts.hub.Len() == 0 }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/config"
+	googlegrpc "google.golang.org/grpc"
+	"log/slog"
+	"net"
+)
+
+type Server struct {
+	server *googlegrpc.Server
+	cfg    config.Config
+	logger *slog.Logger
+}
+
+func NewServer(server *googlegrpc.Server, cfg config.Config, logger *slog.Logger) *Server {
+	return &Server{
+		server: server,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Registrar нужен для регистрации обработчиков до Run
+func (s *Server) Registrar() googlegrpc.ServiceRegistrar {
+	return s.server
+}
+
+func (s *Server) Run() error {
+	lis, err := net.Listen("tcp", ":"+s.cfg.GRPCPort)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("grpc server started",
+		slog.String("port", s.cfg.GRPCPort),
+	)
+
+	err = s.server.Serve(lis)
+	if err != nil && !errors.Is(err, googlegrpc.ErrServerStopped) {
+		s.logger.Warn("grpc server panic",
+			slog.String("port", s.cfg.GRPCPort),
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// Stop ждет завершения активных вызовов не дольше GRPCShutdownTimeout, после чего закрывает соединения принудительно.
+// Отмена ctx не сокращает ожидание: к остановке он обычно уже отменен
+func (s *Server) Stop(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.GRPCShutdownTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("failed to shutdown grpc server gracefully",
+			slog.String("port", s.cfg.GRPCPort),
+			slog.String("error", ctx.Err().Error()),
+		)
+		s.server.Stop()
+	}
+
+	s.logger.Info("grpc server has been stopped",
+		slog.String("port", s.cfg.GRPCPort),
+	)
+}
@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeCached отдает body со strong ETag от тела ответа и отвечает 304 на совпавший If-None-Match
+// (или If-Modified-Since, если ETag клиент не прислал). Тело зависит от роли клиента из-за маскирования
+// и от Accept, поэтому ответ кэшируется только в браузере (private) и варьируется по этим заголовкам
+func writeCached(w http.ResponseWriter, r *http.Request, contentType string, body []byte, lastModified time.Time, maxAge time.Duration) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds()))+", must-revalidate")
+	h.Add("Vary", "Accept, Authorization, X-API-Key")
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// notModified проверяет условия GET по RFC 9110: If-None-Match важнее If-Modified-Since
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatch(header, etag)
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagMatch - слабое сравнение, как требует If-None-Match: префикс W/ не учитывается
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package rest
+
+import (
+	"embed"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"html/template"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed templates/invoice.html
+var templatesFS embed.FS
+
+var invoiceTemplate = template.Must(template.New("invoice.html").Funcs(template.FuncMap{
+	// money - сумма в минимальных единицах в основных: 1817 USD - "18.17"
+	"money": func(amount int, currency string) string {
+		return domain.NewMoney(int64(amount), domain.Currency(currency)).Decimal()
+	},
+}).ParseFS(templatesFS, "templates/invoice.html"))
+
+// orderEncoder кодирует заказ в один из форматов ответа GET /order/{uid}
+type orderEncoder struct {
+	contentType string
+	aliases     []string
+	encode      func(w io.Writer, order *domain.Order) error
+}
+
+// header - Content-Type ответа, текстовым форматам добавляется кодировка
+func (e orderEncoder) header() string {
+	if strings.HasPrefix(e.contentType, "text/") || e.contentType == "application/xml" {
+		return e.contentType + "; charset=utf-8"
+	}
+	return e.contentType
+}
+
+// orderEncoders - форматы в порядке предпочтения сервера, первый отдается без Accept или на */*
+var orderEncoders = []orderEncoder{
+	{
+		contentType: "application/json",
+		encode: func(w io.Writer, order *domain.Order) error {
+			return json.NewEncoder(w).Encode(mapper.ConvertFromDomain(order))
+		},
+	},
+	{
+		contentType: "application/xml",
+		aliases:     []string{"text/xml"},
+		encode: func(w io.Writer, order *domain.Order) error {
+			if _, err := io.WriteString(w, xml.Header); err != nil {
+				return err
+			}
+			return xml.NewEncoder(w).Encode(mapper.ConvertFromDomain(order))
+		},
+	},
+	{
+		contentType: "application/x-protobuf",
+		aliases:     []string{"application/protobuf"},
+		encode: func(w io.Writer, order *domain.Order) error {
+			data, err := proto.Marshal(mapper.ConvertToProto(order))
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		},
+	},
+	{
+		contentType: "application/msgpack",
+		aliases:     []string{"application/x-msgpack"},
+		encode: func(w io.Writer, order *domain.Order) error {
+			enc := msgpack.NewEncoder(w)
+			enc.SetCustomStructTag("json")
+			return enc.Encode(mapper.ConvertFromDomain(order))
+		},
+	},
+	{
+		contentType: "text/html",
+		encode: func(w io.Writer, order *domain.Order) error {
+			return invoiceTemplate.Execute(w, mapper.ConvertFromDomain(order))
+		},
+	},
+}
+
+// supportedOrderTypes - список форматов для ответа 406
+func supportedOrderTypes() string {
+	types := make([]string, len(orderEncoders))
+	for i, enc := range orderEncoders {
+		types[i] = enc.contentType
+	}
+	return strings.Join(types, ", ")
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateOrderEncoder выбирает формат по Accept: наибольший q, при равенстве - более точный диапазон,
+// затем порядок сервера. ok=false - ни один формат клиенту не подходит
+func negotiateOrderEncoder(accept string) (orderEncoder, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return orderEncoders[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	best, bestQ, bestSpecificity := -1, 0.0, -1
+	for i, enc := range orderEncoders {
+		q, specificity := matchAccept(ranges, enc)
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = i, q, specificity
+		}
+	}
+
+	if best < 0 {
+		return orderEncoder{}, false
+	}
+	return orderEncoders[best], true
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	// точные диапазоны важнее масок: "application/xml;q=0" перекрывает "*/*"
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return rangeSpecificity(ranges[i].mediaType) > rangeSpecificity(ranges[j].mediaType)
+	})
+
+	return ranges
+}
+
+// matchAccept - q самого точного диапазона, под который подходит формат, и точность этого диапазона
+func matchAccept(ranges []acceptRange, enc orderEncoder) (float64, int) {
+	types := append([]string{enc.contentType}, enc.aliases...)
+
+	for _, r := range ranges {
+		for _, t := range types {
+			if mediaRangeMatch(r.mediaType, t) {
+				return r.q, rangeSpecificity(r.mediaType)
+			}
+		}
+	}
+	return 0, -1
+}
+
+func mediaRangeMatch(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+func rangeSpecificity(mediaRange string) int {
+	switch {
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/adapter/pubsub/inmemory"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FeedController отдает поток новых заказов по SSE или WebSocket
+type FeedController struct {
+	hub      *inmemory.InMemOrderHub
+	masker   *masking.Masker
+	upgrader websocket.Upgrader
+	logger   *slog.Logger
+	cfg      config.Config
+	done     chan struct{}
+	once     sync.Once
+}
+
+func NewFeedController(hub *inmemory.InMemOrderHub, masker *masking.Masker, cfg config.Config, logger *slog.Logger) *FeedController {
+	return &FeedController{
+		hub:    hub,
+		masker: masker,
+		logger: logger,
+		cfg:    cfg,
+		done:   make(chan struct{}),
+	}
+}
+
+// Close завершает все открытые потоки, нужен для http.Server.RegisterOnShutdown:
+// иначе Shutdown будет ждать долгоживущие соединения до таймаута
+func (c *FeedController) Close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *FeedController) StreamOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	deliveryService := query.Get("delivery_service")
+	region := query.Get("region")
+
+	sub := c.hub.Subscribe(func(order *domain.Order) bool {
+		return (deliveryService == "" || order.DeliveryService == deliveryService) &&
+			(region == "" || order.Delivery.Region == region)
+	})
+	defer sub.Close()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		c.streamWebSocket(w, r, sub)
+		return
+	}
+
+	c.streamSSE(w, r, sub)
+}
+
+func (c *FeedController) streamSSE(w http.ResponseWriter, r *http.Request, sub *inmemory.Subscription) {
+	rc := http.NewResponseController(w)
+	// поток живет дольше WriteTimeout сервера
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		c.logger.ErrorContext(r.Context(), "streaming is not supported",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	heartbeat := time.NewTicker(c.cfg.FeedHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var reportedDropped int64
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case order, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			// клиент не успевал читать - сообщаем, сколько заказов он пропустил
+			if dropped := sub.Dropped(); dropped != reportedDropped {
+				if _, err := fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped-reportedDropped); err != nil {
+					return
+				}
+				reportedDropped = dropped
+			}
+
+			data, err := json.Marshal(mapper.ConvertFromDomain(c.masker.Order(r.Context(), order)))
+			if err != nil {
+				c.logger.ErrorContext(r.Context(), "failed to marshal order",
+					slog.String("uuid", order.OrderUID.String()),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+
+			if _, err = fmt.Fprintf(w, "id: %s\nevent: order\ndata: %s\n\n", order.OrderUID, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+type feedMessage struct {
+	Type    string                     `json:"type"`
+	Order   *mapper.OrderFromDomainDTO `json:"order,omitempty"`
+	Dropped int64                      `json:"dropped,omitempty"`
+}
+
+func (c *FeedController) streamWebSocket(w http.ResponseWriter, r *http.Request, sub *inmemory.Subscription) {
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade сам отвечает клиенту ошибкой
+		c.logger.WarnContext(r.Context(), "failed to upgrade connection",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// читаем соединение только ради control-фреймов и обнаружения закрытия
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(c.cfg.FeedHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var reportedDropped int64
+
+	write := func(msg feedMessage) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(c.cfg.FeedWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-c.done:
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown"),
+				time.Now().Add(c.cfg.FeedWriteTimeout),
+			)
+			return
+		case <-heartbeat.C:
+			if err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.FeedWriteTimeout)); err != nil {
+				return
+			}
+		case order, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			if dropped := sub.Dropped(); dropped != reportedDropped {
+				if err = write(feedMessage{Type: "dropped", Dropped: dropped - reportedDropped}); err != nil {
+					return
+				}
+				reportedDropped = dropped
+			}
+
+			if err = write(feedMessage{Type: "order", Order: mapper.ConvertFromDomain(c.masker.Order(r.Context(), order))}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *FeedController) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/orders/stream", c.StreamOrders).Methods("GET")
+}
@@ -0,0 +1,106 @@
+package rest_test
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/adapter/controller/rest"
+	"github.com/folivorra/get_order/internal/adapter/pubsub/inmemory"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFeedServer(t *testing.T) (*httptest.Server, *inmemory.InMemOrderHub) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.NewConfig(logger)
+	hub := inmemory.NewInMemOrderHub(logger, cfg.FeedSubscriberBuffer)
+
+	feed := rest.NewFeedController(hub, nil, cfg, logger)
+	router := mux.NewRouter()
+	feed.RegisterRoutes(router)
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(func() {
+		feed.Close()
+		srv.Close()
+	})
+
+	return srv, hub
+}
+
+func waitSubscribers(t *testing.T, hub *inmemory.InMemOrderHub, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return hub.Len() == n }, time.Second, 5*time.Millisecond)
+}
+
+func TestStreamOrders_SSEFiltersByRegion(t *testing.T) {
+	srv, hub := newFeedServer(t)
+
+	resp, err := http.Get(srv.URL + "/orders/stream?region=north")
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	waitSubscribers(t, hub, 1)
+
+	north := &domain.Order{OrderUID: uuid.New(), Delivery: domain.Delivery{Region: "north"}}
+	hub.Publish(&domain.Order{OrderUID: uuid.New(), Delivery: domain.Delivery{Region: "south"}})
+	hub.Publish(north)
+
+	reader := bufio.NewReader(resp.Body)
+	var event []string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			break
+		}
+		event = append(event, line)
+	}
+
+	require.Len(t, event, 3)
+	assert.Equal(t, "id: "+north.OrderUID.String(), event[0])
+	assert.Equal(t, "event: order", event[1])
+	assert.Contains(t, event[2], `"region":"north"`)
+}
+
+func TestStreamOrders_WebSocket(t *testing.T) {
+	srv, hub := newFeedServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/orders/stream?delivery_service=meest", nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	waitSubscribers(t, hub, 1)
+
+	order := &domain.Order{OrderUID: uuid.New(), DeliveryService: "meest"}
+	hub.Publish(order)
+
+	var msg struct {
+		Type  string `json:"type"`
+		Order struct {
+			OrderUID uuid.UUID `json:"order_uid"`
+		} `json:"order"`
+	}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "order", msg.Type)
+	assert.Equal(t, order.OrderUID, msg.Order.OrderUID)
+}
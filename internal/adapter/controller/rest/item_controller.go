@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// ItemController - каталог товаров по артикулу nm_id
+type ItemController struct {
+	items  *usecase.ItemService
+	masker *masking.Masker
+	logger *slog.Logger
+	cfg    config.Config
+}
+
+func NewItemController(items *usecase.ItemService, masker *masking.Masker, cfg config.Config, logger *slog.Logger) *ItemController {
+	return &ItemController{
+		items:  items,
+		masker: masker,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+type itemOrdersResponse struct {
+	Items []*mapper.OrderFromDomainDTO `json:"items"`
+	Next  string                       `json:"next,omitempty"`
+}
+
+func (c *ItemController) GetItem(w http.ResponseWriter, r *http.Request) {
+	nmID, ok := c.parseNmID(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := c.items.GetItem(r.Context(), nmID)
+	if err != nil {
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mapper.ConvertCatalog(nmID, items))
+}
+
+// GetItemOrders - заказы с артикулом в порядке даты создания, next - токен следующей страницы для after
+func (c *ItemController) GetItemOrders(w http.ResponseWriter, r *http.Request) {
+	nmID, ok := c.parseNmID(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	page, err := mapper.DecodePageToken(query.Get("after"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "after must be a page token from next")
+		return
+	}
+
+	page.Limit = c.cfg.CatalogMaxPageSize
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "limit must be a positive integer")
+			return
+		}
+		page.Limit = min(limit, c.cfg.CatalogMaxPageSize)
+	}
+
+	orders, err := c.items.ItemOrders(r.Context(), nmID, page)
+	if err != nil {
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	resp := itemOrdersResponse{Items: make([]*mapper.OrderFromDomainDTO, len(orders))}
+	for i, order := range c.masker.Orders(r.Context(), orders) {
+		resp.Items[i] = mapper.ConvertFromDomain(order)
+	}
+	if len(orders) == page.Limit {
+		resp.Next = mapper.EncodePageToken(orders[len(orders)-1])
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (c *ItemController) parseNmID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	nmID, err := strconv.Atoi(mux.Vars(r)["nm_id"])
+	if err != nil || nmID <= 0 {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "nm_id must be a positive integer")
+		return 0, false
+	}
+	return nmID, true
+}
+
+func (c *ItemController) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/items/{nm_id}", c.GetItem).Methods("GET")
+	r.HandleFunc("/items/{nm_id}/orders", c.GetItemOrders).Methods("GET")
+}
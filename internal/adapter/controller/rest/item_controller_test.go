@@ -0,0 +1,157 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/adapter/cache/inmemory"
+	"github.com/folivorra/get_order/internal/adapter/controller/rest"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubItemRepo отдает заданный каталог по любому артикулу, nil - артикула нет
+type stubItemRepo struct {
+	items []*domain.CatalogItem
+}
+
+func (s *stubItemRepo) GetByNmID(context.Context, int) ([]*domain.CatalogItem, error) {
+	if s.items == nil {
+		return nil, usecase.ErrItemNotFound
+	}
+	return s.items, nil
+}
+
+// stubListRepo отдает на List заданные заказы и запоминает фильтр
+type stubListRepo struct {
+	usecase.OrderRepo
+	orders []*domain.Order
+	filter domain.OrderFilter
+}
+
+func (s *stubListRepo) List(_ context.Context, filter domain.OrderFilter, page domain.OrderPage) ([]*domain.Order, error) {
+	s.filter = filter
+	return s.orders[:min(len(s.orders), page.Limit)], nil
+}
+
+func newItemRouter(t *testing.T, items usecase.ItemRepo, orders usecase.OrderRepo) *mux.Router {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.NewConfig(logger)
+	service := usecase.NewOrderService(logger, cfg, orders, inmemory.NewInMemOrderCache(logger, 10), nil, nil, nil)
+	controller := rest.NewItemController(usecase.NewItemService(logger, items, service), nil, cfg, logger)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequestIDMiddleware())
+	controller.RegisterRoutes(router)
+
+	return router
+}
+
+func TestGetItem(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	orderUID := uuid.New()
+	repo := &stubItemRepo{items: []*domain.CatalogItem{{
+		NmID:           2389212,
+		ChrtID:         9934930,
+		ItemAttributes: domain.ItemAttributes{Name: "Mascara", Brand: "Vivienne Sabo", Size: "0"},
+		Version:        2,
+		CreatedAt:      created,
+		UpdatedAt:      created.Add(time.Hour),
+		Versions: []domain.ItemVersion{
+			{Version: 1, ItemAttributes: domain.ItemAttributes{Name: "Mascaras", Brand: "Vivienne Sabo", Size: "0"}, ValidFrom: created},
+			{Version: 2, ItemAttributes: domain.ItemAttributes{Name: "Mascara", Brand: "Vivienne Sabo", Size: "0"}, OrderUID: orderUID, ValidFrom: created.Add(time.Hour)},
+		},
+		Conflicts: []domain.ItemConflict{{
+			OrderUID: orderUID,
+			Fields:   []string{"name"},
+			Current:  domain.ItemAttributes{Name: "Mascaras", Brand: "Vivienne Sabo", Size: "0"},
+			Incoming: domain.ItemAttributes{Name: "Mascara", Brand: "Vivienne Sabo", Size: "0"},
+			Applied:  true,
+		}},
+	}}}
+	router := newItemRouter(t, repo, &stubListRepo{})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/2389212", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got mapper.CatalogDTO
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got.Items, 1)
+	assert.Equal(t, 2389212, got.NmID)
+	assert.Equal(t, "Mascara", got.Items[0].Name)
+	assert.Equal(t, 2, got.Items[0].Version)
+	assert.Len(t, got.Items[0].Versions, 2)
+	require.Len(t, got.Items[0].Conflicts, 1)
+	assert.Equal(t, []string{"name"}, got.Items[0].Conflicts[0].Fields)
+	assert.Equal(t, orderUID.String(), got.Items[0].Conflicts[0].OrderUID)
+	assert.True(t, got.Items[0].Conflicts[0].Applied)
+}
+
+func TestGetItem_Problems(t *testing.T) {
+	router := newItemRouter(t, &stubItemRepo{}, &stubListRepo{})
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   problem.Code
+	}{
+		{"invalid nm_id", "/items/abc", http.StatusBadRequest, problem.CodeInvalidRequest},
+		{"negative nm_id", "/items/-1", http.StatusBadRequest, problem.CodeInvalidRequest},
+		{"not found", "/items/2389212", http.StatusNotFound, problem.CodeItemNotFound},
+		{"orders of unknown item", "/items/2389212/orders", http.StatusNotFound, problem.CodeItemNotFound},
+		{"invalid page token", "/items/2389212/orders?after=not-a-token", http.StatusBadRequest, problem.CodeInvalidRequest},
+		{"invalid limit", "/items/2389212/orders?limit=0", http.StatusBadRequest, problem.CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, p := doProblem(t, router, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.code, p.Code)
+		})
+	}
+}
+
+func TestGetItemOrders_Pagination(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	orders := &stubListRepo{orders: []*domain.Order{
+		{OrderUID: uuid.New(), DateCreated: created},
+		{OrderUID: uuid.New(), DateCreated: created.Add(time.Minute)},
+	}}
+	router := newItemRouter(t, &stubItemRepo{}, orders)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/2389212/orders?limit=2", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, domain.OrderFilter{NmID: 2389212}, orders.filter)
+
+	var got struct {
+		Items []mapper.OrderFromDomainDTO `json:"items"`
+		Next  string                      `json:"next"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got.Items, 2)
+	assert.Equal(t, orders.orders[0].OrderUID, got.Items[0].OrderUID)
+
+	page, err := mapper.DecodePageToken(got.Next)
+	require.NoError(t, err)
+	assert.Equal(t, orders.orders[1].OrderUID, page.AfterOrderUID)
+}
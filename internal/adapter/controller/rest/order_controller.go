@@ -1,29 +1,50 @@
 package rest
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/export"
+	"github.com/folivorra/get_order/internal/adapter/importer"
 	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/adapter/problem"
 	"github.com/folivorra/get_order/internal/config"
-	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/domain"
 	"github.com/folivorra/get_order/internal/usecase"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// SchemaVersionHeader - заголовок POST /orders с версией формата заказа ("2" или "v2")
+const SchemaVersionHeader = "Order-Schema-Version"
+
 type Controller struct {
-	service *usecase.OrderService
-	logger  *slog.Logger
-	cfg     config.Config
+	service   *usecase.OrderService
+	submitter *usecase.OrderSubmitter
+	importer  *importer.Importer
+	masker    *masking.Masker
+	logger    *slog.Logger
+	cfg       config.Config
 }
 
-func NewController(service *usecase.OrderService, cfg config.Config, logger *slog.Logger) *Controller {
+func NewController(service *usecase.OrderService, submitter *usecase.OrderSubmitter, masker *masking.Masker, cfg config.Config, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: service,
-		logger:  logger,
-		cfg:     cfg,
+		service:   service,
+		submitter: submitter,
+		importer:  importer.NewImporter(logger, service, cfg.ImportBatchSize),
+		masker:    masker,
+		logger:    logger,
+		cfg:       cfg,
 	}
 }
 
@@ -33,28 +54,273 @@ func (c *Controller) GetOrderToUI(w http.ResponseWriter, r *http.Request) {
 
 	uid, err := uuid.Parse(uidV)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidUID, "uid must be a UUID")
+		return
+	}
+
+	encoder, ok := negotiateOrderEncoder(r.Header.Get("Accept"))
+	if !ok {
+		writeProblem(w, r, http.StatusNotAcceptable, problem.CodeNotAcceptable, "supported types: "+supportedOrderTypes())
 		return
 	}
 
 	order, err := c.service.GetOrder(r.Context(), uid)
 	if err != nil {
-		if errors.Is(err, postgres.ErrOrderDoesNotExists) {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	var body bytes.Buffer
+	if err = encoder.encode(&body, c.masker.Order(r.Context(), order)); err != nil {
+		writeServiceError(w, r, c.logger, fmt.Errorf("encode order as %s: %w", encoder.contentType, err))
+		return
+	}
+
+	writeCached(w, r, encoder.header(), body.Bytes(), c.lastModified(order), c.cfg.ServerHTTPOrderCacheMaxAge)
+}
+
+func (c *Controller) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, c.cfg.ServerHTTPOrderMaxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+				fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "failed to read request body")
+		return
+	}
+
+	version, err := usecase.ParseOrderVersion(r.Header.Get(SchemaVersionHeader))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeUnsupportedVersion, err.Error())
+		return
+	}
+
+	orderDTO, err := c.service.DecodeOrder(body, version)
+	switch {
+	case errors.Is(err, usecase.ErrInvalidOrderJSON):
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, err.Error())
+		return
+	case errors.Is(err, usecase.ErrUnsupportedOrderVersion):
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeUnsupportedVersion, err.Error())
+		return
+	case err != nil:
+		writeValidationError(w, r, err)
+		return
+	}
+
+	if err = c.service.ValidateOrder(r.Context(), orderDTO); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	key := r.Header.Get("Idempotency-Key")
+
+	order := mapper.ConvertToDomain(orderDTO)
+	uid, replayed, err := c.submitter.Submit(r.Context(), key, hex.EncodeToString(hash[:]), order)
+
+	if err != nil {
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		c.logger.InfoContext(r.Context(), "order has been saved in db",
+			slog.String("uuid", uid.String()),
+			slog.String("source", "http"),
+		)
+	}
+
+	w.Header().Set("Location", "/order/"+uid.String())
+	writeJSON(w, http.StatusCreated, map[string]string{"order_uid": uid.String()})
+}
+
+func (c *Controller) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if _, err := export.NewWriter(format, io.Discard); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	filter, err := mapper.ConvertFilterToDomain(&mapper.OrderFilterDTO{
+		DateFrom:        query.Get("date_from"),
+		DateTo:          query.Get("date_to"),
+		DeliveryService: query.Get("delivery_service"),
+		Region:          query.Get("region"),
+		Currency:        query.Get("currency"),
+		Phone:           query.Get("phone"),
+		Email:           query.Get("email"),
+	})
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	// выгрузка может идти дольше обычного WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(c.cfg.ServerHTTPExportTimeout))
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"orders.%s\"", format))
+
+	body := &exportBody{w: w}
+	count, err := export.Export(r.Context(), c.exportSource, filter, format, body, func() {
+		// пока ничего не записано, ошибку еще можно вернуть статусом
+		if body.written {
+			_ = rc.Flush()
+		}
+	})
+	if err != nil && !body.written {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Disposition")
+		writeServiceError(w, r, c.logger, fmt.Errorf("export orders: %w", err))
+		return
+	}
+	if err != nil {
+		c.logger.ErrorContext(r.Context(), "failed to export orders",
+			slog.String("format", format),
+			slog.Int("exported", count),
+			slog.String("error", err.Error()),
+		)
+		// ответ уже начат со статусом 200: обрываем соединение, чтобы обрезанный файл не приняли за целый
+		panic(http.ErrAbortHandler)
+	}
+
+	c.logger.InfoContext(r.Context(), "orders exported",
+		slog.String("format", format),
+		slog.Int("exported", count),
+	)
+}
+
+func (c *Controller) ImportOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = importer.FormatJSONL
+	}
+
+	mapping, err := importer.ParseMapping(query.Get("mapping"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "dry_run must be a boolean")
+			return
 		}
+	}
+
+	// загрузка файла может идти дольше обычных таймаутов сервера
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(c.cfg.ServerHTTPImportTimeout))
+	_ = rc.SetWriteDeadline(time.Now().Add(c.cfg.ServerHTTPImportTimeout))
+
+	body := http.MaxBytesReader(w, r.Body, c.cfg.ServerHTTPImportMaxBody)
+
+	reader, err := importer.NewReader(format, body, mapping)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
 		return
 	}
 
-	orderDTO := mapper.ConvertFromDomain(order)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+
+	summary, err := c.importer.Run(r.Context(), reader, dryRun, func(result importer.Result) error {
+		return enc.Encode(result)
+	})
 
-	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(orderDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// статус ответа уже отправлен, поэтому итог и причина остановки идут последней строкой отчета
+	trailer := importTrailer{Summary: summary}
+	if err != nil {
+		p, ok := problem.FromServiceError(err)
+		if !ok {
+			c.logger.ErrorContext(r.Context(), "failed to import orders",
+				slog.String("format", format),
+				slog.String("error", err.Error()),
+			)
+		}
+		trailer.Error = &importError{Code: p.Code, Detail: p.Detail}
+	}
+	_ = enc.Encode(trailer)
+}
+
+// importTrailer - последняя строка отчета импорта: итоги по записям и, если импорт прерван, причина.
+// Записи после прерывания в отчет не попадают и не сохраняются
+type importTrailer struct {
+	Summary importer.Summary `json:"summary"`
+	Error   *importError     `json:"error,omitempty"`
+}
+
+type importError struct {
+	Code   problem.Code `json:"code"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// GetOrderSchema отдает JSON Schema версии входящего заказа, по которой проверяются Kafka и POST /orders
+func (c *Controller) GetOrderSchema(w http.ResponseWriter, r *http.Request) {
+	version, err := usecase.ParseOrderVersion(mux.Vars(r)["version"])
+	var schema []byte
+	if err == nil {
+		schema, err = c.service.OrderSchema(version)
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusNotFound, problem.CodeSchemaNotFound, "order schema version does not exist")
+		return
 	}
+
+	writeCached(w, r, "application/schema+json", schema, time.Time{}, c.cfg.ServerHTTPOrderCacheMaxAge)
+}
+
+// lastModified - заказ не меняется после сохранения, но итоги в валюте отчетности меняются вместе
+// с курсами. Дата создания может быть в будущем, а Last-Modified позже текущего времени недопустим
+func (c *Controller) lastModified(order *domain.Order) time.Time {
+	modified := order.DateCreated
+	if rates := c.service.RatesUpdatedAt(); rates.After(modified) {
+		modified = rates
+	}
+	if now := time.Now(); modified.After(now) {
+		modified = now
+	}
+	return modified
+}
+
+// exportBody запоминает, ушли ли в ответ выгрузки данные
+type exportBody struct {
+	w       io.Writer
+	written bool
+}
+
+func (b *exportBody) Write(p []byte) (int, error) {
+	b.written = b.written || len(p) > 0
+	return b.w.Write(p)
+}
+
+// exportSource отдает заказы для выгрузки, замаскированные по роли клиента
+func (c *Controller) exportSource(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) error {
+	return c.service.ExportOrders(ctx, filter, func(order *domain.Order) error {
+		return fn(c.masker.Order(ctx, order))
+	})
 }
 
 func (c *Controller) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/orders", c.CreateOrder).Methods("POST")
+	r.HandleFunc("/orders/export", c.ExportOrders).Methods("GET")
+	r.HandleFunc("/orders/import", c.ImportOrders).Methods("POST")
 	r.HandleFunc("/order/{uid}", c.GetOrderToUI).Methods("GET")
+	r.HandleFunc("/schemas/order/{version}", c.GetOrderSchema).Methods("GET")
 }
@@ -0,0 +1,441 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	orderv2 "github.com/folivorra/get_order/api/order/v2"
+	"github.com/folivorra/get_order/internal/adapter/cache/inmemory"
+	"github.com/folivorra/get_order/internal/adapter/controller/rest"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// stubRepo отдает на Get заданный заказ или ошибку, остальные методы не используются
+type stubRepo struct {
+	usecase.OrderRepo
+	order  *domain.Order
+	getErr error
+}
+
+func (s *stubRepo) Get(context.Context, uuid.UUID) (*domain.Order, error) {
+	return s.order, s.getErr
+}
+
+// streamRepo отдает в Stream заданные заказы, затем ошибку err
+type streamRepo struct {
+	usecase.OrderRepo
+	orders []*domain.Order
+	err    error
+}
+
+func (s *streamRepo) Stream(_ context.Context, _ domain.OrderFilter, fn func(order *domain.Order) error) error {
+	for _, order := range s.orders {
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func newOrderRouter(t *testing.T, repo usecase.OrderRepo) *mux.Router {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.NewConfig(logger)
+	service := usecase.NewOrderService(logger, cfg, repo, inmemory.NewInMemOrderCache(logger, 10), nil, nil, nil)
+	controller := rest.NewController(service, nil, nil, cfg, logger)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequestIDMiddleware())
+	controller.RegisterRoutes(router)
+
+	return router
+}
+
+func doProblem(t *testing.T, router http.Handler, req *http.Request) (*httptest.ResponseRecorder, problem.Problem) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	return rec, p
+}
+
+func TestGetOrder_Problems(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		err    error
+		status int
+		code   problem.Code
+	}{
+		{"invalid uid", "/order/not-a-uuid", nil, http.StatusBadRequest, problem.CodeInvalidUID},
+		{"not found", "/order/" + uuid.NewString(), postgres.ErrOrderDoesNotExists, http.StatusNotFound, problem.CodeOrderNotFound},
+		{"timeout", "/order/" + uuid.NewString(), context.DeadlineExceeded, http.StatusGatewayTimeout, problem.CodeTimeout},
+		{"unavailable", "/order/" + uuid.NewString(),
+			errors.Join(postgres.ErrMaxRetryAttemptsExceeded, errors.New("dial tcp: connection refused")),
+			http.StatusServiceUnavailable, problem.CodeUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newOrderRouter(t, &stubRepo{getErr: tt.err})
+
+			rec, p := doProblem(t, router, httptest.NewRequest("GET", tt.target, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.NotEmpty(t, p.RequestID)
+			assert.Equal(t, rec.Header().Get(problem.RequestIDHeader), p.RequestID)
+		})
+	}
+}
+
+func TestGetOrder_InternalErrorIsHidden(t *testing.T) {
+	router := newOrderRouter(t, &stubRepo{getErr: errors.New(`pq: relation "orders" does not exist`)})
+
+	req := httptest.NewRequest("GET", "/order/"+uuid.NewString(), nil)
+	req.Header.Set(problem.RequestIDHeader, "trace-42")
+
+	rec, p := doProblem(t, router, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, problem.CodeInternal, p.Code)
+	assert.Empty(t, p.Detail)
+	assert.Equal(t, "trace-42", p.RequestID)
+	assert.NotContains(t, rec.Body.String(), "relation")
+}
+
+func TestCreateOrder_InvalidJSON(t *testing.T) {
+	router := newOrderRouter(t, &stubRepo{})
+
+	rec, p := doProblem(t, router, httptest.NewRequest("POST", "/orders", strings.NewReader("{")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, problem.CodeInvalidJSON, p.Code)
+}
+
+func TestCreateOrder_SchemaViolation(t *testing.T) {
+	router := newOrderRouter(t, &stubRepo{})
+
+	rec, p := doProblem(t, router, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"order_uid": 1}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, problem.CodeValidationFailed, p.Code)
+	require.NotEmpty(t, p.Errors)
+	assert.Contains(t, p.Errors, problem.FieldError{Field: "order_uid", Message: "expected string, but got number"})
+}
+
+func TestGetOrderSchema(t *testing.T) {
+	router := newOrderRouter(t, &stubRepo{})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schemas/order/v1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/schema+json", rec.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+	assert.JSONEq(t, string(orderv1.OrderJSONSchema), rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schemas/order/v2", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, string(orderv2.OrderJSONSchema), rec.Body.String())
+
+	rec, p := doProblem(t, router, httptest.NewRequest("GET", "/schemas/order/v9", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, problem.CodeSchemaNotFound, p.Code)
+}
+
+func TestCreateOrder_SchemaVersionHeader(t *testing.T) {
+	router := newOrderRouter(t, &stubRepo{})
+
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader("{}"))
+	req.Header.Set(rest.SchemaVersionHeader, "v9")
+	rec, p := doProblem(t, router, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, problem.CodeUnsupportedVersion, p.Code)
+
+	// в v2 quantity обязательно
+	req = httptest.NewRequest("POST", "/orders", strings.NewReader(`{"items": [{}]}`))
+	req.Header.Set(rest.SchemaVersionHeader, "2")
+	rec, p = doProblem(t, router, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, p.Errors, problem.FieldError{Field: "items[0].quantity", Message: "is required"})
+}
+
+func TestGetOrder_ConditionalGet(t *testing.T) {
+	uid := uuid.New()
+	router := newOrderRouter(t, &stubRepo{order: &domain.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/order/"+uid.String(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.NotContains(t, etag, "W/")
+	assert.Equal(t, "Fri, 26 Nov 2021 06:22:19 GMT", rec.Header().Get("Last-Modified"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "private")
+
+	req := httptest.NewRequest("GET", "/order/"+uid.String(), nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	req = httptest.NewRequest("GET", "/order/"+uid.String(), nil)
+	req.Header.Set("If-Modified-Since", "Sat, 27 Nov 2021 00:00:00 GMT")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	req = httptest.NewRequest("GET", "/order/"+uid.String(), nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	req.Header.Set("If-Modified-Since", "Sat, 27 Nov 2021 00:00:00 GMT")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestGetOrder_LastModified(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ratesUpdated := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	converter, err := usecase.NewCurrencyConverter("RUB", usecase.ExchangeRates{UpdatedAt: ratesUpdated})
+	require.NoError(t, err)
+
+	lastModified := func(dateCreated time.Time) string {
+		uid := uuid.New()
+		repo := &stubRepo{order: &domain.Order{OrderUID: uid, DateCreated: dateCreated}}
+		service := usecase.NewOrderService(logger, config.NewConfig(logger), repo, inmemory.NewInMemOrderCache(logger, 10), nil, nil, converter)
+		router := mux.NewRouter()
+		rest.NewController(service, nil, nil, config.NewConfig(logger), logger).RegisterRoutes(router)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/order/"+uid.String(), nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Header().Get("Last-Modified")
+	}
+
+	// итоги пересчитаны по курсам, которые новее заказа
+	assert.Equal(t, ratesUpdated.Format(http.TimeFormat), lastModified(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)))
+
+	// заказ из будущего не дает Last-Modified позже текущего времени
+	modified, err := http.ParseTime(lastModified(time.Now().Add(24 * time.Hour)))
+	require.NoError(t, err)
+	assert.False(t, modified.After(time.Now()))
+}
+
+func TestGetOrder_ContentNegotiation(t *testing.T) {
+	uid := uuid.New()
+	router := newOrderRouter(t, &stubRepo{order: &domain.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Items:       []domain.OrderItem{{Item: &domain.Item{Name: "Mascaras"}, Quantity: 1}},
+	}})
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/order/"+uid.String(), nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("application/xml")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	var xmlOrder mapper.OrderFromDomainDTO
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &xmlOrder))
+	assert.Equal(t, "WBILMTESTTRACK", xmlOrder.TrackNumber)
+	require.Len(t, xmlOrder.Items, 1)
+	assert.Equal(t, "Mascaras", xmlOrder.Items[0].Name)
+
+	rec = get("application/x-protobuf")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var pbOrder orderv1.Order
+	require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &pbOrder))
+	assert.Equal(t, "WBILMTESTTRACK", pbOrder.GetTrackNumber())
+
+	rec = get("application/msgpack")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var packed map[string]any
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &packed))
+	assert.Equal(t, "WBILMTESTTRACK", packed["track_number"])
+
+	// браузер при переходе по ссылке получает печатную форму
+	rec = get("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "WBILMTESTTRACK")
+
+	rec = get("*/*")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	// у разных форматов разные ETag
+	assert.NotEqual(t, get("application/json").Header().Get("ETag"), get("application/xml").Header().Get("ETag"))
+
+	rec, p := doProblem(t, router, func() *http.Request {
+		req := httptest.NewRequest("GET", "/order/"+uid.String(), nil)
+		req.Header.Set("Accept", "image/png, application/json;q=0")
+		return req
+	}())
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Equal(t, problem.CodeNotAcceptable, p.Code)
+}
+
+func TestGetOrder_Totals(t *testing.T) {
+	uid := uuid.New()
+	router := newOrderRouter(t, &stubRepo{order: &domain.Order{
+		OrderUID:    uid,
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Payment: domain.Payment{
+			Currency:     "USD",
+			Amount:       domain.NewMoney(1817, "USD"),
+			GoodsTotal:   domain.NewMoney(317, "USD"),
+			DeliveryCost: domain.NewMoney(1500, "USD"),
+		},
+	}})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/order/"+uid.String(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var order mapper.OrderFromDomainDTO
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&order))
+	assert.Equal(t, 1817, order.Payment.Amount)
+	assert.Equal(t, mapper.TotalsFromDomainDTO{
+		Currency:     "USD",
+		Amount:       "18.17",
+		GoodsTotal:   "3.17",
+		DeliveryCost: "15.00",
+		CustomFee:    "0.00",
+	}, order.Totals.Original)
+	// без курсов итогов в валюте отчетности нет
+	assert.Nil(t, order.Totals.Reporting)
+}
+
+// stubImportRepo отдает на SaveBatch заданные результаты
+type stubImportRepo struct {
+	usecase.OrderRepo
+	errs []error
+	err  error
+}
+
+func (s *stubImportRepo) SaveBatch(context.Context, []*domain.Order) ([]error, error) {
+	return s.errs, s.err
+}
+
+func importReport(t *testing.T, repo usecase.OrderRepo, target string) ([]map[string]any, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.NewConfig(logger)
+	validator, err := usecase.NewOrderValidator(usecase.DefaultRuleSets())
+	require.NoError(t, err)
+	service := usecase.NewOrderService(logger, cfg, repo, inmemory.NewInMemOrderCache(logger, 10), nil, validator, nil)
+
+	router := mux.NewRouter()
+	rest.NewController(service, nil, nil, cfg, logger).RegisterRoutes(router)
+
+	raw, err := os.ReadFile("../../../usecase/testdata/order_v1.json")
+	require.NoError(t, err)
+	var line bytes.Buffer
+	require.NoError(t, json.Compact(&line, raw))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", target, &line))
+
+	var lines []map[string]any
+	dec := json.NewDecoder(rec.Body)
+	for dec.More() {
+		var v map[string]any
+		require.NoError(t, dec.Decode(&v))
+		lines = append(lines, v)
+	}
+	return lines, rec
+}
+
+func TestImportOrders_Report(t *testing.T) {
+	lines, rec := importReport(t, &stubImportRepo{errs: []error{errors.New(`pq: deadlock detected`)}}, "/orders/import")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, lines, 2)
+
+	// текст неизвестной ошибки сохранения не раскрывается
+	assert.Equal(t, "rejected", lines[0]["status"])
+	assert.Equal(t, string(problem.CodeInternal), lines[0]["code"])
+	assert.NotContains(t, lines[0]["error"], "deadlock")
+
+	assert.Equal(t, map[string]any{"accepted": 0.0, "duplicate": 0.0, "rejected": 1.0, "dry_run": false}, lines[1]["summary"])
+	assert.NotContains(t, lines[1], "error")
+}
+
+func TestImportOrders_InterruptedReportEndsWithError(t *testing.T) {
+	repo := &stubImportRepo{err: errors.Join(postgres.ErrMaxRetryAttemptsExceeded, errors.New("dial tcp: connection refused"))}
+
+	lines, rec := importReport(t, repo, "/orders/import")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]any{"code": string(problem.CodeUnavailable), "detail": "storage is temporarily unavailable"}, lines[0]["error"])
+}
+
+func TestImportOrders_InvalidDryRun(t *testing.T) {
+	router := newOrderRouter(t, &stubRepo{})
+
+	rec, p := doProblem(t, router, httptest.NewRequest("POST", "/orders/import?dry_run=maybe", strings.NewReader("")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, problem.CodeInvalidRequest, p.Code)
+}
+
+func TestExportOrders_FailsBeforeFirstRow(t *testing.T) {
+	router := newOrderRouter(t, &streamRepo{err: fmt.Errorf("%w: %w", postgres.ErrMaxRetryAttemptsExceeded, errors.New("connection refused"))})
+
+	rec, p := doProblem(t, router, httptest.NewRequest("GET", "/orders/export?format=jsonl", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, problem.CodeUnavailable, p.Code)
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+}
+
+func TestExportOrders_AbortsTruncatedExport(t *testing.T) {
+	router := newOrderRouter(t, &streamRepo{
+		orders: []*domain.Order{{OrderUID: uuid.New(), Items: []domain.OrderItem{{Item: &domain.Item{}}}}},
+		err:    errors.New("cursor closed"),
+	})
+
+	rec := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/orders/export?format=jsonl", nil))
+	})
+	assert.NotEmpty(t, rec.Body.String())
+}
@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// QuarantineController - ручной разбор заказов, не прошедших проверки в консьюмере
+type QuarantineController struct {
+	quarantine *usecase.QuarantineService
+	logger     *slog.Logger
+	cfg        config.Config
+}
+
+func NewQuarantineController(quarantine *usecase.QuarantineService, cfg config.Config, logger *slog.Logger) *QuarantineController {
+	return &QuarantineController{
+		quarantine: quarantine,
+		logger:     logger,
+		cfg:        cfg,
+	}
+}
+
+type quarantineListResponse struct {
+	Items []mapper.QuarantinedOrderDTO `json:"items"`
+	Next  string                       `json:"next,omitempty"`
+}
+
+// reviewRequest - тело approve и reject. Order - исправленный заказ, без него одобряется исходное сообщение
+type reviewRequest struct {
+	Order json.RawMessage `json:"order"`
+	Note  string          `json:"note"`
+}
+
+func (c *QuarantineController) ListQuarantined(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := domain.QuarantinePage{
+		Limit:  c.cfg.QuarantineMaxPageSize,
+		Status: domain.QuarantineStatus(query.Get("status")),
+	}
+
+	switch page.Status {
+	case "", domain.QuarantinePending, domain.QuarantineApproved, domain.QuarantineRejected:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "status must be pending, approved or rejected")
+		return
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "limit must be a positive integer")
+			return
+		}
+		page.Limit = min(limit, c.cfg.QuarantineMaxPageSize)
+	}
+
+	if v := query.Get("after"); v != "" {
+		after, err := uuid.Parse(v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidUID, "after must be a UUID")
+			return
+		}
+		page.AfterID = after
+	}
+
+	entries, err := c.quarantine.List(r.Context(), page)
+	if err != nil {
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	resp := quarantineListResponse{Items: make([]mapper.QuarantinedOrderDTO, len(entries))}
+	for i, entry := range entries {
+		resp.Items[i] = mapper.ConvertQuarantinedOrder(entry, false)
+	}
+	if len(entries) == page.Limit {
+		resp.Next = entries[len(entries)-1].ID.String()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (c *QuarantineController) GetQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.parseID(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := c.quarantine.Get(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mapper.ConvertQuarantinedOrder(entry, true))
+}
+
+func (c *QuarantineController) ApproveQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.parseID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := c.decodeReview(w, r)
+	if !ok {
+		return
+	}
+
+	var payload []byte
+	if len(req.Order) > 0 && string(req.Order) != "null" {
+		payload = req.Order
+	}
+
+	uid, err := c.quarantine.Approve(r.Context(), id, payload, reviewer(r), req.Note)
+	if err != nil {
+		c.writeReviewError(w, r, err)
+		return
+	}
+
+	c.logger.InfoContext(r.Context(), "order has been saved in db",
+		slog.String("uuid", uid.String()),
+		slog.String("source", "quarantine"),
+	)
+
+	w.Header().Set("Location", "/order/"+uid.String())
+	writeJSON(w, http.StatusCreated, map[string]string{"order_uid": uid.String()})
+}
+
+func (c *QuarantineController) RejectQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.parseID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := c.decodeReview(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.quarantine.Reject(r.Context(), id, reviewer(r), req.Note); err != nil {
+		c.writeReviewError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *QuarantineController) parseID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidUID, "id must be a UUID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// decodeReview читает тело approve и reject, пустое тело допустимо
+func (c *QuarantineController) decodeReview(w http.ResponseWriter, r *http.Request) (reviewRequest, bool) {
+	var req reviewRequest
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, c.cfg.ServerHTTPOrderMaxBody)).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+		return req, true
+	case errors.As(err, &tooLarge):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+	default:
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidJSON, err.Error())
+	}
+
+	return req, false
+}
+
+func (c *QuarantineController) writeReviewError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case usecase.ValidationViolations(err) != nil:
+		writeValidationError(w, r, err)
+	case errors.Is(err, usecase.ErrQuarantinePayload):
+		writeProblem(w, r, http.StatusUnprocessableEntity, problem.CodeValidationFailed, err.Error())
+	default:
+		writeServiceError(w, r, c.logger, err)
+	}
+}
+
+// reviewer - кто разобрал запись, без аутентификации - anonymous
+func reviewer(r *http.Request) string {
+	if p := middleware.PrincipalFromContext(r.Context()); p != nil {
+		return p.Subject
+	}
+	return string(middleware.RoleAnonymous)
+}
+
+func (c *QuarantineController) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/quarantine", c.ListQuarantined).Methods("GET")
+	r.HandleFunc("/quarantine/{id}", c.GetQuarantined).Methods("GET")
+	r.HandleFunc("/quarantine/{id}/approve", c.ApproveQuarantined).Methods("POST")
+	r.HandleFunc("/quarantine/{id}/reject", c.RejectQuarantined).Methods("POST")
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/usecase"
+	"log/slog"
+	"net/http"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code problem.Code, detail string) {
+	problem.Write(w, r, problem.New(status, code, detail))
+}
+
+// writeValidationError перечисляет все нарушения, расхождение сумм отдается отдельным кодом
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problem.New(http.StatusUnprocessableEntity, problem.CodeValidationFailed, "order validation failed")
+	if errors.Is(err, usecase.ErrOrderQuarantined) {
+		p = problem.New(http.StatusUnprocessableEntity, problem.CodeReconciliationFailed, "order amounts do not reconcile")
+	}
+	for _, violation := range usecase.ValidationViolations(err) {
+		p.WithErrors(problem.FieldError{Field: violation.Path, Message: violation.Message})
+	}
+	problem.Write(w, r, p)
+}
+
+// writeServiceError переводит ошибку сервиса в problem+json. Неизвестные ошибки клиенту не раскрываются,
+// причина пишется в лог, request_id к записи добавляет middleware.ContextHandler
+func writeServiceError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	p, ok := problem.FromServiceError(err)
+	if !ok {
+		logger.ErrorContext(r.Context(), "internal error",
+			slog.String("url", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
+	}
+	problem.Write(w, r, p)
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// StatsController - статистика заказов и выручки
+type StatsController struct {
+	stats  *usecase.StatsService
+	logger *slog.Logger
+	cfg    config.Config
+}
+
+func NewStatsController(stats *usecase.StatsService, cfg config.Config, logger *slog.Logger) *StatsController {
+	return &StatsController{
+		stats:  stats,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// GetOrderStats - GET /stats/orders?bucket=day&group_by=delivery_service,region&from=...&to=..., даты в RFC3339
+func (c *StatsController) GetOrderStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	statsQuery := domain.OrderStatsQuery{Bucket: domain.StatsDay}
+
+	var err error
+	if v := query.Get("bucket"); v != "" {
+		if statsQuery.Bucket, err = domain.ParseStatsBucket(v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	if statsQuery.GroupBy, err = domain.ParseStatsDimensions(query.Get("group_by")); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"from", &statsQuery.From},
+		{"to", &statsQuery.To},
+	} {
+		v := query.Get(bound.name)
+		if v == "" {
+			continue
+		}
+		if *bound.dst, err = time.Parse(time.RFC3339, v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, bound.name+" must be an RFC3339 date")
+			return
+		}
+	}
+
+	report, err := c.stats.OrderStats(r.Context(), statsQuery)
+	if err != nil {
+		writeServiceError(w, r, c.logger, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mapper.ConvertOrderStats(report.Query, report.Rollup, report.Rows))
+}
+
+func (c *StatsController) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/stats/orders", c.GetOrderStats).Methods("GET")
+}
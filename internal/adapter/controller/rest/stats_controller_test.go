@@ -0,0 +1,98 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/adapter/controller/rest"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStatsRepo отдает заданные строки и запоминает запрос
+type stubStatsRepo struct {
+	usecase.StatsRepo
+	rows  []domain.OrderStatsRow
+	query domain.OrderStatsQuery
+}
+
+func (s *stubStatsRepo) OrderStats(_ context.Context, query domain.OrderStatsQuery) ([]domain.OrderStatsRow, error) {
+	s.query = query
+	return s.rows, nil
+}
+
+func newStatsRouter(t *testing.T, repo usecase.StatsRepo) *mux.Router {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.NewConfig(logger)
+	controller := rest.NewStatsController(usecase.NewStatsService(logger, cfg, repo, nil), cfg, logger)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequestIDMiddleware())
+	controller.RegisterRoutes(router)
+
+	return router
+}
+
+func TestGetOrderStats(t *testing.T) {
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	repo := &stubStatsRepo{rows: []domain.OrderStatsRow{
+		{Bucket: day, Region: "", Brand: "Vivienne Sabo", Currency: "USD", Orders: 2, Items: 3, Revenue: domain.NewMoney(1817, "USD")},
+	}}
+	router := newStatsRouter(t, repo)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/stats/orders?bucket=week&group_by=region,brand&from=2026-10-20T00:00:00Z&to=2026-10-21T00:00:00Z", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, domain.OrderStatsQuery{
+		Bucket:  domain.StatsWeek,
+		GroupBy: []domain.StatsDimension{domain.StatsByRegion, domain.StatsByBrand},
+		From:    day,
+		To:      day.Add(7 * 24 * time.Hour),
+	}, repo.query)
+
+	var got mapper.OrderStatsDTO
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, "live", got.Source)
+	assert.Equal(t, "2026-10-19T00:00:00Z", got.From)
+	require.Len(t, got.Rows, 1)
+	assert.Equal(t, "18.17", got.Rows[0].Revenue)
+	// пустой регион - тоже значение группировки, а службы доставки в группировке нет
+	require.NotNil(t, got.Rows[0].Region)
+	assert.Equal(t, "", *got.Rows[0].Region)
+	assert.Nil(t, got.Rows[0].DeliveryService)
+}
+
+func TestGetOrderStats_Problems(t *testing.T) {
+	router := newStatsRouter(t, &stubStatsRepo{})
+
+	for _, target := range []string{
+		"/stats/orders?bucket=month",
+		"/stats/orders?group_by=customer_id",
+		"/stats/orders?from=yesterday",
+		"/stats/orders?from=2026-10-20T00:00:00Z&to=2026-10-01T00:00:00Z",
+		"/stats/orders?bucket=hour&from=2020-01-01T00:00:00Z&to=2026-01-01T00:00:00Z",
+	} {
+		t.Run(target, func(t *testing.T) {
+			rec, p := doProblem(t, router, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, problem.CodeInvalidRequest, p.Code)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package export
+
+import (
+	"context"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/domain"
+	"io"
+)
+
+// Source - источник заказов для выгрузки, его реализуют usecase.OrderService и postgres.PgOrderRepo
+type Source func(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) error
+
+// Export пишет заказы из src в w в заданном формате, после каждого заказа вызывается flush (если задан)
+func Export(ctx context.Context, src Source, filter domain.OrderFilter, format string, w io.Writer, flush func()) (int, error) {
+	writer, err := NewWriter(format, w)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = src(ctx, filter, func(order *domain.Order) error {
+		if err := writer.Write(mapper.ConvertToExportRows(order)); err != nil {
+			return err
+		}
+		count++
+
+		if flush != nil {
+			flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.Close()
+}
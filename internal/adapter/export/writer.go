@@ -0,0 +1,129 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/parquet-go/parquet-go"
+	"io"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+
+	// parquetRowGroupSize - сколько строк держим в памяти до сброса row group
+	parquetRowGroupSize = 10000
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+)
+
+type Writer interface {
+	Write(rows []mapper.OrderExportRow) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return &parquetWriter{w: parquet.NewGenericWriter[mapper.OrderExportRow](w)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(rows []mapper.OrderExportRow) error {
+	if !c.headerWritten {
+		if err := c.w.Write(mapper.OrderExportHeader); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	for i := range rows {
+		if err := c.w.Write(rows[i].Record()); err != nil {
+			return err
+		}
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if !c.headerWritten {
+		if err := c.w.Write(mapper.OrderExportHeader); err != nil {
+			return err
+		}
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(rows []mapper.OrderExportRow) error {
+	for i := range rows {
+		if err := j.enc.Encode(&rows[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+type parquetWriter struct {
+	w        *parquet.GenericWriter[mapper.OrderExportRow]
+	buffered int
+}
+
+func (p *parquetWriter) Write(rows []mapper.OrderExportRow) error {
+	if _, err := p.w.Write(rows); err != nil {
+		return err
+	}
+
+	p.buffered += len(rows)
+	if p.buffered >= parquetRowGroupSize {
+		p.buffered = 0
+		return p.w.Flush()
+	}
+
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
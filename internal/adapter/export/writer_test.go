@@ -0,0 +1,75 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/adapter/export"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSource(orders ...*domain.Order) export.Source {
+	return func(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) error {
+		for _, order := range orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func newExportOrder() *domain.Order {
+	return &domain.Order{
+		OrderUID:        uuid.New(),
+		TrackNumber:     "TRACK123",
+		DeliveryService: "meest",
+		Delivery:        domain.Delivery{Name: "A", Region: "B"},
+		Payment:         domain.Payment{Currency: "USD", Amount: domain.NewMoney(30, "USD")},
+		Items: []domain.OrderItem{
+			{Price: domain.NewMoney(10, "USD"), TotalPrice: domain.NewMoney(10, "USD"), Quantity: 1, Item: &domain.Item{Name: "first"}},
+			{Price: domain.NewMoney(20, "USD"), TotalPrice: domain.NewMoney(20, "USD"), Quantity: 1, Item: &domain.Item{Name: "second"}},
+		},
+	}
+}
+
+func TestExport_CSVOneRowPerItem(t *testing.T) {
+	var buf bytes.Buffer
+
+	count, err := export.Export(context.Background(), testSource(newExportOrder()), domain.OrderFilter{}, export.FormatCSV, &buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "order_uid", records[0][0])
+	assert.Equal(t, "first", records[1][15])
+	assert.Equal(t, "second", records[2][15])
+}
+
+func TestExport_JSONL(t *testing.T) {
+	var buf bytes.Buffer
+
+	_, err := export.Export(context.Background(), testSource(newExportOrder(), newExportOrder()), domain.OrderFilter{}, export.FormatJSONL, &buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, bytes.Count(buf.Bytes(), []byte("\n")))
+}
+
+func TestExport_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+
+	_, err := export.Export(context.Background(), testSource(newExportOrder()), domain.OrderFilter{}, export.FormatParquet, &buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("PAR1"), buf.Bytes()[:4])
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	_, err := export.Export(context.Background(), testSource(), domain.OrderFilter{}, "xlsx", &bytes.Buffer{}, nil)
+	assert.ErrorIs(t, err, export.ErrUnknownFormat)
+}
@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+)
+
+type Status string
+
+const (
+	StatusAccepted  Status = "accepted"
+	StatusDuplicate Status = "duplicate"
+	StatusRejected  Status = "rejected"
+)
+
+// Result - строка отчета по одной записи входного файла. Code - стабильный код причины отказа,
+// те же коды, что в ответах problem+json
+type Result struct {
+	Line     int          `json:"line"`
+	OrderUID string       `json:"order_uid,omitempty"`
+	Status   Status       `json:"status"`
+	Code     problem.Code `json:"code,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type Summary struct {
+	Accepted  int  `json:"accepted"`
+	Duplicate int  `json:"duplicate"`
+	Rejected  int  `json:"rejected"`
+	DryRun    bool `json:"dry_run"`
+}
+
+type Importer struct {
+	logger    *slog.Logger
+	srv       *usecase.OrderService
+	batchSize int
+}
+
+func NewImporter(logger *slog.Logger, srv *usecase.OrderService, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	return &Importer{
+		logger:    logger,
+		srv:       srv,
+		batchSize: batchSize,
+	}
+}
+
+// Run читает записи из reader, валидирует их и пишет пачками через OrderService.
+// report вызывается для каждой записи в порядке следования в файле.
+// В режиме dryRun заказы только проверяются, в базу ничего не пишется
+func (im *Importer) Run(ctx context.Context, reader Reader, dryRun bool, report func(Result) error) (Summary, error) {
+	summary := Summary{DryRun: dryRun}
+	seen := make(map[uuid.UUID]struct{})
+
+	var (
+		results []Result
+		batch   []*domain.Order
+		indexes []int
+	)
+
+	flush := func() error {
+		if len(batch) > 0 {
+			errs, err := im.srv.ImportOrders(ctx, batch)
+			if err != nil {
+				return err
+			}
+
+			for i, saveErr := range errs {
+				result := &results[indexes[i]]
+				switch {
+				case errors.Is(saveErr, postgres.ErrOrderAlreadyExists):
+					result.Status = StatusDuplicate
+				case saveErr != nil:
+					im.rejectServiceError(ctx, result, saveErr)
+				}
+			}
+		}
+
+		for _, result := range results {
+			switch result.Status {
+			case StatusAccepted:
+				summary.Accepted++
+			case StatusDuplicate:
+				summary.Duplicate++
+			case StatusRejected:
+				summary.Rejected++
+			}
+
+			if err := report(result); err != nil {
+				return err
+			}
+		}
+
+		results, batch, indexes = results[:0], batch[:0], indexes[:0]
+
+		return nil
+	}
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		result := im.check(ctx, record, seen, dryRun)
+		results = append(results, result)
+
+		if result.Status == StatusAccepted && !dryRun {
+			batch = append(batch, mapper.ConvertToDomain(record.Order))
+			indexes = append(indexes, len(results)-1)
+		}
+
+		if len(results) >= im.batchSize {
+			if err = flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return summary, err
+	}
+
+	im.logger.InfoContext(ctx, "orders import finished",
+		slog.Bool("dry_run", dryRun),
+		slog.Int("accepted", summary.Accepted),
+		slog.Int("duplicate", summary.Duplicate),
+		slog.Int("rejected", summary.Rejected),
+	)
+
+	return summary, nil
+}
+
+func (im *Importer) check(ctx context.Context, record *Record, seen map[uuid.UUID]struct{}, dryRun bool) Result {
+	result := Result{Line: record.Line, Status: StatusAccepted}
+
+	if record.Err != nil {
+		result.Status = StatusRejected
+		result.Code = problem.CodeInvalidRequest
+		result.Error = record.Err.Error()
+		return result
+	}
+
+	result.OrderUID = record.Order.OrderUID.String()
+
+	if err := im.srv.ValidateOrder(ctx, record.Order); err != nil {
+		result.Status = StatusRejected
+		result.Code = problem.CodeValidationFailed
+		if errors.Is(err, usecase.ErrOrderQuarantined) {
+			result.Code = problem.CodeReconciliationFailed
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	if _, ok := seen[record.Order.OrderUID]; ok {
+		result.Status = StatusDuplicate
+		return result
+	}
+	seen[record.Order.OrderUID] = struct{}{}
+
+	if dryRun {
+		exists, err := im.srv.OrderExists(ctx, record.Order.OrderUID)
+		switch {
+		case err != nil:
+			im.rejectServiceError(ctx, &result, err)
+		case exists:
+			result.Status = StatusDuplicate
+		}
+	}
+
+	return result
+}
+
+// rejectServiceError отклоняет запись с кодом ошибки сервиса. Текст неизвестных ошибок в отчет не попадает,
+// он пишется в лог вместе со строкой файла
+func (im *Importer) rejectServiceError(ctx context.Context, result *Result, err error) {
+	p, ok := problem.FromServiceError(err)
+	if !ok {
+		im.logger.ErrorContext(ctx, "failed to import order",
+			slog.Int("line", result.Line),
+			slog.String("uuid", result.OrderUID),
+			slog.String("error", err.Error()),
+		)
+		p.Detail = "internal error"
+	}
+
+	result.Status = StatusRejected
+	result.Code = p.Code
+	result.Error = p.Detail
+}
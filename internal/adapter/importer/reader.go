@@ -0,0 +1,466 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/google/uuid"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+
+	maxLineSize = 4 * 1024 * 1024
+)
+
+var (
+	ErrUnknownFormat  = errors.New("unknown import format")
+	ErrUnknownField   = errors.New("unknown field in column mapping")
+	ErrColumnNotFound = errors.New("mapped column not found in csv header")
+	ErrInvalidMapping = errors.New("column mapping must look like field=column,field=column")
+)
+
+// Record - одна запись входного файла, Err заполняется, если запись не удалось разобрать
+type Record struct {
+	Line  int
+	Order *mapper.OrderIntoDomainDTO
+	Err   error
+}
+
+// Reader отдает записи по одной, в конце файла возвращает io.EOF
+type Reader interface {
+	Next() (*Record, error)
+}
+
+func NewReader(format string, r io.Reader, mapping map[string]string) (Reader, error) {
+	switch format {
+	case FormatJSONL:
+		return NewJSONLReader(r), nil
+	case FormatCSV:
+		return NewCSVReader(r, mapping)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewJSONLReader(r io.Reader) Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &jsonlReader{scanner: scanner}
+}
+
+func (j *jsonlReader) Next() (*Record, error) {
+	for j.scanner.Scan() {
+		j.line++
+
+		line := strings.TrimSpace(j.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var orderDTO mapper.OrderIntoDomainDTO
+		if err := json.Unmarshal([]byte(line), &orderDTO); err != nil {
+			return &Record{Line: j.line, Err: err}, nil
+		}
+
+		return &Record{Line: j.line, Order: &orderDTO}, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+type fieldSetter func(order *mapper.OrderIntoDomainDTO, item *mapper.ItemIntoDomainDTO, value string) error
+
+// orderFields - поля заказа, заполняются из первой строки заказа
+var orderFields = map[string]fieldSetter{
+	"order_uid": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.OrderUID, err = parseUUID(v)
+		return err
+	},
+	"track_number": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.TrackNumber = v
+		return nil
+	},
+	"entry": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Entry = v
+		return nil
+	},
+	"locale": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Locale = v
+		return nil
+	},
+	"internal_signature": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.InternalSignature = v
+		return nil
+	},
+	"customer_id": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.CustomerID = v
+		return nil
+	},
+	"delivery_service": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.DeliveryService = v
+		return nil
+	},
+	"shardkey": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Shardkey = v
+		return nil
+	},
+	"sm_id": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.SmID, err = parseInt(v)
+		return err
+	},
+	"date_created": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.DateCreated, err = mapper.ParseTimestamp(v)
+		return err
+	},
+	"oof_shard": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.OofShard = v
+		return nil
+	},
+
+	"delivery_name": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.Name = v
+		return nil
+	},
+	"delivery_phone": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.Phone = v
+		return nil
+	},
+	"delivery_zip": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.Zip = v
+		return nil
+	},
+	"delivery_city": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.City = v
+		return nil
+	},
+	"delivery_address": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.Address = v
+		return nil
+	},
+	"delivery_region": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.Region = v
+		return nil
+	},
+	"delivery_email": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Delivery.Email = v
+		return nil
+	},
+
+	"payment_transaction": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Payment.Transaction = v
+		return nil
+	},
+	"payment_request_id": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Payment.RequestID = v
+		return nil
+	},
+	"payment_currency": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Payment.Currency = v
+		return nil
+	},
+	"payment_provider": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Payment.Provider = v
+		return nil
+	},
+	"payment_amount": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.Payment.Amount, err = parseInt(v)
+		return err
+	},
+	"payment_dt": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.Payment.PaymentDT, err = mapper.ParseTimestamp(v)
+		return err
+	},
+	"payment_bank": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) error {
+		o.Payment.Bank = v
+		return nil
+	},
+	"payment_delivery_cost": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.Payment.DeliveryCost, err = parseInt(v)
+		return err
+	},
+	"payment_goods_total": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.Payment.GoodsTotal, err = parseInt(v)
+		return err
+	},
+	"payment_custom_fee": func(o *mapper.OrderIntoDomainDTO, _ *mapper.ItemIntoDomainDTO, v string) (err error) {
+		o.Payment.CustomFee, err = parseInt(v)
+		return err
+	},
+}
+
+// itemFields - поля позиции, каждая строка CSV дает одну позицию заказа
+var itemFields = map[string]fieldSetter{
+	"item_uid": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.ItemUID, err = parseUUID(v)
+		return err
+	},
+	"item_chrt_id": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.ChrtID, err = parseInt(v)
+		return err
+	},
+	"item_track_number": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) error {
+		i.TrackNumber = v
+		return nil
+	},
+	"item_price": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.Price, err = parseInt(v)
+		return err
+	},
+	"item_rid": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) error { i.Rid = v; return nil },
+	"item_name": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) error {
+		i.Name = v
+		return nil
+	},
+	"item_sale": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.Sale, err = parseInt(v)
+		return err
+	},
+	"item_size": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) error {
+		i.Size = v
+		return nil
+	},
+	"item_total_price": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.TotalPrice, err = parseInt(v)
+		return err
+	},
+	"item_nm_id": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.NmID, err = parseInt(v)
+		return err
+	},
+	"item_brand": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) error {
+		i.Brand = v
+		return nil
+	},
+	"item_status": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.Status, err = parseInt(v)
+		return err
+	},
+	"item_quantity": func(_ *mapper.OrderIntoDomainDTO, i *mapper.ItemIntoDomainDTO, v string) (err error) {
+		i.Quantity, err = parseInt(v)
+		return err
+	},
+}
+
+// ParseMapping разбирает сопоставление вида "order_uid=id,delivery_name=customer",
+// слева - имя поля заказа, справа - имя колонки в CSV
+func ParseMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || field == "" || column == "" {
+			return nil, ErrInvalidMapping
+		}
+		mapping[field] = column
+	}
+
+	return mapping, nil
+}
+
+type column struct {
+	index  int
+	name   string
+	setter fieldSetter
+}
+
+// csvReader собирает заказ из подряд идущих строк с одинаковым order_uid, одна строка - одна позиция
+type csvReader struct {
+	r            *csv.Reader
+	orderColumns []column
+	itemColumns  []column
+	uidIndex     int
+	line         int
+	pending      *csvRow
+}
+
+// csvRow - строка файла, err - строку не удалось разобрать (короткая строка или ошибка формата CSV)
+type csvRow struct {
+	fields []string
+	line   int
+	err    error
+}
+
+func NewCSVReader(r io.Reader, mapping map[string]string) (Reader, error) {
+	for field := range mapping {
+		if _, ok := orderFields[field]; ok {
+			continue
+		}
+		if _, ok := itemFields[field]; ok {
+			continue
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnknownField, field)
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+
+	reader := &csvReader{r: cr, line: 1, uidIndex: -1}
+
+	resolve := func(fields map[string]fieldSetter) ([]column, error) {
+		var columns []column
+		for field, setter := range fields {
+			name, mapped := mapping[field]
+			if !mapped {
+				name = field
+			}
+
+			i, ok := index[name]
+			if !ok {
+				if mapped {
+					return nil, fmt.Errorf("%w: %s", ErrColumnNotFound, name)
+				}
+				continue
+			}
+
+			if field == "order_uid" {
+				reader.uidIndex = i
+			}
+			columns = append(columns, column{index: i, name: field, setter: setter})
+		}
+		return columns, nil
+	}
+
+	if reader.orderColumns, err = resolve(orderFields); err != nil {
+		return nil, err
+	}
+	if reader.itemColumns, err = resolve(itemFields); err != nil {
+		return nil, err
+	}
+
+	if reader.uidIndex < 0 {
+		return nil, fmt.Errorf("%w: order_uid", ErrColumnNotFound)
+	}
+
+	return reader, nil
+}
+
+// Next собирает заказ из идущих подряд строк с одним order_uid. Строка, которую не удалось разобрать,
+// отдается отдельной отклоненной записью, чтение продолжается со следующей
+func (c *csvReader) Next() (*Record, error) {
+	row, err := c.nextRow()
+	if err != nil {
+		return nil, err
+	}
+	if row.err != nil {
+		return &Record{Line: row.line, Err: row.err}, nil
+	}
+
+	record := &Record{Line: row.line, Order: &mapper.OrderIntoDomainDTO{}}
+	record.Err = c.apply(c.orderColumns, record.Order, nil, row.fields)
+	uid := row.fields[c.uidIndex]
+
+	for {
+		item := mapper.ItemIntoDomainDTO{}
+		if err = c.apply(c.itemColumns, record.Order, &item, row.fields); err != nil && record.Err == nil {
+			record.Err = err
+		}
+		record.Order.Items = append(record.Order.Items, item)
+
+		row, err = c.nextRow()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if row.err != nil || row.fields[c.uidIndex] != uid {
+			c.pending = row
+			break
+		}
+	}
+
+	if record.Err != nil {
+		record.Order = nil
+	}
+
+	return record, nil
+}
+
+// nextRow возвращает ошибку только при сбое чтения или в конце файла (io.EOF)
+func (c *csvReader) nextRow() (*csvRow, error) {
+	if c.pending != nil {
+		row := c.pending
+		c.pending = nil
+		return row, nil
+	}
+
+	fields, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	c.line++
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &csvRow{line: c.line, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) <= c.uidIndex {
+		return &csvRow{line: c.line, err: csv.ErrFieldCount}, nil
+	}
+
+	return &csvRow{fields: fields, line: c.line}, nil
+}
+
+func (c *csvReader) apply(columns []column, order *mapper.OrderIntoDomainDTO, item *mapper.ItemIntoDomainDTO, row []string) error {
+	for _, col := range columns {
+		if col.index >= len(row) {
+			continue
+		}
+		if err := col.setter(order, item, strings.TrimSpace(row[col.index])); err != nil {
+			return fmt.Errorf("%s: %w", col.name, err)
+		}
+	}
+
+	return nil
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func parseUUID(v string) (uuid.UUID, error) {
+	if v == "" {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(v)
+}
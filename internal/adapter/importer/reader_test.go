@@ -0,0 +1,120 @@
+package importer_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/adapter/importer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, r importer.Reader) []*importer.Record {
+	t.Helper()
+
+	var records []*importer.Record
+	for {
+		record, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestJSONLReader_SkipsBlankLinesAndKeepsLineNumbers(t *testing.T) {
+	input := `{"order_uid":"11111111-1111-1111-1111-111111111111","track_number":"T1"}
+
+not a json
+{"order_uid":"22222222-2222-2222-2222-222222222222","track_number":"T2"}
+`
+	records := readAll(t, importer.NewJSONLReader(strings.NewReader(input)))
+
+	require.Len(t, records, 3)
+	assert.Equal(t, 1, records[0].Line)
+	assert.Equal(t, "T1", records[0].Order.TrackNumber)
+	assert.Equal(t, 3, records[1].Line)
+	assert.Error(t, records[1].Err)
+	assert.Equal(t, 4, records[2].Line)
+}
+
+func TestCSVReader_GroupsRowsByOrderUID(t *testing.T) {
+	input := `order_uid,track_number,customer,item_name,item_price
+11111111-1111-1111-1111-111111111111,T1,Ann,first,10
+11111111-1111-1111-1111-111111111111,T1,Ann,second,20
+22222222-2222-2222-2222-222222222222,T2,Bob,third,30
+`
+	mapping, err := importer.ParseMapping("delivery_name=customer")
+	require.NoError(t, err)
+
+	reader, err := importer.NewCSVReader(strings.NewReader(input), mapping)
+	require.NoError(t, err)
+
+	records := readAll(t, reader)
+
+	require.Len(t, records, 2)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "Ann", records[0].Order.Delivery.Name)
+	require.Len(t, records[0].Order.Items, 2)
+	assert.Equal(t, "second", records[0].Order.Items[1].Name)
+	assert.Equal(t, 20, records[0].Order.Items[1].Price)
+	assert.Equal(t, 4, records[1].Line)
+	assert.Equal(t, "Bob", records[1].Order.Delivery.Name)
+}
+
+func TestCSVReader_InvalidValueRejectsRecord(t *testing.T) {
+	input := `order_uid,item_price
+11111111-1111-1111-1111-111111111111,abc
+`
+	reader, err := importer.NewCSVReader(strings.NewReader(input), nil)
+	require.NoError(t, err)
+
+	records := readAll(t, reader)
+
+	require.Len(t, records, 1)
+	assert.ErrorContains(t, records[0].Err, "item_price")
+	assert.Nil(t, records[0].Order)
+}
+
+func TestCSVReader_MalformedRowsAreRejected(t *testing.T) {
+	input := `track_number,item_name,order_uid
+T1,first,11111111-1111-1111-1111-111111111111
+T1,short
+T2,bad"quote,22222222-2222-2222-2222-222222222222
+T3,third,33333333-3333-3333-3333-333333333333
+`
+	reader, err := importer.NewCSVReader(strings.NewReader(input), nil)
+	require.NoError(t, err)
+
+	records := readAll(t, reader)
+
+	require.Len(t, records, 4)
+	assert.Equal(t, "T1", records[0].Order.TrackNumber)
+	require.Len(t, records[0].Order.Items, 1)
+
+	assert.Equal(t, 3, records[1].Line)
+	assert.ErrorIs(t, records[1].Err, csv.ErrFieldCount)
+	assert.Equal(t, 4, records[2].Line)
+	assert.ErrorIs(t, records[2].Err, csv.ErrBareQuote)
+
+	assert.Equal(t, 5, records[3].Line)
+	assert.Equal(t, "T3", records[3].Order.TrackNumber)
+}
+
+func TestCSVReader_MappingErrors(t *testing.T) {
+	_, err := importer.NewCSVReader(strings.NewReader("order_uid\n"), map[string]string{"unknown": "x"})
+	assert.ErrorIs(t, err, importer.ErrUnknownField)
+
+	_, err = importer.NewCSVReader(strings.NewReader("order_uid\n"), map[string]string{"item_name": "missing"})
+	assert.ErrorIs(t, err, importer.ErrColumnNotFound)
+
+	_, err = importer.NewCSVReader(strings.NewReader("track_number\n"), nil)
+	assert.ErrorIs(t, err, importer.ErrColumnNotFound)
+
+	_, err = importer.ParseMapping("order_uid")
+	assert.ErrorIs(t, err, importer.ErrInvalidMapping)
+}
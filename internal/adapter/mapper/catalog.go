@@ -0,0 +1,98 @@
+package mapper
+
+import (
+	"github.com/folivorra/get_order/internal/domain"
+)
+
+// CatalogDTO - ответ GET /items/{nm_id}: все размеры артикула
+type CatalogDTO struct {
+	NmID  int              `json:"nm_id"`
+	Items []CatalogItemDTO `json:"items"`
+}
+
+type ItemAttributesDTO struct {
+	Name  string `json:"name"`
+	Brand string `json:"brand"`
+	Size  string `json:"size"`
+}
+
+type CatalogItemDTO struct {
+	ChrtID int `json:"chrt_id"`
+	ItemAttributesDTO
+	Version   int               `json:"version"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+	Versions  []ItemVersionDTO  `json:"versions"`
+	Conflicts []ItemConflictDTO `json:"conflicts"`
+}
+
+type ItemVersionDTO struct {
+	Version int `json:"version"`
+	ItemAttributesDTO
+	ItemUID   string `json:"item_uid"`
+	OrderUID  string `json:"order_uid"`
+	ValidFrom string `json:"valid_from"`
+}
+
+type ItemConflictDTO struct {
+	ItemUID    string            `json:"item_uid"`
+	OrderUID   string            `json:"order_uid"`
+	Fields     []string          `json:"fields"`
+	Current    ItemAttributesDTO `json:"current"`
+	Incoming   ItemAttributesDTO `json:"incoming"`
+	Applied    bool              `json:"applied"`
+	DetectedAt string            `json:"detected_at"`
+}
+
+func ConvertCatalog(nmID int, items []*domain.CatalogItem) CatalogDTO {
+	dto := CatalogDTO{
+		NmID:  nmID,
+		Items: make([]CatalogItemDTO, len(items)),
+	}
+
+	for i, item := range items {
+		itemDTO := CatalogItemDTO{
+			ChrtID:            item.ChrtID,
+			ItemAttributesDTO: convertItemAttributes(item.ItemAttributes),
+			Version:           item.Version,
+			CreatedAt:         FormatTime(item.CreatedAt),
+			UpdatedAt:         FormatTime(item.UpdatedAt),
+			Versions:          make([]ItemVersionDTO, len(item.Versions)),
+			Conflicts:         make([]ItemConflictDTO, len(item.Conflicts)),
+		}
+
+		for j, version := range item.Versions {
+			itemDTO.Versions[j] = ItemVersionDTO{
+				Version:           version.Version,
+				ItemAttributesDTO: convertItemAttributes(version.ItemAttributes),
+				ItemUID:           version.ItemUID.String(),
+				OrderUID:          version.OrderUID.String(),
+				ValidFrom:         FormatTime(version.ValidFrom),
+			}
+		}
+
+		for j, conflict := range item.Conflicts {
+			itemDTO.Conflicts[j] = ItemConflictDTO{
+				ItemUID:    conflict.ItemUID.String(),
+				OrderUID:   conflict.OrderUID.String(),
+				Fields:     conflict.Fields,
+				Current:    convertItemAttributes(conflict.Current),
+				Incoming:   convertItemAttributes(conflict.Incoming),
+				Applied:    conflict.Applied,
+				DetectedAt: FormatTime(conflict.DetectedAt),
+			}
+		}
+
+		dto.Items[i] = itemDTO
+	}
+
+	return dto
+}
+
+func convertItemAttributes(attrs domain.ItemAttributes) ItemAttributesDTO {
+	return ItemAttributesDTO{
+		Name:  attrs.Name,
+		Brand: attrs.Brand,
+		Size:  attrs.Size,
+	}
+}
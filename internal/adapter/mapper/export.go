@@ -0,0 +1,117 @@
+package mapper
+
+import (
+	"github.com/folivorra/get_order/internal/domain"
+	"strconv"
+)
+
+// OrderExportRow - плоское представление заказа для выгрузки, одна строка на каждую позицию заказа
+type OrderExportRow struct {
+	OrderUID        string `json:"order_uid" parquet:"order_uid"`
+	TrackNumber     string `json:"track_number" parquet:"track_number"`
+	DeliveryService string `json:"delivery_service" parquet:"delivery_service"`
+	DateCreated     string `json:"date_created" parquet:"date_created"`
+	DeliveryName    string `json:"delivery_name" parquet:"delivery_name"`
+	DeliveryPhone   string `json:"delivery_phone" parquet:"delivery_phone"`
+	DeliveryZip     string `json:"delivery_zip" parquet:"delivery_zip"`
+	DeliveryCity    string `json:"delivery_city" parquet:"delivery_city"`
+	DeliveryAddress string `json:"delivery_address" parquet:"delivery_address"`
+	DeliveryRegion  string `json:"delivery_region" parquet:"delivery_region"`
+	DeliveryEmail   string `json:"delivery_email" parquet:"delivery_email"`
+	PaymentCurrency string `json:"payment_currency" parquet:"payment_currency"`
+	PaymentAmount   int    `json:"payment_amount" parquet:"payment_amount"`
+	PaymentDelivery int    `json:"payment_delivery_cost" parquet:"payment_delivery_cost"`
+	ItemPrice       int    `json:"item_price" parquet:"item_price"`
+	ItemName        string `json:"item_name" parquet:"item_name"`
+	ItemSale        int    `json:"item_sale" parquet:"item_sale"`
+	ItemSize        string `json:"item_size" parquet:"item_size"`
+	ItemTotalPrice  int    `json:"item_total_price" parquet:"item_total_price"`
+	ItemBrand       string `json:"item_brand" parquet:"item_brand"`
+	ItemQuantity    int    `json:"item_quantity" parquet:"item_quantity"`
+}
+
+// OrderExportHeader - заголовок CSV в порядке полей OrderExportRow
+var OrderExportHeader = []string{
+	"order_uid",
+	"track_number",
+	"delivery_service",
+	"date_created",
+	"delivery_name",
+	"delivery_phone",
+	"delivery_zip",
+	"delivery_city",
+	"delivery_address",
+	"delivery_region",
+	"delivery_email",
+	"payment_currency",
+	"payment_amount",
+	"payment_delivery_cost",
+	"item_price",
+	"item_name",
+	"item_sale",
+	"item_size",
+	"item_total_price",
+	"item_brand",
+	"item_quantity",
+}
+
+// ConvertToExportRows раскладывает заказ по позициям так же, как это делает OrderFromDomainDTO
+func ConvertToExportRows(order *domain.Order) []OrderExportRow {
+	dto := ConvertFromDomain(order)
+
+	rows := make([]OrderExportRow, len(dto.Items))
+	for i, item := range dto.Items {
+		rows[i] = OrderExportRow{
+			OrderUID:        dto.OrderUID.String(),
+			TrackNumber:     dto.TrackNumber,
+			DeliveryService: dto.DeliveryService,
+			DateCreated:     dto.DateCreated,
+			DeliveryName:    dto.Delivery.Name,
+			DeliveryPhone:   dto.Delivery.Phone,
+			DeliveryZip:     dto.Delivery.Zip,
+			DeliveryCity:    dto.Delivery.City,
+			DeliveryAddress: dto.Delivery.Address,
+			DeliveryRegion:  dto.Delivery.Region,
+			DeliveryEmail:   dto.Delivery.Email,
+			PaymentCurrency: dto.Payment.Currency,
+			PaymentAmount:   dto.Payment.Amount,
+			PaymentDelivery: dto.Payment.DeliveryCost,
+			ItemPrice:       item.Price,
+			ItemName:        item.Name,
+			ItemSale:        item.Sale,
+			ItemSize:        item.Size,
+			ItemTotalPrice:  item.TotalPrice,
+			ItemBrand:       item.Brand,
+			ItemQuantity:    item.Quantity,
+		}
+	}
+
+	return rows
+}
+
+// Record возвращает строку в порядке OrderExportHeader
+func (r *OrderExportRow) Record() []string {
+	return []string{
+		r.OrderUID,
+		r.TrackNumber,
+		r.DeliveryService,
+		r.DateCreated,
+		r.DeliveryName,
+		r.DeliveryPhone,
+		r.DeliveryZip,
+		r.DeliveryCity,
+		r.DeliveryAddress,
+		r.DeliveryRegion,
+		r.DeliveryEmail,
+		r.PaymentCurrency,
+		strconv.Itoa(r.PaymentAmount),
+		strconv.Itoa(r.PaymentDelivery),
+		strconv.Itoa(r.ItemPrice),
+		r.ItemName,
+		strconv.Itoa(r.ItemSale),
+		r.ItemSize,
+		strconv.Itoa(r.ItemTotalPrice),
+		r.ItemBrand,
+		strconv.Itoa(r.ItemQuantity),
+	}
+}
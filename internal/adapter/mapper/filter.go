@@ -0,0 +1,82 @@
+package mapper
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPageToken = errors.New("invalid page token")
+)
+
+// OrderFilterDTO - фильтр выборки заказов в том виде, в котором он приходит от клиента, даты в RFC3339
+type OrderFilterDTO struct {
+	DateFrom        string `json:"date_from"`
+	DateTo          string `json:"date_to"`
+	DeliveryService string `json:"delivery_service"`
+	Region          string `json:"region"`
+	Currency        string `json:"currency"`
+	Phone           string `json:"phone"`
+	Email           string `json:"email"`
+}
+
+func ConvertFilterToDomain(dto *OrderFilterDTO) (domain.OrderFilter, error) {
+	filter := domain.OrderFilter{
+		DeliveryService: dto.DeliveryService,
+		Region:          dto.Region,
+		Currency:        dto.Currency,
+		Phone:           dto.Phone,
+		Email:           dto.Email,
+	}
+
+	var err error
+	if dto.DateFrom != "" {
+		if filter.DateFrom, err = time.Parse(time.RFC3339, dto.DateFrom); err != nil {
+			return filter, fmt.Errorf("date_from: %w", err)
+		}
+	}
+	if dto.DateTo != "" {
+		if filter.DateTo, err = time.Parse(time.RFC3339, dto.DateTo); err != nil {
+			return filter, fmt.Errorf("date_to: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+// EncodePageToken - base64 от "date_created|order_uid" последнего заказа страницы
+func EncodePageToken(order *domain.Order) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(FormatTime(order.DateCreated) + "|" + order.OrderUID.String()))
+}
+
+// DecodePageToken разбирает токен из EncodePageToken, пустой токен означает первую страницу
+func DecodePageToken(token string) (domain.OrderPage, error) {
+	var page domain.OrderPage
+	if token == "" {
+		return page, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return page, ErrInvalidPageToken
+	}
+
+	date, uid, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return page, ErrInvalidPageToken
+	}
+
+	if page.AfterDateCreated, err = time.Parse(time.RFC3339Nano, date); err != nil {
+		return page, ErrInvalidPageToken
+	}
+	if page.AfterOrderUID, err = uuid.Parse(uid); err != nil {
+		return page, ErrInvalidPageToken
+	}
+
+	return page, nil
+}
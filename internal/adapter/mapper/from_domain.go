@@ -1,44 +1,62 @@
 package mapper
 
 import (
+	"encoding/xml"
 	"github.com/folivorra/get_order/internal/domain"
 	"github.com/google/uuid"
 )
 
 type DeliveryFromDomainDTO struct {
-	Name    string `json:"name"`
-	Phone   string `json:"phone"`
-	Zip     string `json:"zip"`
-	City    string `json:"city"`
-	Address string `json:"address"`
-	Region  string `json:"region"`
-	Email   string `json:"email"`
+	Name    string `json:"name" xml:"name"`
+	Phone   string `json:"phone" xml:"phone"`
+	Zip     string `json:"zip" xml:"zip"`
+	City    string `json:"city" xml:"city"`
+	Address string `json:"address" xml:"address"`
+	Region  string `json:"region" xml:"region"`
+	Email   string `json:"email" xml:"email"`
 }
 
 type PaymentFromDomainDTO struct {
-	Currency     string `json:"currency"`
-	Amount       int    `json:"amount"`
-	DeliveryCost int    `json:"delivery_cost"`
+	Currency     string `json:"currency" xml:"currency"`
+	Amount       int    `json:"amount" xml:"amount"`
+	DeliveryCost int    `json:"delivery_cost" xml:"delivery_cost"`
 }
 
 type ItemFromDomainDTO struct {
-	Price      int    `json:"price"`
-	Name       string `json:"name"`
-	Sale       int    `json:"sale"`
-	Size       string `json:"size"`
-	TotalPrice int    `json:"total_price"`
-	Brand      string `json:"brand"`
-	Quantity   int    `json:"quantity"`
+	Price      int    `json:"price" xml:"price"`
+	Name       string `json:"name" xml:"name"`
+	Sale       int    `json:"sale" xml:"sale"`
+	Size       string `json:"size" xml:"size"`
+	TotalPrice int    `json:"total_price" xml:"total_price"`
+	Brand      string `json:"brand" xml:"brand"`
+	Quantity   int    `json:"quantity" xml:"quantity"`
+}
+
+// TotalsFromDomainDTO - итоги платежа в основных единицах валюты: "18.17"
+type TotalsFromDomainDTO struct {
+	Currency     string `json:"currency" xml:"currency"`
+	Amount       string `json:"amount" xml:"amount"`
+	GoodsTotal   string `json:"goods_total" xml:"goods_total"`
+	DeliveryCost string `json:"delivery_cost" xml:"delivery_cost"`
+	CustomFee    string `json:"custom_fee" xml:"custom_fee"`
+}
+
+// OrderTotalsDTO - итоги в валюте платежа и в валюте отчетности, если для валюты платежа есть курс
+type OrderTotalsDTO struct {
+	Original  TotalsFromDomainDTO  `json:"original" xml:"original"`
+	Reporting *TotalsFromDomainDTO `json:"reporting,omitempty" xml:"reporting,omitempty"`
 }
 
 type OrderFromDomainDTO struct {
-	OrderUID        uuid.UUID             `json:"order_uid"`
-	TrackNumber     string                `json:"track_number"`
-	Delivery        DeliveryFromDomainDTO `json:"delivery"`
-	Payment         PaymentFromDomainDTO  `json:"payment"`
-	Items           []ItemFromDomainDTO   `json:"items"`
-	DeliveryService string                `json:"delivery_service"`
-	DateCreated     string                `json:"date_created"`
+	XMLName         xml.Name              `json:"-" xml:"order"`
+	OrderUID        uuid.UUID             `json:"order_uid" xml:"order_uid"`
+	TrackNumber     string                `json:"track_number" xml:"track_number"`
+	Delivery        DeliveryFromDomainDTO `json:"delivery" xml:"delivery"`
+	Payment         PaymentFromDomainDTO  `json:"payment" xml:"payment"`
+	Items           []ItemFromDomainDTO   `json:"items" xml:"items>item"`
+	Totals          OrderTotalsDTO        `json:"totals" xml:"totals"`
+	DeliveryService string                `json:"delivery_service" xml:"delivery_service"`
+	DateCreated     string                `json:"date_created" xml:"date_created"`
 }
 
 func ConvertFromDomain(order *domain.Order) *OrderFromDomainDTO {
@@ -55,26 +73,44 @@ func ConvertFromDomain(order *domain.Order) *OrderFromDomainDTO {
 			Email:   order.Delivery.Email,
 		},
 		Payment: PaymentFromDomainDTO{
-			Currency:     order.Payment.Currency,
-			Amount:       order.Payment.Amount,
-			DeliveryCost: order.Payment.DeliveryCost,
+			Currency:     string(order.Payment.Currency),
+			Amount:       int(order.Payment.Amount.Amount),
+			DeliveryCost: int(order.Payment.DeliveryCost.Amount),
+		},
+		Items: make([]ItemFromDomainDTO, len(order.Items)),
+		Totals: OrderTotalsDTO{
+			Original: convertTotals(order.Payment.Totals()),
 		},
-		Items:           make([]ItemFromDomainDTO, len(order.Items)),
 		DeliveryService: order.DeliveryService,
-		DateCreated:     order.DateCreated,
+		DateCreated:     FormatTime(order.DateCreated),
 	}
 
 	for i, item := range order.Items {
 		orderDTO.Items[i] = ItemFromDomainDTO{
-			Price:      item.Price,
+			Price:      int(item.Price.Amount),
 			Name:       item.Item.Name,
 			Sale:       item.Sale,
 			Size:       item.Item.Size,
-			TotalPrice: item.TotalPrice,
+			TotalPrice: int(item.TotalPrice.Amount),
 			Brand:      item.Item.Brand,
 			Quantity:   item.Quantity,
 		}
 	}
 
+	if order.Reporting != nil {
+		reporting := convertTotals(*order.Reporting)
+		orderDTO.Totals.Reporting = &reporting
+	}
+
 	return &orderDTO
 }
+
+func convertTotals(totals domain.Totals) TotalsFromDomainDTO {
+	return TotalsFromDomainDTO{
+		Currency:     string(totals.Currency()),
+		Amount:       totals.Amount.Decimal(),
+		GoodsTotal:   totals.GoodsTotal.Decimal(),
+		DeliveryCost: totals.DeliveryCost.Decimal(),
+		CustomFee:    totals.CustomFee.Decimal(),
+	}
+}
@@ -16,16 +16,16 @@ type DeliveryIntoDomainDTO struct {
 }
 
 type PaymentIntoDomainDTO struct {
-	Transaction  string `json:"transaction"`
-	RequestID    string `json:"request_id"`
-	Currency     string `json:"currency"`
-	Provider     string `json:"provider"`
-	Amount       int    `json:"amount"`
-	PaymentDT    int    `json:"payment_dt"`
-	Bank         string `json:"bank"`
-	DeliveryCost int    `json:"delivery_cost"`
-	GoodsTotal   int    `json:"goods_total"`
-	CustomFee    int    `json:"custom_fee"`
+	Transaction  string    `json:"transaction"`
+	RequestID    string    `json:"request_id"`
+	Currency     string    `json:"currency"`
+	Provider     string    `json:"provider"`
+	Amount       int       `json:"amount"`
+	PaymentDT    Timestamp `json:"payment_dt"`
+	Bank         string    `json:"bank"`
+	DeliveryCost int       `json:"delivery_cost"`
+	GoodsTotal   int       `json:"goods_total"`
+	CustomFee    int       `json:"custom_fee"`
 }
 
 type ItemIntoDomainDTO struct {
@@ -57,19 +57,26 @@ type OrderIntoDomainDTO struct {
 	DeliveryService   string                `json:"delivery_service"`
 	Shardkey          string                `json:"shardkey"`
 	SmID              int                   `json:"sm_id"`
-	DateCreated       string                `json:"date_created"`
+	DateCreated       Timestamp             `json:"date_created"`
 	OofShard          string                `json:"oof_shard"`
 }
 
+// ConvertToDomain - суммы во входящем заказе в минимальных единицах валюты платежа,
+// код валюты приводится к верхнему регистру
 func ConvertToDomain(dto *OrderIntoDomainDTO) *domain.Order {
+	currency := domain.NormalizeCurrency(dto.Payment.Currency)
+	money := func(amount int) domain.Money {
+		return domain.NewMoney(int64(amount), currency)
+	}
+
 	items := make([]domain.OrderItem, len(dto.Items))
 	for i, itemDTO := range dto.Items {
 		items[i] = domain.OrderItem{
 			OrderUID:   dto.OrderUID,
 			ItemUID:    itemDTO.ItemUID,
-			Price:      itemDTO.Price,
+			Price:      money(itemDTO.Price),
 			Sale:       itemDTO.Sale,
-			TotalPrice: itemDTO.TotalPrice,
+			TotalPrice: money(itemDTO.TotalPrice),
 			Quantity:   itemDTO.Quantity,
 			Item: &domain.Item{
 				ItemUID:     itemDTO.ItemUID,
@@ -101,14 +108,14 @@ func ConvertToDomain(dto *OrderIntoDomainDTO) *domain.Order {
 		Payment: domain.Payment{
 			Transaction:  dto.Payment.Transaction,
 			RequestID:    dto.Payment.RequestID,
-			Currency:     dto.Payment.Currency,
+			Currency:     currency,
 			Provider:     dto.Payment.Provider,
-			Amount:       dto.Payment.Amount,
-			PaymentDT:    dto.Payment.PaymentDT,
+			Amount:       money(dto.Payment.Amount),
+			PaymentDT:    dto.Payment.PaymentDT.UTC(),
 			Bank:         dto.Payment.Bank,
-			DeliveryCost: dto.Payment.DeliveryCost,
-			GoodsTotal:   dto.Payment.GoodsTotal,
-			CustomFee:    dto.Payment.CustomFee,
+			DeliveryCost: money(dto.Payment.DeliveryCost),
+			GoodsTotal:   money(dto.Payment.GoodsTotal),
+			CustomFee:    money(dto.Payment.CustomFee),
 		},
 		Items:             items,
 		Locale:            dto.Locale,
@@ -117,7 +124,7 @@ func ConvertToDomain(dto *OrderIntoDomainDTO) *domain.Order {
 		DeliveryService:   dto.DeliveryService,
 		ShardKey:          dto.Shardkey,
 		SmID:              dto.SmID,
-		DateCreated:       dto.DateCreated,
+		DateCreated:       dto.DateCreated.UTC(),
 		OofShard:          dto.OofShard,
 	}
 }
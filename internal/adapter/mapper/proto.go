@@ -0,0 +1,166 @@
+package mapper
+
+import (
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"time"
+)
+
+func ConvertToProto(order *domain.Order) *orderv1.Order {
+	items := make([]*orderv1.OrderItem, len(order.Items))
+	for i, item := range order.Items {
+		items[i] = &orderv1.OrderItem{
+			OrderItemUid: item.OrderItemUID.String(),
+			OrderUid:     item.OrderUID.String(),
+			ItemUid:      item.ItemUID.String(),
+			Price:        item.Price.Amount,
+			Sale:         int64(item.Sale),
+			TotalPrice:   item.TotalPrice.Amount,
+			Quantity:     int32(item.Quantity),
+		}
+
+		if item.Item != nil {
+			items[i].Item = &orderv1.Item{
+				ItemUid:     item.Item.ItemUID.String(),
+				ChrtId:      int64(item.Item.ChrtID),
+				TrackNumber: item.Item.TrackNumber,
+				Rid:         item.Item.RID,
+				Name:        item.Item.Name,
+				Size:        item.Item.Size,
+				NmId:        int64(item.Item.NmID),
+				Brand:       item.Item.Brand,
+				Status:      int32(item.Item.Status),
+			}
+		}
+	}
+
+	return &orderv1.Order{
+		OrderUid:    order.OrderUID.String(),
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: &orderv1.Delivery{
+			DeliveryUid: order.Delivery.DeliveryUID.String(),
+			Name:        order.Delivery.Name,
+			Phone:       order.Delivery.Phone,
+			Zip:         order.Delivery.Zip,
+			City:        order.Delivery.City,
+			Address:     order.Delivery.Address,
+			Region:      order.Delivery.Region,
+			Email:       order.Delivery.Email,
+		},
+		Payment: &orderv1.Payment{
+			PaymentUid:   order.Payment.PaymentUID.String(),
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     string(order.Payment.Currency),
+			Provider:     order.Payment.Provider,
+			Amount:       order.Payment.Amount.Amount,
+			PaymentDt:    unixSeconds(order.Payment.PaymentDT),
+			Bank:         order.Payment.Bank,
+			DeliveryCost: order.Payment.DeliveryCost.Amount,
+			GoodsTotal:   order.Payment.GoodsTotal.Amount,
+			CustomFee:    order.Payment.CustomFee.Amount,
+		},
+		Items:             items,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		ShardKey:          order.ShardKey,
+		SmId:              int64(order.SmID),
+		DateCreated:       FormatTime(order.DateCreated),
+		OofShard:          order.OofShard,
+	}
+}
+
+// ConvertProtoToDTO приводит заказ из gRPC к тому же DTO, что читает консьюмер,
+// чтобы дальше он прошел ту же валидацию. Некорректные uuid превращаются в uuid.Nil
+func ConvertProtoToDTO(order *orderv1.Order) *OrderIntoDomainDTO {
+	items := make([]ItemIntoDomainDTO, len(order.GetItems()))
+	for i, item := range order.GetItems() {
+		items[i] = ItemIntoDomainDTO{
+			ItemUID:     parseUUIDOrNil(item.GetItemUid()),
+			ChrtID:      int(item.GetItem().GetChrtId()),
+			TrackNumber: item.GetItem().GetTrackNumber(),
+			Price:       int(item.GetPrice()),
+			Rid:         item.GetItem().GetRid(),
+			Name:        item.GetItem().GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetItem().GetSize(),
+			TotalPrice:  int(item.GetTotalPrice()),
+			NmID:        int(item.GetItem().GetNmId()),
+			Brand:       item.GetItem().GetBrand(),
+			Status:      int(item.GetItem().GetStatus()),
+			Quantity:    int(item.GetQuantity()),
+		}
+	}
+
+	return &OrderIntoDomainDTO{
+		OrderUID:    parseUUIDOrNil(order.GetOrderUid()),
+		TrackNumber: order.GetTrackNumber(),
+		Entry:       order.GetEntry(),
+		Delivery: DeliveryIntoDomainDTO{
+			Name:    order.GetDelivery().GetName(),
+			Phone:   order.GetDelivery().GetPhone(),
+			Zip:     order.GetDelivery().GetZip(),
+			City:    order.GetDelivery().GetCity(),
+			Address: order.GetDelivery().GetAddress(),
+			Region:  order.GetDelivery().GetRegion(),
+			Email:   order.GetDelivery().GetEmail(),
+		},
+		Payment: PaymentIntoDomainDTO{
+			Transaction:  order.GetPayment().GetTransaction(),
+			RequestID:    order.GetPayment().GetRequestId(),
+			Currency:     order.GetPayment().GetCurrency(),
+			Provider:     order.GetPayment().GetProvider(),
+			Amount:       int(order.GetPayment().GetAmount()),
+			PaymentDT:    unixTimestamp(order.GetPayment().GetPaymentDt()),
+			Bank:         order.GetPayment().GetBank(),
+			DeliveryCost: int(order.GetPayment().GetDeliveryCost()),
+			GoodsTotal:   int(order.GetPayment().GetGoodsTotal()),
+			CustomFee:    int(order.GetPayment().GetCustomFee()),
+		},
+		Items:             items,
+		Locale:            order.GetLocale(),
+		InternalSignature: order.GetInternalSignature(),
+		CustomerID:        order.GetCustomerId(),
+		DeliveryService:   order.GetDeliveryService(),
+		Shardkey:          order.GetShardKey(),
+		SmID:              int(order.GetSmId()),
+		DateCreated:       parseTimestampOrZero(order.GetDateCreated()),
+		OofShard:          order.GetOofShard(),
+	}
+}
+
+// unixSeconds - payment_dt в gRPC передается секундами unix, нулевое время - 0
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func unixTimestamp(seconds int64) Timestamp {
+	if seconds == 0 {
+		return Timestamp{}
+	}
+	return NewTimestamp(time.Unix(seconds, 0))
+}
+
+// parseTimestampOrZero - некорректная дата становится нулевой и не проходит правило required
+func parseTimestampOrZero(s string) Timestamp {
+	t, err := ParseTimestamp(s)
+	if err != nil {
+		return Timestamp{}
+	}
+	return t
+}
+
+func parseUUIDOrNil(s string) uuid.UUID {
+	uid, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil
+	}
+	return uid
+}
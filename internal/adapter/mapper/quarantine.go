@@ -0,0 +1,63 @@
+package mapper
+
+import (
+	"encoding/json"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"time"
+)
+
+// QuarantinedOrderDTO - запись карантина в ответах API. Payload - исходное сообщение,
+// если оно не было JSON, то строкой
+type QuarantinedOrderDTO struct {
+	ID            string          `json:"id"`
+	OrderUID      string          `json:"order_uid,omitempty"`
+	Source        string          `json:"source"`
+	Topic         string          `json:"topic,omitempty"`
+	Partition     int             `json:"partition"`
+	Offset        int64           `json:"offset"`
+	SchemaVersion int             `json:"schema_version,omitempty"`
+	Reason        string          `json:"reason"`
+	Report        json.RawMessage `json:"report"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	Status        string          `json:"status"`
+	ReviewedBy    string          `json:"reviewed_by,omitempty"`
+	ReviewNote    string          `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// ConvertQuarantinedOrder - withPayload включает исходное сообщение (в списке оно не отдается)
+func ConvertQuarantinedOrder(entry *domain.QuarantinedOrder, withPayload bool) QuarantinedOrderDTO {
+	dto := QuarantinedOrderDTO{
+		ID:            entry.ID.String(),
+		Source:        entry.Source,
+		Topic:         entry.Topic,
+		Partition:     entry.Partition,
+		Offset:        entry.Offset,
+		SchemaVersion: entry.SchemaVersion,
+		Reason:        entry.Reason,
+		Report:        entry.Report,
+		Status:        string(entry.Status),
+		ReviewedBy:    entry.ReviewedBy,
+		ReviewNote:    entry.ReviewNote,
+		CreatedAt:     entry.CreatedAt,
+	}
+
+	if entry.OrderUID != uuid.Nil {
+		dto.OrderUID = entry.OrderUID.String()
+	}
+	if !entry.ReviewedAt.IsZero() {
+		dto.ReviewedAt = &entry.ReviewedAt
+	}
+
+	if withPayload {
+		if json.Valid(entry.Payload) {
+			dto.Payload = entry.Payload
+		} else {
+			dto.Payload, _ = json.Marshal(string(entry.Payload))
+		}
+	}
+
+	return dto
+}
@@ -0,0 +1,68 @@
+package mapper
+
+import (
+	"github.com/folivorra/get_order/internal/domain"
+)
+
+// OrderStatsDTO - ответ GET /stats/orders. From и To - выровненные по интервалам границы,
+// Source - live (по таблицам заказов) или rollup (по почасовым агрегатам)
+type OrderStatsDTO struct {
+	Bucket  string             `json:"bucket"`
+	GroupBy []string           `json:"group_by"`
+	From    string             `json:"from"`
+	To      string             `json:"to"`
+	Source  string             `json:"source"`
+	Rows    []OrderStatsRowDTO `json:"rows"`
+}
+
+// OrderStatsRowDTO - поля измерений есть в строке, только если по ним была группировка
+type OrderStatsRowDTO struct {
+	Bucket          string  `json:"bucket"`
+	DeliveryService *string `json:"delivery_service,omitempty"`
+	Region          *string `json:"region,omitempty"`
+	Brand           *string `json:"brand,omitempty"`
+	Currency        string  `json:"currency"`
+	Orders          int64   `json:"orders"`
+	Items           int64   `json:"items"`
+	Revenue         string  `json:"revenue"`
+}
+
+func ConvertOrderStats(query domain.OrderStatsQuery, rollup bool, rows []domain.OrderStatsRow) OrderStatsDTO {
+	dto := OrderStatsDTO{
+		Bucket:  string(query.Bucket),
+		GroupBy: make([]string, len(query.GroupBy)),
+		From:    FormatTime(query.From),
+		To:      FormatTime(query.To),
+		Source:  "live",
+		Rows:    make([]OrderStatsRowDTO, len(rows)),
+	}
+	if rollup {
+		dto.Source = "rollup"
+	}
+
+	for i, dimension := range query.GroupBy {
+		dto.GroupBy[i] = string(dimension)
+	}
+
+	for i, row := range rows {
+		rowDTO := OrderStatsRowDTO{
+			Bucket:   FormatTime(row.Bucket),
+			Currency: string(row.Currency),
+			Orders:   row.Orders,
+			Items:    row.Items,
+			Revenue:  row.Revenue.Decimal(),
+		}
+		if query.Has(domain.StatsByDeliveryService) {
+			rowDTO.DeliveryService = &row.DeliveryService
+		}
+		if query.Has(domain.StatsByRegion) {
+			rowDTO.Region = &row.Region
+		}
+		if query.Has(domain.StatsByBrand) {
+			rowDTO.Brand = &row.Brand
+		}
+		dto.Rows[i] = rowDTO
+	}
+
+	return dto
+}
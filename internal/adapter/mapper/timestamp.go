@@ -0,0 +1,85 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp - время во входящем заказе: строка RFC3339 с зоной или целое число секунд unix.
+// Время приводится к UTC, пустая строка и null - нулевое время. В JSON пишется строкой RFC3339 в UTC,
+// нулевое время - null, чтобы правило required видело отсутствие значения
+type Timestamp struct {
+	time.Time
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	if t.IsZero() {
+		return Timestamp{}
+	}
+	return Timestamp{Time: t.UTC()}
+}
+
+// ParseTimestamp разбирает RFC3339 или секунды unix, в CSV и заголовках дата приходит строкой
+func ParseTimestamp(value string) (Timestamp, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Timestamp{}, nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return NewTimestamp(time.Unix(seconds, 0)), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("timestamp %q: want RFC3339 or unix seconds", value)
+	}
+	return NewTimestamp(t), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		// строка из цифр - тоже секунды unix, как в CSV
+		parsed, err := ParseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+
+	seconds, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("timestamp %s: want RFC3339 or unix seconds", data)
+	}
+	*t = NewTimestamp(time.Unix(seconds, 0))
+	return nil
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(FormatTime(t.Time))
+}
+
+// FormatTime - время в ответах и выгрузках: RFC3339 в UTC, дробная часть секунд только если она есть
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
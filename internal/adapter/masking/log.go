@@ -0,0 +1,102 @@
+package masking
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// ReplaceAttr маскирует атрибуты slog по политике. Атрибут совпадает с полем по полному пути
+// (группы + ключ) или по последней части пути, если он записан без групп: "phone" -> delivery.phone.
+// Строковые значения с JSON-объектом (например, заказ целиком) маскируются по путям внутри JSON.
+func (p Policy) ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(p) == 0 || a.Value.Kind() != slog.KindString {
+		return a
+	}
+
+	if strategy, ok := p.lookup(groups, a.Key); ok {
+		return slog.String(a.Key, strategy.Apply(a.Value.String()))
+	}
+
+	value := a.Value.String()
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return a
+	}
+
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return a
+	}
+
+	if !p.maskJSON(doc, "") {
+		return a
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return a
+	}
+
+	return slog.String(a.Key, strings.TrimSuffix(buf.String(), "\n"))
+}
+
+func (p Policy) lookup(groups []string, key string) (Strategy, bool) {
+	path := strings.Join(append(groups[:len(groups):len(groups)], key), ".")
+	if strategy, ok := p[path]; ok {
+		return strategy, true
+	}
+
+	if len(groups) > 0 {
+		return "", false
+	}
+
+	for field, strategy := range p {
+		if strings.HasSuffix(field, "."+key) {
+			return strategy, true
+		}
+	}
+
+	return "", false
+}
+
+// maskJSON маскирует строки в разобранном JSON на месте, индексы массивов в путь не входят
+func (p Policy) maskJSON(node any, path string) bool {
+	changed := false
+
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			if s, ok := child.(string); ok {
+				if strategy, ok := p[childPath]; ok {
+					v[key] = strategy.Apply(s)
+					changed = true
+				}
+				continue
+			}
+
+			if p.maskJSON(child, childPath) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if p.maskJSON(child, path) {
+				changed = true
+			}
+		}
+	}
+
+	return changed
+}
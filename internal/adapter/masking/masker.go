@@ -0,0 +1,49 @@
+package masking
+
+import (
+	"context"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/domain"
+)
+
+// Masker маскирует заказы в ответах API для клиентов, чья роль ниже reveal
+type Masker struct {
+	policy Policy
+	reveal middleware.Role
+}
+
+func NewMasker(policy Policy, reveal middleware.Role) *Masker {
+	return &Masker{
+		policy: policy,
+		reveal: reveal,
+	}
+}
+
+// Reveal сообщает, можно ли показать клиенту запроса данные без маскирования
+func (m *Masker) Reveal(ctx context.Context) bool {
+	if m == nil || len(m.policy) == 0 {
+		return true
+	}
+
+	principal := middleware.PrincipalFromContext(ctx)
+	return principal != nil && principal.Role.Allows(m.reveal)
+}
+
+func (m *Masker) Order(ctx context.Context, order *domain.Order) *domain.Order {
+	if m.Reveal(ctx) {
+		return order
+	}
+	return m.policy.Order(order)
+}
+
+func (m *Masker) Orders(ctx context.Context, orders []*domain.Order) []*domain.Order {
+	if m.Reveal(ctx) {
+		return orders
+	}
+
+	masked := make([]*domain.Order, len(orders))
+	for i, order := range orders {
+		masked[i] = m.policy.Order(order)
+	}
+	return masked
+}
@@ -0,0 +1,96 @@
+package masking_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/folivorra/get_order/internal/adapter/masking"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func testPolicy(t *testing.T) masking.Policy {
+	t.Helper()
+
+	policy, err := masking.ParsePolicy([]string{
+		"delivery.name:partial",
+		"delivery.phone:last4",
+		"delivery.email:email",
+		"delivery.address",
+		"payment.transaction:full",
+	})
+	require.NoError(t, err)
+	return policy
+}
+
+func TestParsePolicy_RejectsUnknown(t *testing.T) {
+	_, err := masking.ParsePolicy([]string{"items.name:full"})
+	assert.ErrorIs(t, err, masking.ErrUnknownField)
+
+	_, err = masking.ParsePolicy([]string{"delivery.phone:first2"})
+	assert.ErrorIs(t, err, masking.ErrUnknownStrategy)
+}
+
+func TestStrategy_Apply(t *testing.T) {
+	assert.Equal(t, "И*** П***", masking.StrategyPartial.Apply("Иван Петров"))
+	assert.Equal(t, "***4567", masking.StrategyLast4.Apply("+79001234567"))
+	assert.Equal(t, "***", masking.StrategyLast4.Apply("123"))
+	assert.Equal(t, "i***@mail.ru", masking.StrategyEmail.Apply("ivan@mail.ru"))
+	assert.Equal(t, "***", masking.StrategyEmail.Apply("not-an-email"))
+	assert.Equal(t, "***", masking.StrategyFull.Apply("Lenina 1"))
+	assert.Equal(t, "", masking.StrategyFull.Apply(""))
+}
+
+func TestPolicy_OrderReturnsMaskedCopy(t *testing.T) {
+	order := &domain.Order{
+		Delivery: domain.Delivery{Name: "Ivan Petrov", Phone: "+79001234567", City: "Moscow", Email: "ivan@mail.ru"},
+		Payment:  domain.Payment{Transaction: "tx-1"},
+		Items:    []domain.OrderItem{{}},
+	}
+
+	masked := testPolicy(t).Order(order)
+
+	assert.Equal(t, "I*** P***", masked.Delivery.Name)
+	assert.Equal(t, "***4567", masked.Delivery.Phone)
+	assert.Equal(t, "Moscow", masked.Delivery.City)
+	assert.Equal(t, "***", masked.Payment.Transaction)
+	assert.Equal(t, "Ivan Petrov", order.Delivery.Name)
+	assert.Equal(t, "tx-1", order.Payment.Transaction)
+}
+
+func TestMasker_RevealsByRole(t *testing.T) {
+	masker := masking.NewMasker(testPolicy(t), middleware.RoleSupport)
+	order := &domain.Order{Delivery: domain.Delivery{Phone: "+79001234567"}}
+
+	anonymous := context.Background()
+	viewer := middleware.WithPrincipal(anonymous, &middleware.Principal{Subject: "ui", Role: middleware.RoleViewer})
+	admin := middleware.WithPrincipal(anonymous, &middleware.Principal{Subject: "ops", Role: middleware.RoleAdmin})
+
+	assert.Equal(t, "***4567", masker.Order(anonymous, order).Delivery.Phone)
+	assert.Equal(t, "***4567", masker.Order(viewer, order).Delivery.Phone)
+	assert.Same(t, order, masker.Order(admin, order))
+}
+
+func TestPolicy_ReplaceAttr(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: testPolicy(t).ReplaceAttr}))
+
+	logger.Info("saved order view",
+		slog.String("order", `{"delivery":{"name":"Ivan Petrov","email":"ivan@mail.ru","city":"Moscow"},"items":[{"name":"Mascaras"}],"payment":{"transaction":"tx-1","amount":1817}}`),
+		slog.String("phone", "+79001234567"),
+		slog.Group("delivery", slog.String("email", "ivan@mail.ru")),
+		slog.Group("item", slog.String("name", "Mascaras")),
+	)
+
+	out := buf.String()
+	assert.NotContains(t, out, "Ivan")
+	assert.NotContains(t, out, "ivan@")
+	assert.NotContains(t, out, "tx-1")
+	assert.NotContains(t, out, "+7900")
+	assert.Contains(t, out, "Moscow")
+	assert.Contains(t, out, "1817")
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("Mascaras")))
+}
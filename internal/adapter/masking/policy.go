@@ -0,0 +1,118 @@
+package masking
+
+import (
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/domain"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strategy - способ маскирования значения поля
+type Strategy string
+
+const (
+	StrategyFull    Strategy = "full"    // значение целиком заменяется на ***
+	StrategyPartial Strategy = "partial" // остается первая буква каждого слова: И*** П***
+	StrategyLast4   Strategy = "last4"   // остаются последние 4 символа: ***4567
+	StrategyEmail   Strategy = "email"   // остается первая буква и домен: i***@mail.ru
+)
+
+const mask = "***"
+
+var (
+	ErrUnknownField    = errors.New("unknown masking field")
+	ErrUnknownStrategy = errors.New("unknown masking strategy")
+)
+
+// fields - поля заказа, которые можно маскировать, ключи совпадают с путями в JSON заказа
+var fields = map[string]func(order *domain.Order) *string{
+	"delivery.name":       func(o *domain.Order) *string { return &o.Delivery.Name },
+	"delivery.phone":      func(o *domain.Order) *string { return &o.Delivery.Phone },
+	"delivery.zip":        func(o *domain.Order) *string { return &o.Delivery.Zip },
+	"delivery.city":       func(o *domain.Order) *string { return &o.Delivery.City },
+	"delivery.address":    func(o *domain.Order) *string { return &o.Delivery.Address },
+	"delivery.region":     func(o *domain.Order) *string { return &o.Delivery.Region },
+	"delivery.email":      func(o *domain.Order) *string { return &o.Delivery.Email },
+	"payment.transaction": func(o *domain.Order) *string { return &o.Payment.Transaction },
+}
+
+// Policy - политика маскирования: путь поля -> способ
+type Policy map[string]Strategy
+
+// ParsePolicy принимает записи вида "delivery.phone:last4"
+func ParsePolicy(entries []string) (Policy, error) {
+	policy := make(Policy, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		field, strategy, ok := strings.Cut(entry, ":")
+		if !ok {
+			strategy = string(StrategyFull)
+		}
+
+		if _, ok = fields[field]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, field)
+		}
+
+		switch Strategy(strategy) {
+		case StrategyFull, StrategyPartial, StrategyLast4, StrategyEmail:
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, strategy)
+		}
+
+		policy[field] = Strategy(strategy)
+	}
+
+	return policy, nil
+}
+
+// Order возвращает копию заказа с замаскированными полями, исходный заказ не меняется
+func (p Policy) Order(order *domain.Order) *domain.Order {
+	if order == nil || len(p) == 0 {
+		return order
+	}
+
+	masked := *order
+	for field, strategy := range p {
+		value := fields[field](&masked)
+		*value = strategy.Apply(*value)
+	}
+
+	return &masked
+}
+
+func (s Strategy) Apply(value string) string {
+	if value == "" {
+		return value
+	}
+
+	switch s {
+	case StrategyPartial:
+		words := strings.Fields(value)
+		for i, word := range words {
+			r, _ := utf8.DecodeRuneInString(word)
+			words[i] = string(r) + mask
+		}
+		return strings.Join(words, " ")
+	case StrategyLast4:
+		if utf8.RuneCountInString(value) <= 4 {
+			return mask
+		}
+		runes := []rune(value)
+		return mask + string(runes[len(runes)-4:])
+	case StrategyEmail:
+		local, domainPart, ok := strings.Cut(value, "@")
+		if !ok || local == "" {
+			return mask
+		}
+		r, _ := utf8.DecodeRuneInString(local)
+		return string(r) + mask + "@" + domainPart
+	default:
+		return mask
+	}
+}
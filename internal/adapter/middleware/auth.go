@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+)
+
+// Role - роль клиента HTTP API, роли упорядочены по возрастанию прав
+type Role string
+
+const (
+	RoleAnonymous Role = "anonymous"
+	RoleViewer    Role = "viewer"
+	RoleSupport   Role = "support"
+	RoleAdmin     Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleAnonymous: 0,
+	RoleViewer:    1,
+	RoleSupport:   2,
+	RoleAdmin:     3,
+}
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUnknownRole        = errors.New("unknown role")
+)
+
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleLevels[role]; !ok || role == RoleAnonymous {
+		return "", ErrUnknownRole
+	}
+	return role, nil
+}
+
+// Allows сообщает, достаточно ли прав роли для доступа с ролью required
+func (r Role) Allows(required Role) bool {
+	level, ok := roleLevels[r]
+	return ok && level >= roleLevels[required]
+}
+
+// Principal - аутентифицированный клиент
+type Principal struct {
+	Subject string
+	Role    Role
+	Method  string // apikey или jwt
+}
+
+// Authenticator извлекает клиента из запроса, ErrNoCredentials - в запросе нет его учетных данных
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type principalCtxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// PrincipalFromContext возвращает клиента запроса, nil - запрос анонимный
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalCtxKey{}).(*Principal)
+	return p
+}
+
+// RoutePolicy - требуемая роль по маршруту: ключ "METHOD /path/{template}" или только шаблон пути
+type RoutePolicy map[string]Role
+
+func (p RoutePolicy) required(r *http.Request) Role {
+	tpl, ok := routeTemplate(r)
+	if !ok {
+		return RoleAdmin
+	}
+
+	if role, ok := p[r.Method+" "+tpl]; ok {
+		return role
+	}
+	if role, ok := p[tpl]; ok {
+		return role
+	}
+
+	// маршрут без политики доступен только администратору
+	return RoleAdmin
+}
+
+// routeTemplate - шаблон пути маршрута, который совпал с запросом
+func routeTemplate(r *http.Request) (string, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+
+	return tpl, true
+}
+
+// routeValue ищет настройку маршрута сначала по ключу "METHOD /path/{template}", затем по шаблону пути.
+// route - ключ маршрута для логов, у несовпавших с роутером запросов вместо шаблона берется путь
+func routeValue[T any](values map[string]T, r *http.Request) (route string, value T, ok bool) {
+	tpl, matched := routeTemplate(r)
+	if !matched {
+		tpl = r.URL.Path
+	}
+
+	route = r.Method + " " + tpl
+	if value, ok = values[route]; ok {
+		return route, value, true
+	}
+	value, ok = values[tpl]
+	return route, value, ok
+}
+
+type Auth struct {
+	logger         *slog.Logger
+	policy         RoutePolicy
+	authenticators []Authenticator
+}
+
+func NewAuth(logger *slog.Logger, policy RoutePolicy, authenticators ...Authenticator) *Auth {
+	return &Auth{
+		logger:         logger,
+		policy:         policy,
+		authenticators: authenticators,
+	}
+}
+
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := a.policy.required(r)
+
+		// клиента мог уже определить стоящий раньше лимитер через Identify
+		var err error
+		principal := PrincipalFromContext(r.Context())
+		if principal == nil {
+			principal, err = authenticate(a.authenticators, r)
+		}
+		if err != nil && !errors.Is(err, ErrNoCredentials) {
+			a.deny(w, r, http.StatusUnauthorized, required, nil, err)
+			return
+		}
+
+		if required == RoleAnonymous {
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+			return
+		}
+
+		if principal == nil {
+			a.deny(w, r, http.StatusUnauthorized, required, nil, ErrNoCredentials)
+			return
+		}
+
+		if !principal.Role.Allows(required) {
+			a.deny(w, r, http.StatusForbidden, required, principal, errors.New("insufficient role"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// Identify - клиент запроса с верными учетными данными, иначе nil. Для RateLimitOptions.Identify
+func (a *Auth) Identify(r *http.Request) *Principal {
+	principal, err := authenticate(a.authenticators, r)
+	if err != nil {
+		return nil
+	}
+	return principal
+}
+
+// authenticate - первый аутентификатор, нашедший в запросе свои учетные данные, определяет клиента
+func authenticate(authenticators []Authenticator, r *http.Request) (*Principal, error) {
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+func (a *Auth) deny(w http.ResponseWriter, r *http.Request, status int, required Role, p *Principal, reason error) {
+	attrs := []any{
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.Path),
+		slog.String("remote", r.RemoteAddr),
+		slog.String("required_role", string(required)),
+		slog.Int("status", status),
+		slog.String("error", reason.Error()),
+	}
+	if p != nil {
+		attrs = append(attrs,
+			slog.String("subject", p.Subject),
+			slog.String("role", string(p.Role)),
+			slog.String("auth_method", p.Method),
+		)
+	}
+	a.logger.WarnContext(r.Context(), "access denied", attrs...)
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="get_order"`)
+		writeError(w, r, status, problem.CodeUnauthorized, "missing or invalid credentials")
+		return
+	}
+	writeError(w, r, status, problem.CodeForbidden, "role "+string(required)+" is required")
+}
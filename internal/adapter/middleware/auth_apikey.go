@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator проверяет ключ из заголовка X-API-Key, ключи хранятся в виде sha256
+type APIKeyAuthenticator struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// NewAPIKeyAuthenticator принимает записи вида "subject:role:key"
+func NewAPIKeyAuthenticator(entries []string) (*APIKeyAuthenticator, error) {
+	keys := make(map[[sha256.Size]byte]Principal, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("api key entry must be subject:role:key")
+		}
+
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("api key %s: %w", parts[0], err)
+		}
+
+		hash := sha256.Sum256([]byte(parts[2]))
+		if _, ok := keys[hash]; ok {
+			return nil, fmt.Errorf("api key %s: duplicate key", parts[0])
+		}
+
+		keys[hash] = Principal{Subject: parts[0], Role: role, Method: "apikey"}
+	}
+
+	return &APIKeyAuthenticator{keys: keys}, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &principal, nil
+}
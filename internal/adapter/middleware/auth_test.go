@@ -0,0 +1,178 @@
+package middleware_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newAuthRouter(t *testing.T, authenticators ...middleware.Authenticator) *mux.Router {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth := middleware.NewAuth(logger, middleware.RoutePolicy{
+		"GET /public":         middleware.RoleAnonymous,
+		"GET /order/{uid}":    middleware.RoleViewer,
+		"POST /orders/import": middleware.RoleAdmin,
+	}, authenticators...)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		p := middleware.PrincipalFromContext(r.Context())
+		if p != nil {
+			_, _ = io.WriteString(w, p.Subject)
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Use(auth.Middleware)
+	router.HandleFunc("/public", ok).Methods("GET")
+	router.HandleFunc("/order/{uid}", ok).Methods("GET")
+	router.HandleFunc("/orders/import", ok).Methods("POST")
+	router.HandleFunc("/unlisted", ok).Methods("GET")
+
+	return router
+}
+
+func serve(router http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v[0])
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuth_APIKeyRoles(t *testing.T) {
+	apiKeys, err := middleware.NewAPIKeyAuthenticator([]string{"ui:viewer:v-key", "ops:admin:a-key"})
+	require.NoError(t, err)
+	router := newAuthRouter(t, apiKeys)
+
+	viewer := http.Header{middleware.APIKeyHeader: {"v-key"}}
+	admin := http.Header{middleware.APIKeyHeader: {"a-key"}}
+
+	rec := serve(router, "GET", "/public", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(router, "GET", "/order/1", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	rec = serve(router, "GET", "/order/1", http.Header{middleware.APIKeyHeader: {"wrong"}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serve(router, "GET", "/order/1", viewer)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ui", rec.Body.String())
+
+	rec = serve(router, "POST", "/orders/import", viewer)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve(router, "POST", "/orders/import", admin)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// маршрут без политики требует admin
+	rec = serve(router, "GET", "/unlisted", viewer)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestNewAPIKeyAuthenticator_RejectsBadEntries(t *testing.T) {
+	_, err := middleware.NewAPIKeyAuthenticator([]string{"ui:root:key"})
+	assert.ErrorIs(t, err, middleware.ErrUnknownRole)
+
+	_, err = middleware.NewAPIKeyAuthenticator([]string{"ui-viewer"})
+	assert.Error(t, err)
+
+	_, err = middleware.NewAPIKeyAuthenticator([]string{"a:viewer:k", "b:admin:k"})
+	assert.Error(t, err)
+}
+
+func TestAuth_JWTHS256(t *testing.T) {
+	jwtAuth, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{
+		HMACSecret: "secret",
+		Issuer:     "get_order",
+	})
+	require.NoError(t, err)
+	router := newAuthRouter(t, jwtAuth)
+
+	sign := func(claims jwt.MapClaims, secret string) http.Header {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	rec := serve(router, "GET", "/order/1", sign(jwt.MapClaims{"sub": "alice", "role": "support", "iss": "get_order", "exp": exp}, "secret"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", rec.Body.String())
+
+	rec = serve(router, "GET", "/order/1", sign(jwt.MapClaims{"sub": "alice", "role": "support", "iss": "get_order", "exp": exp}, "other"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serve(router, "GET", "/order/1", sign(jwt.MapClaims{"sub": "alice", "role": "support", "iss": "evil", "exp": exp}, "secret"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serve(router, "GET", "/order/1", sign(jwt.MapClaims{"sub": "alice", "role": "support", "iss": "get_order"}, "secret"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serve(router, "POST", "/orders/import", sign(jwt.MapClaims{"sub": "bob", "role": []string{"viewer", "admin"}, "iss": "get_order", "exp": exp}, "secret"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuth_JWTRS256FromJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	jwtAuth, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{JWKSFile: path})
+	require.NoError(t, err)
+	router := newAuthRouter(t, jwtAuth)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":  "svc",
+		"role": "admin",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	rec := serve(router, "POST", "/orders/import", http.Header{"Authorization": {"Bearer " + signed}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// HS256 не настроен, токен с ним не принимается
+	hs, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "svc", "role": "admin", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	rec = serve(router, "POST", "/orders/import", http.Header{"Authorization": {"Bearer " + hs}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
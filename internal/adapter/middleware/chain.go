@@ -0,0 +1,13 @@
+package middleware
+
+import "net/http"
+
+// Chain собирает несколько middleware в одну, первая в списке оборачивает остальные
+func Chain(middlewares ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
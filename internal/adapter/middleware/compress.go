@@ -0,0 +1,292 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+type CompressionOptions struct {
+	Encodings []string // в порядке предпочтения сервера
+	MinSize   int      // ответы меньше MinSize байт отдаются без сжатия
+	Types     []string // сжимаемые Content-Type без параметров
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	EncodingZstd: {New: func() any {
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	}},
+}
+
+// CompressionMiddleware сжимает ответы кодировкой из Accept-Encoding с наибольшим приоритетом сервера.
+// Решение принимается по Content-Type и первым MinSize байтам, поэтому SSE, WebSocket, Parquet
+// и короткие ответы проходят как есть, а Flush стримов продолжает работать
+func CompressionMiddleware(opts CompressionOptions) (func(http.Handler) http.Handler, error) {
+	for _, encoding := range opts.Encodings {
+		if _, ok := encoderPools[encoding]; !ok {
+			return nil, fmt.Errorf("unsupported compression encoding %q", encoding)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), opts.Encodings)
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, opts: opts}
+			if header := r.Header.Get("If-None-Match"); header != "" {
+				// обработчик сравнивает If-None-Match со своим ETag несжатого ответа
+				if stripped, ok := stripETagEncoding(header, encoding); ok {
+					r = r.Clone(r.Context())
+					r.Header.Set("If-None-Match", stripped)
+					cw.encodedETag = true
+				}
+			}
+			next.ServeHTTP(cw, r)
+			// при панике не закрываем: недописанный ответ не должен уйти клиенту как успешный
+			_ = cw.Close()
+		})
+	}, nil
+}
+
+// negotiateEncoding выбирает первую из supported кодировок, которую клиент принимает с q > 0
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[name] = q > 0
+	}
+
+	for _, encoding := range supported {
+		if ok, listed := accepted[encoding]; listed {
+			if ok {
+				return encoding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return encoding
+		}
+	}
+
+	return ""
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	opts        CompressionOptions
+	encodedETag bool // клиент прислал ETag сжатого варианта
+
+	status      int
+	buf         []byte
+	decided     bool
+	passthrough bool
+	enc         encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	// информационные ответы уходят сразу и не фиксируют статус
+	if cw.decided || code < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	cw.status = code
+
+	if code == http.StatusNotModified && cw.encodedETag {
+		cw.alterETag()
+	}
+
+	if !cw.compressible() || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		cw.startPassthrough()
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if !cw.compressible() {
+			cw.startPassthrough()
+		}
+	}
+
+	if cw.decided {
+		if cw.passthrough {
+			return cw.ResponseWriter.Write(b)
+		}
+		return cw.enc.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) < cw.opts.MinSize {
+		return len(b), nil
+	}
+
+	if err := cw.startCompression(); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush означает стриминг: ждать MinSize байт дальше нельзя, поэтому сжатие начинается сразу
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if cw.compressible() {
+			_ = cw.startCompression()
+		} else {
+			cw.startPassthrough()
+		}
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close дописывает сжатый поток, а недобравший MinSize ответ отдает без сжатия
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return nil
+		}
+		cw.startPassthrough()
+		return nil
+	}
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+	cw.enc.Reset(io.Discard)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return slices.Contains(cw.opts.Types, mediaType)
+}
+
+func (cw *compressWriter) startPassthrough() {
+	cw.decided = true
+	cw.passthrough = true
+
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buf) > 0 {
+		_, _ = cw.ResponseWriter.Write(cw.buf)
+		cw.buf = nil
+	}
+}
+
+func (cw *compressWriter) startCompression() error {
+	cw.decided = true
+
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+	cw.alterETag()
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.enc = encoderPools[cw.encoding].Get().(encoder)
+	cw.enc.Reset(cw.ResponseWriter)
+
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.enc.Write(buf)
+	return err
+}
+
+// alterETag добавляет к strong ETag суффикс кодировки: сжатый ответ - другое представление,
+// и его ETag не должен совпадать с ETag несжатого
+func (cw *compressWriter) alterETag() {
+	etag := cw.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") || !strings.HasSuffix(etag, `"`) {
+		return
+	}
+	cw.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+cw.encoding+`"`)
+}
+
+// stripETagEncoding убирает из If-None-Match суффикс, добавленный alterETag, ok - суффикс был
+func stripETagEncoding(header, encoding string) (string, bool) {
+	suffix := "-" + encoding + `"`
+	stripped := false
+
+	tags := strings.Split(header, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if strings.HasSuffix(tag, suffix) {
+			tag = strings.TrimSuffix(tag, suffix) + `"`
+			stripped = true
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", "), stripped
+}
@@ -0,0 +1,112 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newCompressed(t *testing.T, contentType, body string) http.Handler {
+	t.Helper()
+
+	compression, err := middleware.CompressionMiddleware(middleware.CompressionOptions{
+		Encodings: []string{middleware.EncodingZstd, middleware.EncodingGzip},
+		MinSize:   64,
+		Types:     []string{"application/json"},
+	})
+	require.NoError(t, err)
+
+	return compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	}))
+}
+
+func doEncoded(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompression_NegotiatesEncoding(t *testing.T) {
+	body := `{"items":"` + strings.Repeat("a", 500) + `"}`
+	handler := newCompressed(t, "application/json; charset=utf-8", body)
+
+	rec := doEncoded(handler, "gzip, deflate, br, zstd")
+	require.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+	dec, err := zstd.NewReader(rec.Body)
+	require.NoError(t, err)
+	got, err := io.ReadAll(dec)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+
+	// zstd запрещен клиентом через q=0
+	rec = doEncoded(handler, "gzip, zstd;q=0")
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	got, err = io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+	assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+
+	rec = doEncoded(handler, "br")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rec.Body.String())
+}
+
+func TestCompression_SkipsSmallAndStreamingResponses(t *testing.T) {
+	rec := doEncoded(newCompressed(t, "application/json", `{"ok":true}`), "gzip")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"ok":true}`, rec.Body.String())
+
+	events := "data: " + strings.Repeat("x", 500) + "\n\n"
+	rec = doEncoded(newCompressed(t, "text/event-stream", events), "gzip")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, events, rec.Body.String())
+}
+
+func TestCompressionMiddleware_UnknownEncoding(t *testing.T) {
+	_, err := middleware.CompressionMiddleware(middleware.CompressionOptions{Encodings: []string{"br"}})
+	assert.Error(t, err)
+}
+
+func TestCompression_AltersETagPerEncoding(t *testing.T) {
+	body := strings.Repeat("a", 500)
+	compression, err := middleware.CompressionMiddleware(middleware.CompressionOptions{
+		Encodings: []string{middleware.EncodingGzip},
+		MinSize:   64,
+		Types:     []string{"application/json"},
+	})
+	require.NoError(t, err)
+
+	handler := compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	}))
+
+	rec := doEncoded(handler, "gzip")
+	assert.Equal(t, `"v1-gzip"`, rec.Header().Get("ETag"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", `"v1-gzip"`)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, `"v1-gzip"`, rec.Header().Get("ETag"))
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net/http"
+)
+
+// MethodPolicy - требуемая роль по полному имени метода gRPC, например "/order.v1.OrderService/GetOrder"
+type MethodPolicy map[string]Role
+
+func (p MethodPolicy) required(method string) Role {
+	if role, ok := p[method]; ok {
+		return role
+	}
+
+	// метод без политики доступен только администратору
+	return RoleAdmin
+}
+
+// GRPCAuth - аналог Auth для gRPC: учетные данные берутся из метаданных authorization и x-api-key
+// и проверяются теми же аутентификаторами, что и в HTTP API
+type GRPCAuth struct {
+	logger         *slog.Logger
+	policy         MethodPolicy
+	authenticators []Authenticator
+}
+
+func NewGRPCAuth(logger *slog.Logger, policy MethodPolicy, authenticators ...Authenticator) *GRPCAuth {
+	return &GRPCAuth{
+		logger:         logger,
+		policy:         policy,
+		authenticators: authenticators,
+	}
+}
+
+func (a *GRPCAuth) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *GRPCAuth) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &requestIDStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorize возвращает контекст с клиентом запроса или ошибку Unauthenticated/PermissionDenied
+func (a *GRPCAuth) authorize(ctx context.Context, method string) (context.Context, error) {
+	required := a.policy.required(method)
+
+	principal, err := authenticate(a.authenticators, metadataRequest(ctx))
+	if err != nil && !errors.Is(err, ErrNoCredentials) {
+		return nil, a.deny(ctx, method, codes.Unauthenticated, required, nil, err)
+	}
+
+	if required == RoleAnonymous {
+		return WithPrincipal(ctx, principal), nil
+	}
+
+	if principal == nil {
+		return nil, a.deny(ctx, method, codes.Unauthenticated, required, nil, ErrNoCredentials)
+	}
+
+	if !principal.Role.Allows(required) {
+		return nil, a.deny(ctx, method, codes.PermissionDenied, required, principal, errors.New("insufficient role"))
+	}
+
+	return WithPrincipal(ctx, principal), nil
+}
+
+func (a *GRPCAuth) deny(ctx context.Context, method string, code codes.Code, required Role, p *Principal, reason error) error {
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("required_role", string(required)),
+		slog.String("code", code.String()),
+		slog.String("error", reason.Error()),
+	}
+	if p != nil {
+		attrs = append(attrs,
+			slog.String("subject", p.Subject),
+			slog.String("role", string(p.Role)),
+			slog.String("auth_method", p.Method),
+		)
+	}
+	a.logger.WarnContext(ctx, "access denied", attrs...)
+
+	if code == codes.Unauthenticated {
+		return status.Error(code, "missing or invalid credentials")
+	}
+	return status.Error(code, "role "+string(required)+" is required")
+}
+
+// metadataRequest переносит метаданные gRPC в заголовки HTTP-запроса, чтобы переиспользовать Authenticator
+func metadataRequest(ctx context.Context) *http.Request {
+	r := (&http.Request{Header: http.Header{}}).WithContext(ctx)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{"Authorization", APIKeyHeader} {
+		if values := md.Get(key); len(values) > 0 {
+			r.Header.Set(key, values[0])
+		}
+	}
+
+	return r
+}
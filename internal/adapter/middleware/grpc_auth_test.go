@@ -0,0 +1,59 @@
+package middleware_test
+
+import (
+	"context"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestGRPCAuth_UnaryInterceptor(t *testing.T) {
+	apiKeys, err := middleware.NewAPIKeyAuthenticator([]string{"ui:viewer:v-key", "ops:admin:a-key"})
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	interceptor := middleware.NewGRPCAuth(logger, middleware.MethodPolicy{
+		"/order.v1.OrderService/GetOrder":    middleware.RoleViewer,
+		"/order.v1.OrderService/SubmitOrder": middleware.RoleSupport,
+	}, apiKeys).UnaryInterceptor()
+
+	handler := func(ctx context.Context, _ any) (any, error) {
+		return middleware.PrincipalFromContext(ctx).Subject, nil
+	}
+
+	call := func(method string, md metadata.MD) (any, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		return interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		code   codes.Code
+	}{
+		{"no credentials", "/order.v1.OrderService/GetOrder", nil, codes.Unauthenticated},
+		{"invalid key", "/order.v1.OrderService/GetOrder", metadata.Pairs("x-api-key", "nope"), codes.Unauthenticated},
+		{"viewer reads", "/order.v1.OrderService/GetOrder", metadata.Pairs("x-api-key", "v-key"), codes.OK},
+		{"viewer submits", "/order.v1.OrderService/SubmitOrder", metadata.Pairs("x-api-key", "v-key"), codes.PermissionDenied},
+		{"admin submits", "/order.v1.OrderService/SubmitOrder", metadata.Pairs("x-api-key", "a-key"), codes.OK},
+		{"unlisted method", "/order.v1.OrderService/DeleteOrder", metadata.Pairs("x-api-key", "v-key"), codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := call(tt.method, tt.md)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.NotEmpty(t, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+func LoggingUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = withGRPCRequestID(ctx)
+
+		logger.InfoContext(ctx, "incoming rpc",
+			slog.String("method", info.FullMethod),
+		)
+
+		resp, err := handler(ctx, req)
+
+		logger.InfoContext(ctx, "rpc completed",
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+		)
+
+		return resp, err
+	}
+}
+
+func LoggingStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := withGRPCRequestID(ss.Context())
+
+		logger.InfoContext(ctx, "incoming stream",
+			slog.String("method", info.FullMethod),
+		)
+
+		err := handler(srv, &requestIDStream{ServerStream: ss, ctx: ctx})
+
+		logger.InfoContext(ctx, "stream completed",
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+		)
+
+		return err
+	}
+}
+
+// withGRPCRequestID - аналог RequestIDMiddleware для gRPC: id берется из метаданных x-request-id
+// или создается новый и возвращается клиенту в заголовке ответа
+func withGRPCRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(strings.ToLower(requestIDHeader)); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestIDHeader), id))
+	return WithRequestID(ctx, id)
+}
+
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
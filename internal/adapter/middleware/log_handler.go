@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ContextHandler дописывает в каждую запись id запроса из контекста, поэтому логгер, переданный
+// в контроллеры, сервис и репозиторий, коррелирует записи без явной передачи id.
+// Работает только для вызовов с контекстом: InfoContext, WarnContext и т.д.
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
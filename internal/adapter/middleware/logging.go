@@ -11,17 +11,20 @@ func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			logger.Info("incoming request",
+			logger.InfoContext(r.Context(), "incoming request",
 				slog.String("method", r.Method),
 				slog.String("url", r.URL.String()),
 				slog.String("remote", r.RemoteAddr),
 			)
 
-			next.ServeHTTP(w, r)
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r)
 
-			logger.Info("request completed",
+			logger.InfoContext(r.Context(), "request completed",
 				slog.String("method", r.Method),
 				slog.String("url", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.Bytes()),
 				slog.Duration("duration", time.Since(start)),
 			)
 		})
@@ -0,0 +1,66 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggingMiddleware_RequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(middleware.NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+
+	handler := middleware.RequestIDMiddleware()(middleware.LoggingMiddleware(logger)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// логгер сервиса получает id запроса только из контекста
+			logger.InfoContext(r.Context(), "inside handler")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("hello"))
+		}),
+	))
+
+	req := httptest.NewRequest("POST", "/orders", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-1", rec.Header().Get("X-Request-ID"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	for _, line := range lines {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "req-1", entry["request_id"])
+	}
+
+	var completed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &completed))
+	assert.Equal(t, "request completed", completed["msg"])
+	assert.EqualValues(t, http.StatusCreated, completed["status"])
+	assert.EqualValues(t, 5, completed["bytes"])
+}
+
+func TestRequestIDMiddleware_RejectsInvalidID(t *testing.T) {
+	var got string
+	handler := middleware.RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = middleware.RequestIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.NotEmpty(t, got)
+	assert.NotContains(t, got, " ")
+	assert.Equal(t, got, rec.Header().Get("X-Request-ID"))
+}
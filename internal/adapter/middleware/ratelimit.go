@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"golang.org/x/time/rate"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit - параметры token bucket: RPS запросов в секунду и запас Burst, RPS <= 0 - без ограничения
+type Limit struct {
+	RPS   float64
+	Burst int
+}
+
+// ParseLimit разбирает лимит вида "rps:burst"
+func ParseLimit(s string) (Limit, error) {
+	rps, burst, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q must be rps:burst", s)
+	}
+
+	var limit Limit
+	var err error
+	if limit.RPS, err = strconv.ParseFloat(rps, 64); err != nil {
+		return Limit{}, fmt.Errorf("limit %q: %w", s, err)
+	}
+	if limit.Burst, err = strconv.Atoi(burst); err != nil {
+		return Limit{}, fmt.Errorf("limit %q: %w", s, err)
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return limit, nil
+}
+
+// ParseRouteLimits разбирает записи вида "GET /order/{uid}=10:20", ключи - как в RoutePolicy
+func ParseRouteLimits(entries []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("route limit %q must be route=rps:burst", entry)
+		}
+
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.TrimSpace(route)] = limit
+	}
+
+	return limits, nil
+}
+
+type RateLimitOptions struct {
+	Default           Limit
+	Routes            map[string]Limit
+	TrustProxy        bool          // брать IP клиента из X-Forwarded-For
+	IdleTTL           time.Duration // через сколько без запросов забывать состояние клиента
+	NotFoundThreshold int           // сколько 404 за NotFoundWindow приводят к блокировке, 0 - не блокировать
+	NotFoundWindow    time.Duration
+	BlockDuration     time.Duration
+	// Identify определяет клиента по учетным данным, пока лимитер стоит перед аутентификацией,
+	// nil - учетных данных нет или они неверны, клиент считается по IP
+	Identify func(r *http.Request) *Principal
+}
+
+type notFoundStrikes struct {
+	count int
+	since time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter ограничивает частоту запросов клиента к каждому маршруту и блокирует клиентов,
+// которые получают слишком много 404 (перебор uid заказов). Клиент - subject из аутентификации,
+// а для анонимных запросов - IP
+type RateLimiter struct {
+	logger *slog.Logger
+	opts   RateLimitOptions
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	strikes   map[string]*notFoundStrikes
+	blocked   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewRateLimiter(logger *slog.Logger, opts RateLimitOptions) *RateLimiter {
+	return &RateLimiter{
+		logger:  logger,
+		opts:    opts,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		strikes: make(map[string]*notFoundStrikes),
+		blocked: make(map[string]time.Time),
+	}
+}
+
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PrincipalFromContext(r.Context()) == nil && l.opts.Identify != nil {
+			if p := l.opts.Identify(r); p != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), p))
+			}
+		}
+
+		client := l.clientKey(r)
+		route, limit := l.routeLimit(r)
+
+		if wait, ok := l.allow(client, route, limit); !ok {
+			l.logger.WarnContext(r.Context(), "rate limit exceeded",
+				slog.String("client", client),
+				slog.String("route", route),
+				slog.Duration("retry_after", wait),
+			)
+			writeRetryAfter(w, r, wait)
+			return
+		}
+
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		if rec.Status() == http.StatusNotFound {
+			l.notFound(client)
+		}
+	})
+}
+
+// allow проверяет блок-лист и bucket клиента, при отказе возвращает, через сколько повторить
+func (l *RateLimiter) allow(client, route string, limit Limit) (time.Duration, bool) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	if until, ok := l.blocked[client]; ok {
+		if now.Before(until) {
+			return until.Sub(now), false
+		}
+		delete(l.blocked, client)
+	}
+
+	if limit.RPS <= 0 {
+		return 0, true
+	}
+
+	key := route + "|" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, false
+	}
+
+	return 0, true
+}
+
+func (l *RateLimiter) notFound(client string) {
+	if l.opts.NotFoundThreshold <= 0 {
+		return
+	}
+
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.strikes[client]
+	if !ok || now.Sub(s.since) > l.opts.NotFoundWindow {
+		s = &notFoundStrikes{since: now}
+		l.strikes[client] = s
+	}
+	s.count++
+
+	if s.count >= l.opts.NotFoundThreshold {
+		delete(l.strikes, client)
+		l.blocked[client] = now.Add(l.opts.BlockDuration)
+
+		l.logger.Warn("client blocked for too many not found responses",
+			slog.String("client", client),
+			slog.Int("not_found", s.count),
+			slog.Duration("window", l.opts.NotFoundWindow),
+			slog.Duration("block", l.opts.BlockDuration),
+		)
+	}
+}
+
+// sweep раз в IdleTTL удаляет состояние клиентов, которые давно не приходили
+func (l *RateLimiter) sweep(now time.Time) {
+	if l.opts.IdleTTL <= 0 || now.Sub(l.lastSweep) < l.opts.IdleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > l.opts.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	for client, s := range l.strikes {
+		if now.Sub(s.since) > l.opts.NotFoundWindow {
+			delete(l.strikes, client)
+		}
+	}
+	for client, until := range l.blocked {
+		if !now.Before(until) {
+			delete(l.blocked, client)
+		}
+	}
+}
+
+func (l *RateLimiter) routeLimit(r *http.Request) (string, Limit) {
+	route, limit, ok := routeValue(l.opts.Routes, r)
+	if !ok {
+		return route, l.opts.Default
+	}
+	return route, limit
+}
+
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if p := PrincipalFromContext(r.Context()); p != nil {
+		return p.Method + ":" + p.Subject
+	}
+
+	return "ip:" + ClientIP(r, l.opts.TrustProxy)
+}
+
+// ClientIP - адрес клиента, с trustProxy - первый адрес из X-Forwarded-For
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeRetryAfter(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, "too many requests, retry after "+strconv.Itoa(seconds)+"s")
+}
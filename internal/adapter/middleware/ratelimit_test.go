@@ -0,0 +1,162 @@
+package middleware_test
+
+import (
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newLimitedRouter(opts middleware.RateLimitOptions) *mux.Router {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := middleware.NewRateLimiter(logger, opts)
+
+	router := mux.NewRouter()
+	// subject из заголовка вместо настоящей аутентификации
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subject := r.Header.Get("X-Subject"); subject != "" {
+				r = r.WithContext(middleware.WithPrincipal(r.Context(), &middleware.Principal{Subject: subject, Method: "apikey"}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Use(limiter.Middleware)
+
+	router.HandleFunc("/order/{uid}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["uid"] == "missing" {
+			http.NotFound(w, r)
+		}
+	}).Methods("GET")
+	router.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+
+	return router
+}
+
+func do(router http.Handler, method, target, remote, subject string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remote
+	if subject != "" {
+		req.Header.Set("X-Subject", subject)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiter_PerRouteAndClient(t *testing.T) {
+	router := newLimitedRouter(middleware.RateLimitOptions{
+		Default: middleware.Limit{RPS: 100, Burst: 100},
+		Routes: map[string]middleware.Limit{
+			"GET /order/{uid}": {RPS: 0.01, Burst: 2},
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		rec := do(router, "GET", "/order/1", "10.0.0.1:1000", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	rec := do(router, "GET", "/order/1", "10.0.0.1:1001", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.Greater(t, retryAfter, 0)
+
+	// другой маршрут того же клиента считается отдельно
+	rec = do(router, "POST", "/orders", "10.0.0.1:1002", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// другой IP - другой bucket
+	rec = do(router, "GET", "/order/1", "10.0.0.2:1000", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// клиент с API-ключом считается по subject, а не по IP
+	rec = do(router, "GET", "/order/1", "10.0.0.1:1003", "partner")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRateLimiter_BlocksAfterManyNotFound(t *testing.T) {
+	router := newLimitedRouter(middleware.RateLimitOptions{
+		Default:           middleware.Limit{RPS: 100, Burst: 100},
+		NotFoundThreshold: 3,
+		NotFoundWindow:    time.Minute,
+		BlockDuration:     time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		rec := do(router, "GET", "/order/missing", "10.0.0.1:1000", "")
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	rec := do(router, "GET", "/order/1", "10.0.0.1:1000", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	rec = do(router, "GET", "/order/1", "10.0.0.2:1000", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	limits, err := middleware.ParseRouteLimits([]string{"GET /order/{uid}=10:20", " POST /orders = 0.5:1 "})
+	require.NoError(t, err)
+	assert.Equal(t, middleware.Limit{RPS: 10, Burst: 20}, limits["GET /order/{uid}"])
+	assert.Equal(t, middleware.Limit{RPS: 0.5, Burst: 1}, limits["POST /orders"])
+
+	_, err = middleware.ParseRouteLimits([]string{"GET /order/{uid}"})
+	assert.Error(t, err)
+
+	_, err = middleware.ParseLimit("fast")
+	assert.Error(t, err)
+}
+
+func TestRateLimiter_BeforeAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	apiKeys, err := middleware.NewAPIKeyAuthenticator([]string{"ui:viewer:v-key"})
+	require.NoError(t, err)
+	auth := middleware.NewAuth(logger, middleware.RoutePolicy{
+		"GET /order/{uid}": middleware.RoleViewer,
+	}, apiKeys)
+	limiter := middleware.NewRateLimiter(logger, middleware.RateLimitOptions{
+		Default:  middleware.Limit{RPS: 0.01, Burst: 2},
+		Identify: auth.Identify,
+	})
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware)
+	router.Use(auth.Middleware)
+	router.HandleFunc("/order/{uid}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, middleware.PrincipalFromContext(r.Context()).Subject)
+	}).Methods("GET")
+
+	request := func(remote, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/order/1", nil)
+		req.RemoteAddr = remote
+		if key != "" {
+			req.Header.Set(middleware.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// перебор ключей ограничивается по IP еще до проверки прав
+	for i := 0; i < 2; i++ {
+		rec := request("10.0.0.1:1000", "wrong-"+strconv.Itoa(i))
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:1000", "wrong-2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:1000", "").Code)
+
+	// верный ключ считается по subject, аутентификация переиспользует найденного клиента
+	rec := request("10.0.0.1:1000", "v-key")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ui", rec.Body.String())
+}
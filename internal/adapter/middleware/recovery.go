@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoveryMiddleware перехватывает панику обработчика, пишет ее в лог со стеком и, если ответ
+// еще не начат, отвечает 500. http.ErrAbortHandler пробрасывается дальше - им обработчик сам
+// обрывает соединение
+func RecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newResponseRecorder(w)
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				logger.ErrorContext(r.Context(), "panic recovered",
+					slog.String("method", r.Method),
+					slog.String("url", r.URL.Path),
+					slog.String("panic", fmt.Sprint(p)),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				if rec.status == 0 {
+					writeInternalError(rec, r)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
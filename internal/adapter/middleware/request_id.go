@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+const (
+	requestIDHeader    = problem.RequestIDHeader
+	maxRequestIDLength = 128
+)
+
+type requestIDCtxKey struct{}
+
+// RequestIDMiddleware берет id запроса из X-Request-ID или создает новый,
+// кладет его в контекст и возвращает клиенту в том же заголовке
+func RequestIDMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+
+			w.Header().Set(requestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	return uuid.NewString()
+}
+
+// validRequestID пропускает только короткие id из печатных ASCII-символов, чтобы их можно было писать в логи
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bufio"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"net"
+	"net/http"
+)
+
+// responseRecorder запоминает статус и размер ответа. Flush и Hijack пробрасываются в исходный writer,
+// поэтому SSE и WebSocket работают и за обернутым writer
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w}
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.status == 0 {
+		rr.status = code
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Status - код ответа, 200 если обработчик ничего не записал
+func (rr *responseRecorder) Status() int {
+	if rr.status == 0 {
+		return http.StatusOK
+	}
+	return rr.status
+}
+
+func (rr *responseRecorder) Bytes() int64 {
+	return rr.bytes
+}
+
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+func (rr *responseRecorder) Flush() {
+	_ = http.NewResponseController(rr.ResponseWriter).Flush()
+}
+
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rr.ResponseWriter).Hijack()
+	if err == nil && rr.status == 0 {
+		rr.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code problem.Code, detail string) {
+	problem.Write(w, r, problem.New(status, code, detail))
+}
+
+func writeInternalError(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, problem.Internal())
+}
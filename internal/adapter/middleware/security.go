@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SecurityHeadersOptions struct {
+	ContentSecurityPolicy string        // пусто - заголовок не ставится
+	HSTSMaxAge            time.Duration // 0 - без Strict-Transport-Security, включать только за TLS
+}
+
+// SecurityHeadersMiddleware добавляет к ответам заголовки, запрещающие sniffing типа, встраивание во фреймы
+// и передачу Referer
+func SecurityHeadersMiddleware(opts SecurityHeadersOptions) func(http.Handler) http.Handler {
+	hsts := ""
+	if opts.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+			if opts.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", opts.ContentSecurityPolicy)
+			}
+			if hsts != "" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"github.com/folivorra/get_order/internal/adapter/middleware"
+	"github.com/folivorra/get_order/internal/adapter/problem"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := middleware.Chain(
+		middleware.RequestIDMiddleware(),
+		middleware.RecoveryMiddleware(logger),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/order/1", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	assert.Equal(t, problem.CodeInternal, p.Code)
+	assert.NotEmpty(t, p.RequestID)
+	assert.NotContains(t, p.Detail, "boom")
+}
+
+func TestTimeoutMiddleware_PerRoute(t *testing.T) {
+	timeouts, err := middleware.ParseRouteTimeouts([]string{"GET /orders/export=0s"})
+	require.NoError(t, err)
+
+	deadlines := map[string]bool{}
+	router := mux.NewRouter()
+	router.Use(middleware.TimeoutMiddleware(time.Second, timeouts))
+	for _, path := range []string{"/order/{uid}", "/orders/export"} {
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			_, ok := r.Context().Deadline()
+			tpl, _ := mux.CurrentRoute(r).GetPathTemplate()
+			deadlines[tpl] = ok
+		}).Methods("GET")
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/order/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/export", nil))
+
+	assert.True(t, deadlines["/order/{uid}"])
+	assert.False(t, deadlines["/orders/export"])
+
+	_, err = middleware.ParseRouteTimeouts([]string{"GET /order/{uid}=soon"})
+	assert.Error(t, err)
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	handler := middleware.CORSMiddleware(middleware.CORSOptions{
+		AllowedOrigins: []string{"https://ui.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"X-API-Key"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("OPTIONS", "/order/1", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://ui.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "60", rec.Header().Get("Access-Control-Max-Age"))
+
+	req = httptest.NewRequest("GET", "/order/1", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	handler := middleware.SecurityHeadersMiddleware(middleware.SecurityHeadersOptions{
+		ContentSecurityPolicy: "default-src 'self'",
+		HSTSMaxAge:            24 * time.Hour,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "default-src 'self'", rec.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "max-age=86400; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+}
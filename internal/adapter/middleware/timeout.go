@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ParseRouteTimeouts разбирает записи вида "GET /orders/export=0s", ключи - как в RoutePolicy
+func ParseRouteTimeouts(entries []string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("route timeout %q must be route=duration", entry)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("route timeout %q: %w", entry, err)
+		}
+		timeouts[strings.TrimSpace(route)] = timeout
+	}
+
+	return timeouts, nil
+}
+
+// TimeoutMiddleware ограничивает время обработки запроса дедлайном контекста: запросы в БД
+// прерываются, а контроллер отвечает 504. Таймаут <= 0 - без ограничения (стримы, выгрузка, загрузка)
+func TimeoutMiddleware(defaultTimeout time.Duration, routes map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, timeout, ok := routeValue(routes, r)
+			if !ok {
+				timeout = defaultTimeout
+			}
+
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
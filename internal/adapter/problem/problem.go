@@ -0,0 +1,88 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType - тип ответа с ошибкой по RFC 7807
+const ContentType = "application/problem+json"
+
+// RequestIDHeader - заголовок с id запроса, его ставит middleware.RequestID, а Write копирует в ответ
+const RequestIDHeader = "X-Request-ID"
+
+// Code - стабильный код ошибки, на него можно опираться в клиентах вместо текста
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeInvalidUID           Code = "invalid_uid"
+	CodeInvalidJSON          Code = "invalid_json"
+	CodeBodyTooLarge         Code = "body_too_large"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeReconciliationFailed Code = "reconciliation_failed"
+	CodeUnsupportedVersion   Code = "unsupported_schema_version"
+	CodeSchemaNotFound       Code = "schema_not_found"
+	CodeOrderNotFound        Code = "order_not_found"
+	CodeNotAcceptable        Code = "not_acceptable"
+	CodeOrderAlreadyExists   Code = "order_already_exists"
+	CodeQuarantineNotFound   Code = "quarantine_not_found"
+	CodeItemNotFound         Code = "item_not_found"
+	CodeQuarantineResolved   Code = "quarantine_resolved"
+	CodeIdempotencyKeyReused Code = "idempotency_key_reused"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeRateLimited          Code = "rate_limited"
+	CodeTimeout              Code = "timeout"
+	CodeUnavailable          Code = "unavailable"
+	CodeInternal             Code = "internal"
+)
+
+const typePrefix = "urn:get_order:problem:"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Internal - ошибка сервера без подробностей: причина пишется в лог, клиенту уходит только request_id
+func Internal() *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "")
+}
+
+func (p *Problem) WithErrors(errs ...FieldError) *Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+// Write отправляет p клиенту, instance - путь запроса
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = w.Header().Get(RequestIDHeader)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,38 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/repository/postgres"
+	"github.com/folivorra/get_order/internal/usecase"
+	"net/http"
+)
+
+// FromServiceError переводит ошибку сервиса в problem со стабильным кодом.
+// ok = false - ошибка неизвестна, возвращается Internal, а причину вызывающий пишет в лог
+func FromServiceError(err error) (p *Problem, ok bool) {
+	switch {
+	case errors.Is(err, postgres.ErrOrderDoesNotExists):
+		return New(http.StatusNotFound, CodeOrderNotFound, "order does not exist"), true
+	case errors.Is(err, postgres.ErrOrderAlreadyExists):
+		return New(http.StatusConflict, CodeOrderAlreadyExists, "order with this order_uid already exists"), true
+	case errors.Is(err, usecase.ErrQuarantineNotFound):
+		return New(http.StatusNotFound, CodeQuarantineNotFound, "quarantined order does not exist"), true
+	case errors.Is(err, usecase.ErrItemNotFound):
+		return New(http.StatusNotFound, CodeItemNotFound, "item does not exist"), true
+	case errors.Is(err, usecase.ErrQuarantineResolved):
+		return New(http.StatusConflict, CodeQuarantineResolved, "quarantined order is already approved or rejected"), true
+	case errors.Is(err, usecase.ErrStatsRange):
+		return New(http.StatusBadRequest, CodeInvalidRequest, err.Error()), true
+	case errors.Is(err, usecase.ErrUnsupportedOrderVersion):
+		return New(http.StatusBadRequest, CodeUnsupportedVersion, err.Error()), true
+	case errors.Is(err, usecase.ErrIdempotencyKeyReused):
+		return New(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "idempotency key was already used with a different request body"), true
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(http.StatusGatewayTimeout, CodeTimeout, "request timed out"), true
+	case errors.Is(err, postgres.ErrMaxRetryAttemptsExceeded):
+		return New(http.StatusServiceUnavailable, CodeUnavailable, "storage is temporarily unavailable"), true
+	default:
+		return Internal(), false
+	}
+}
@@ -0,0 +1,106 @@
+package inmemory
+
+import (
+	"github.com/folivorra/get_order/internal/domain"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Subscription - подписка на новые заказы. Заказы из C общие для всех подписчиков, их нельзя изменять
+type Subscription struct {
+	C       <-chan *domain.Order
+	ch      chan *domain.Order
+	match   func(order *domain.Order) bool
+	dropped atomic.Int64
+	hub     *InMemOrderHub
+	once    sync.Once
+}
+
+// Dropped - сколько заказов не дошло до подписчика из-за переполненного буфера
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s)
+	})
+}
+
+// InMemOrderHub рассылает сохраненные заказы подписчикам. Publish никогда не блокируется:
+// если подписчик не успевает читать и его буфер заполнен, заказ для него отбрасывается
+type InMemOrderHub struct {
+	logger *slog.Logger
+	buffer int
+	subs   map[*Subscription]struct{}
+	mu     sync.RWMutex
+}
+
+func NewInMemOrderHub(logger *slog.Logger, buffer int) *InMemOrderHub {
+	return &InMemOrderHub{
+		logger: logger,
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+		mu:     sync.RWMutex{},
+	}
+}
+
+func (h *InMemOrderHub) Publish(order *domain.Order) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		if sub.match != nil && !sub.match(order) {
+			continue
+		}
+
+		select {
+		case sub.ch <- order:
+		default:
+			sub.dropped.Add(1)
+			h.logger.Debug("subscriber is too slow, order dropped",
+				slog.String("key", order.OrderUID.String()),
+			)
+		}
+	}
+}
+
+// Subscribe подписывает на новые заказы, match отбирает нужные заказы (nil - все заказы)
+func (h *InMemOrderHub) Subscribe(match func(order *domain.Order) bool) *Subscription {
+	ch := make(chan *domain.Order, h.buffer)
+	sub := &Subscription{
+		C:     ch,
+		ch:    ch,
+		match: match,
+		hub:   h,
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	h.logger.Debug("subscriber added",
+		slog.Int("subscribers", h.Len()),
+	)
+
+	return sub
+}
+
+func (h *InMemOrderHub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subs)
+}
+
+func (h *InMemOrderHub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	close(sub.ch)
+	h.mu.Unlock()
+
+	h.logger.Debug("subscriber removed",
+		slog.Int64("dropped", sub.Dropped()),
+	)
+}
@@ -0,0 +1,83 @@
+package inmemory_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/adapter/pubsub/inmemory"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+)
+
+func newTestHub(buffer int) *inmemory.InMemOrderHub {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return inmemory.NewInMemOrderHub(logger, buffer)
+}
+
+func TestHubDeliversToAllSubscribers(t *testing.T) {
+	hub := newTestHub(1)
+	s1 := hub.Subscribe(nil)
+	s2 := hub.Subscribe(nil)
+	defer s1.Close()
+	defer s2.Close()
+
+	order := &domain.Order{OrderUID: uuid.New()}
+	hub.Publish(order)
+
+	if got := <-s1.C; got != order {
+		t.Errorf("expected order in first subscription")
+	}
+	if got := <-s2.C; got != order {
+		t.Errorf("expected order in second subscription")
+	}
+}
+
+func TestHubDropsForSlowSubscriber(t *testing.T) {
+	hub := newTestHub(1)
+	sub := hub.Subscribe(nil)
+	defer sub.Close()
+
+	// второй заказ не помещается в буфер и не должен блокировать Publish
+	hub.Publish(&domain.Order{OrderUID: uuid.New()})
+	hub.Publish(&domain.Order{OrderUID: uuid.New()})
+
+	if sub.Dropped() != 1 {
+		t.Errorf("expected 1 dropped order, got %d", sub.Dropped())
+	}
+}
+
+func TestHubCloseUnsubscribes(t *testing.T) {
+	hub := newTestHub(1)
+	sub := hub.Subscribe(nil)
+	sub.Close()
+	sub.Close()
+
+	if hub.Len() != 0 {
+		t.Errorf("expected no subscribers, got %d", hub.Len())
+	}
+	if _, ok := <-sub.C; ok {
+		t.Errorf("expected closed channel")
+	}
+
+	hub.Publish(&domain.Order{OrderUID: uuid.New()})
+}
+
+func TestHubFiltersBySubscriberMatch(t *testing.T) {
+	hub := newTestHub(2)
+	sub := hub.Subscribe(func(order *domain.Order) bool {
+		return order.Delivery.Region == "north"
+	})
+	defer sub.Close()
+
+	north := &domain.Order{OrderUID: uuid.New(), Delivery: domain.Delivery{Region: "north"}}
+	hub.Publish(&domain.Order{OrderUID: uuid.New(), Delivery: domain.Delivery{Region: "south"}})
+	hub.Publish(north)
+
+	if got := <-sub.C; got != north {
+		t.Errorf("expected only matching order")
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("expected no more orders, got %d", len(sub.C))
+	}
+}
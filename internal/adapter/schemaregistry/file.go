@@ -0,0 +1,65 @@
+package schemaregistry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// расширение файла определяет тип схемы
+var fileTypes = map[string]SchemaType{
+	".avsc":  SchemaAvro,
+	".proto": SchemaProtobuf,
+	".json":  SchemaJSON,
+}
+
+// FileRegistry - локальный реестр для тестов и разработки без Schema Registry:
+// каталог с файлами <id>.avsc, <id>.proto и <id>.json, читается один раз при создании
+type FileRegistry struct {
+	schemas map[int]*Schema
+}
+
+var _ Registry = (*FileRegistry)(nil)
+
+func NewFileRegistry(dir string) (*FileRegistry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read schema registry dir: %w", err)
+	}
+
+	r := &FileRegistry{schemas: make(map[int]*Schema)}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		schemaType, ok := fileTypes[ext]
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ext))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("schema file %s: name must be a positive schema id", entry.Name())
+		}
+		if _, ok = r.schemas[id]; ok {
+			return nil, fmt.Errorf("schema file %s: duplicate schema id %d", entry.Name(), id)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		r.schemas[id] = &Schema{ID: id, Type: schemaType, Schema: string(data)}
+	}
+
+	return r, nil
+}
+
+func (r *FileRegistry) SchemaByID(_ context.Context, id int) (*Schema, error) {
+	schema, ok := r.schemas[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrSchemaNotFound, id)
+	}
+	return schema, nil
+}
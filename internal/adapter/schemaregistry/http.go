@@ -0,0 +1,93 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HTTPRegistry - клиент Confluent Schema Registry (GET /schemas/ids/{id}) с кэшем схем
+type HTTPRegistry struct {
+	baseURL  string
+	client   *http.Client
+	username string
+	password string
+
+	mu      sync.RWMutex
+	schemas map[int]*Schema
+}
+
+var _ Registry = (*HTTPRegistry)(nil)
+
+func NewHTTPRegistry(baseURL string, client *http.Client, username, password string) *HTTPRegistry {
+	return &HTTPRegistry{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		client:   client,
+		username: username,
+		password: password,
+		schemas:  make(map[int]*Schema),
+	}
+}
+
+type schemaResponse struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+func (r *HTTPRegistry) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	r.mu.RLock()
+	schema, ok := r.schemas[id]
+	r.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/schemas/ids/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRegistryUnavailable, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: id %d", ErrSchemaNotFound, id)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: status %d", ErrRegistryUnavailable, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("schema registry: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var body schemaResponse
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("schema registry: decode response: %w", err)
+	}
+	schemaType, err := parseSchemaType(body.SchemaType)
+	if err != nil {
+		return nil, err
+	}
+
+	schema = &Schema{ID: id, Type: schemaType, Schema: body.Schema}
+
+	r.mu.Lock()
+	r.schemas[id] = schema
+	r.mu.Unlock()
+
+	return schema, nil
+}
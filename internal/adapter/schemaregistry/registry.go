@@ -0,0 +1,67 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/config"
+	"net/http"
+	"strings"
+)
+
+type SchemaType string
+
+// типы схем в ответах Confluent Schema Registry, пустой schemaType означает AVRO
+const (
+	SchemaAvro     SchemaType = "AVRO"
+	SchemaProtobuf SchemaType = "PROTOBUF"
+	SchemaJSON     SchemaType = "JSON"
+)
+
+var (
+	ErrSchemaNotFound = errors.New("schema not found in registry")
+	// ErrRegistryUnavailable - временная ошибка реестра, запрос можно повторить
+	ErrRegistryUnavailable = errors.New("schema registry is unavailable")
+)
+
+// Schema - схема из реестра по id, id неизменяем, поэтому схемы можно кэшировать навсегда
+type Schema struct {
+	ID     int
+	Type   SchemaType
+	Schema string
+}
+
+// Registry отдает схему по id из заголовка сообщения в wire format
+type Registry interface {
+	SchemaByID(ctx context.Context, id int) (*Schema, error)
+}
+
+// NewRegistryFromConfig - file:///path - локальный реестр из каталога, http(s):// - Confluent Schema Registry.
+// Пустой SCHEMA_REGISTRY_URL - реестра нет, возвращается nil
+func NewRegistryFromConfig(cfg config.Config) (Registry, error) {
+	url := cfg.SchemaRegistryURL
+	switch {
+	case url == "":
+		return nil, nil
+	case strings.HasPrefix(url, "file://"):
+		return NewFileRegistry(strings.TrimPrefix(url, "file://"))
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"):
+		client := &http.Client{Timeout: cfg.SchemaRegistryTimeout}
+		return NewHTTPRegistry(url, client, cfg.SchemaRegistryUsername, cfg.SchemaRegistryPassword), nil
+	default:
+		return nil, fmt.Errorf("unsupported schema registry url %q, want file:// or http(s)://", url)
+	}
+}
+
+func parseSchemaType(value string) (SchemaType, error) {
+	switch SchemaType(strings.ToUpper(value)) {
+	case "", SchemaAvro:
+		return SchemaAvro, nil
+	case SchemaProtobuf:
+		return SchemaProtobuf, nil
+	case SchemaJSON:
+		return SchemaJSON, nil
+	default:
+		return "", fmt.Errorf("unknown schema type %q", value)
+	}
+}
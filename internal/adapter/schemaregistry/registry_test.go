@@ -0,0 +1,94 @@
+package schemaregistry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/adapter/schemaregistry"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRegistry(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.avsc"), []byte(`{"type":"string"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2.proto"), []byte(`syntax = "proto3";`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o600))
+
+	registry, err := schemaregistry.NewRegistryFromConfig(config.Config{SchemaRegistryURL: "file://" + dir})
+	require.NoError(t, err)
+
+	schema, err := registry.SchemaByID(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, schemaregistry.SchemaProtobuf, schema.Type)
+	assert.Equal(t, `syntax = "proto3";`, schema.Schema)
+
+	_, err = registry.SchemaByID(context.Background(), 3)
+	assert.ErrorIs(t, err, schemaregistry.ErrSchemaNotFound)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "avro.avsc"), []byte("{}"), 0o600))
+	_, err = schemaregistry.NewFileRegistry(dir)
+	assert.Error(t, err)
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		user, password, _ := r.BasicAuth()
+		switch {
+		case user != "reader" || password != "secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/schemas/ids/7":
+			_, _ = w.Write([]byte(`{"schema": "syntax = \"proto3\";", "schemaType": "PROTOBUF"}`))
+		case r.URL.Path == "/schemas/ids/8":
+			_, _ = w.Write([]byte(`{"schema": "{\"type\":\"string\"}"}`))
+		case r.URL.Path == "/schemas/ids/9":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	registry := schemaregistry.NewHTTPRegistry(server.URL+"/", server.Client(), "reader", "secret")
+
+	schema, err := registry.SchemaByID(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, schemaregistry.SchemaProtobuf, schema.Type)
+
+	// схема по id неизменна и берется из кэша
+	_, err = registry.SchemaByID(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	schema, err = registry.SchemaByID(ctx, 8)
+	require.NoError(t, err)
+	assert.Equal(t, schemaregistry.SchemaAvro, schema.Type)
+
+	_, err = registry.SchemaByID(ctx, 9)
+	assert.ErrorIs(t, err, schemaregistry.ErrRegistryUnavailable)
+
+	_, err = registry.SchemaByID(ctx, 10)
+	assert.ErrorIs(t, err, schemaregistry.ErrSchemaNotFound)
+
+	_, err = schemaregistry.NewHTTPRegistry(server.URL, server.Client(), "", "").SchemaByID(ctx, 7)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, schemaregistry.ErrSchemaNotFound)
+}
+
+func TestNewRegistryFromConfig(t *testing.T) {
+	registry, err := schemaregistry.NewRegistryFromConfig(config.Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, registry)
+
+	_, err = schemaregistry.NewRegistryFromConfig(config.Config{SchemaRegistryURL: "ftp://registry"})
+	assert.Error(t, err)
+}
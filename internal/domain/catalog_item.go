@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ItemAttributes - атрибуты товара, которые ведет каталог
+type ItemAttributes struct {
+	Name  string
+	Brand string
+	Size  string
+}
+
+// Diff - имена атрибутов, которые отличаются в other
+func (a ItemAttributes) Diff(other ItemAttributes) []string {
+	var fields []string
+	if a.Name != other.Name {
+		fields = append(fields, "name")
+	}
+	if a.Brand != other.Brand {
+		fields = append(fields, "brand")
+	}
+	if a.Size != other.Size {
+		fields = append(fields, "size")
+	}
+	return fields
+}
+
+// CatalogItem - товар каталога: артикул NmID в размере ChrtID с текущей версией атрибутов
+type CatalogItem struct {
+	NmID   int
+	ChrtID int
+	ItemAttributes
+	Version   int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Versions - история атрибутов по возрастанию версии, Conflicts - расхождения в порядке обнаружения
+	Versions  []ItemVersion
+	Conflicts []ItemConflict
+}
+
+// ItemVersion - атрибуты товара, действующие с ValidFrom (дата заказа, который их принес)
+type ItemVersion struct {
+	Version int
+	ItemAttributes
+	ItemUID   uuid.UUID
+	OrderUID  uuid.UUID
+	ValidFrom time.Time
+}
+
+// ItemConflict - заказ принес атрибуты, отличные от текущей версии каталога.
+// Applied - атрибуты стали новой версией, false - заказ старше текущей версии и каталог не изменился
+type ItemConflict struct {
+	NmID       int
+	ChrtID     int
+	ItemUID    uuid.UUID
+	OrderUID   uuid.UUID
+	Fields     []string
+	Current    ItemAttributes
+	Incoming   ItemAttributes
+	Applied    bool
+	DetectedAt time.Time
+}
@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// IdempotencyKey связывает ключ из заголовка Idempotency-Key с созданным по нему заказом
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	OrderUID    uuid.UUID
+	CreatedAt   time.Time
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"github.com/google/uuid"
+	"time"
 )
 
 type Order struct {
@@ -17,6 +18,9 @@ type Order struct {
 	DeliveryService   string
 	ShardKey          string
 	SmID              int
-	DateCreated       string
+	DateCreated       time.Time // в UTC
 	OofShard          string
+
+	// Reporting - итоги платежа в валюте отчетности, заполняются при чтении заказа, nil - курса нет
+	Reporting *Totals
 }
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// OrderFilter описывает выборку заказов, пустые поля не ограничивают выборку
+type OrderFilter struct {
+	DateFrom        time.Time
+	DateTo          time.Time
+	DeliveryService string
+	Region          string
+	Currency        string
+	Phone           string // телефон получателя, ищется по blind index
+	Email           string // email получателя, ищется по blind index
+	NmID            int    // артикул товара в составе заказа, 0 - любой
+}
+
+// OrderPage - keyset-пагинация по (date_created, order_uid), пустой After* означает первую страницу
+type OrderPage struct {
+	Limit            int
+	AfterDateCreated time.Time
+	AfterOrderUID    uuid.UUID
+}
@@ -2,13 +2,14 @@ package domain
 
 import "github.com/google/uuid"
 
+// OrderItem - цены в валюте платежа заказа, Sale - скидка в процентах
 type OrderItem struct {
 	OrderItemUID uuid.UUID
 	OrderUID     uuid.UUID
 	ItemUID      uuid.UUID
 	Item         *Item
-	Price        int
+	Price        Money
 	Sale         int
-	TotalPrice   int
+	TotalPrice   Money
 	Quantity     int
 }
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StatsBucket - размер интервала агрегации, интервалы выравниваются в UTC, неделя начинается с понедельника
+type StatsBucket string
+
+const (
+	StatsHour StatsBucket = "hour"
+	StatsDay  StatsBucket = "day"
+	StatsWeek StatsBucket = "week"
+)
+
+func ParseStatsBucket(value string) (StatsBucket, error) {
+	switch bucket := StatsBucket(value); bucket {
+	case StatsHour, StatsDay, StatsWeek:
+		return bucket, nil
+	default:
+		return "", fmt.Errorf("bucket %q: want hour, day or week", value)
+	}
+}
+
+func (b StatsBucket) Duration() time.Duration {
+	switch b {
+	case StatsHour:
+		return time.Hour
+	case StatsWeek:
+		return 7 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// Truncate - начало интервала с t. Отсчет time.Truncate идет от 0001-01-01, это понедельник
+func (b StatsBucket) Truncate(t time.Time) time.Time {
+	return t.UTC().Truncate(b.Duration())
+}
+
+// StatsDimension - поле, по которому группируется статистика
+type StatsDimension string
+
+const (
+	StatsByDeliveryService StatsDimension = "delivery_service"
+	StatsByRegion          StatsDimension = "region"
+	StatsByBrand           StatsDimension = "brand"
+	StatsByCurrency        StatsDimension = "currency"
+)
+
+// ParseStatsDimensions разбирает список через запятую, повторы убираются
+func ParseStatsDimensions(value string) ([]StatsDimension, error) {
+	var dimensions []StatsDimension
+	for _, part := range strings.Split(value, ",") {
+		dimension := StatsDimension(strings.TrimSpace(part))
+		switch dimension {
+		case "":
+			continue
+		case StatsByDeliveryService, StatsByRegion, StatsByBrand, StatsByCurrency:
+		default:
+			return nil, fmt.Errorf("group_by %q: want delivery_service, region, brand or currency", dimension)
+		}
+		if !slices.Contains(dimensions, dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+	return dimensions, nil
+}
+
+// OrderStatsQuery - статистика заказов с From по To (не включая) по интервалам Bucket
+type OrderStatsQuery struct {
+	Bucket  StatsBucket
+	GroupBy []StatsDimension
+	From    time.Time
+	To      time.Time
+}
+
+func (q OrderStatsQuery) Has(dimension StatsDimension) bool {
+	return slices.Contains(q.GroupBy, dimension)
+}
+
+// OrderStatsRow - строка статистики. Поля измерений, которых нет в GroupBy, пустые.
+// Orders - число заказов (при группировке по бренду - заказов с товарами бренда), Items - штук товара,
+// Revenue - сумма total_price позиций без доставки
+type OrderStatsRow struct {
+	Bucket          time.Time
+	DeliveryService string
+	Region          string
+	Brand           string
+	Currency        Currency
+	Orders          int64
+	Items           int64
+	Revenue         Money
+}
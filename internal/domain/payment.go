@@ -1,17 +1,30 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
+// Payment - суммы в минимальных единицах валюты Currency
 type Payment struct {
 	PaymentUID   uuid.UUID
 	Transaction  string
 	RequestID    string
-	Currency     string
+	Currency     Currency
 	Provider     string
-	Amount       int
-	PaymentDT    int
+	Amount       Money
+	PaymentDT    time.Time // в UTC
 	Bank         string
-	DeliveryCost int
-	GoodsTotal   int
-	CustomFee    int
+	DeliveryCost Money
+	GoodsTotal   Money
+	CustomFee    Money
+}
+
+func (p Payment) Totals() Totals {
+	return Totals{
+		Amount:       p.Amount,
+		GoodsTotal:   p.GoodsTotal,
+		DeliveryCost: p.DeliveryCost,
+		CustomFee:    p.CustomFee,
+	}
 }
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type QuarantineStatus string
+
+const (
+	QuarantinePending  QuarantineStatus = "pending"
+	QuarantineApproved QuarantineStatus = "approved"
+	QuarantineRejected QuarantineStatus = "rejected"
+)
+
+// QuarantinedOrder - входящий заказ, не прошедший проверки и ожидающий ручного разбора
+type QuarantinedOrder struct {
+	ID        uuid.UUID
+	OrderUID  uuid.UUID // uuid.Nil, если сообщение не удалось разобрать
+	Source    string    // kafka, http, grpc или import
+	Topic     string
+	Partition int
+	Offset    int64
+	// SchemaVersion - версия формата из заголовка сообщения, 0 - не указана (конверт или версия по умолчанию)
+	SchemaVersion int
+	Payload       []byte // сообщение в исходном виде, после правки - исправленный заказ
+	Reason        string // invalid_json, encoding_failed, unsupported_version, schema_failed, validation_failed или reconciliation_failed
+	Report        []byte // отчет валидации в JSON
+	Status        QuarantineStatus
+	ReviewedBy    string
+	ReviewNote    string
+	ReviewedAt    time.Time
+	CreatedAt     time.Time
+}
+
+// QuarantinePage - keyset-пагинация по (created_at, id), пустой AfterID означает первую страницу
+type QuarantinePage struct {
+	Limit   int
+	Status  QuarantineStatus // пустой - все статусы
+	AfterID uuid.UUID
+}
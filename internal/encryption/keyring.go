@@ -0,0 +1,247 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/config"
+	"os"
+	"strings"
+)
+
+const keySize = 32 // AES-256
+
+var (
+	ErrUnknownKey     = errors.New("unknown encryption key")
+	ErrInvalidKey     = errors.New("encryption key must be 32 bytes")
+	ErrNoActiveKey    = errors.New("active encryption key is not set")
+	ErrNoIndexKey     = errors.New("blind index key is not set")
+	ErrMalformedValue = errors.New("malformed ciphertext")
+)
+
+// Keyring - мастер-ключи (KEK) по идентификаторам и ключ blind index.
+// Данные строки шифруются своим ключом (DEK), а DEK - активным мастер-ключом
+type Keyring struct {
+	active   string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+func NewKeyring(active string, keys map[string][]byte, indexKey []byte) (*Keyring, error) {
+	for id, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, id)
+		}
+	}
+	if active == "" {
+		return nil, ErrNoActiveKey
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, active)
+	}
+	if len(indexKey) == 0 {
+		return nil, ErrNoIndexKey
+	}
+
+	return &Keyring{
+		active:   active,
+		keys:     keys,
+		indexKey: indexKey,
+	}, nil
+}
+
+// keyFile - формат файла ключей, ключи в base64
+type keyFile struct {
+	Active        string            `json:"active"`
+	Keys          map[string]string `json:"keys"`
+	BlindIndexKey string            `json:"blind_index_key"`
+}
+
+// NewKeyringFromConfig берет ключи из CRYPTO_KEYFILE, а если он не задан - из CRYPTO_KEYS.
+// Если ключей нет, возвращает nil: шифрование выключено
+func NewKeyringFromConfig(cfg config.Config) (*Keyring, error) {
+	file := keyFile{
+		Active:        cfg.CryptoActiveKey,
+		Keys:          make(map[string]string),
+		BlindIndexKey: cfg.CryptoBlindIndexKey,
+	}
+
+	if cfg.CryptoKeyFile != "" {
+		data, err := os.ReadFile(cfg.CryptoKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("keyfile: %w", err)
+		}
+		if err = json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("keyfile: %w", err)
+		}
+	} else {
+		for _, entry := range cfg.CryptoKeys {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			id, key, ok := strings.Cut(entry, ":")
+			if !ok || id == "" {
+				return nil, errors.New("crypto key entry must be id:base64")
+			}
+			file.Keys[id] = key
+		}
+	}
+
+	if len(file.Keys) == 0 {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(file.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("blind index key: %w", err)
+	}
+
+	return NewKeyring(file.Active, keys, indexKey)
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// NewRowKey создает новый DEK для строки и заворачивает его активным мастер-ключом
+func (k *Keyring) NewRowKey() (*RowKey, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(k.keys[k.active], dek, []byte(k.active))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RowKey{KeyID: k.active, Wrapped: wrapped, dek: dek}, nil
+}
+
+// OpenRowKey разворачивает DEK строки мастер-ключом keyID
+func (k *Keyring) OpenRowKey(keyID string, wrapped []byte) (*RowKey, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RowKey{KeyID: keyID, Wrapped: wrapped, dek: dek}, nil
+}
+
+// BlindIndex - HMAC-SHA256 от нормализованного значения, по нему ищут без расшифровки.
+// field входит в HMAC, чтобы одинаковые значения разных полей давали разные индексы
+func (k *Keyring) BlindIndex(field, value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(Normalize(field, value)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Normalize приводит значение к виду, в котором оно попадает в blind index
+func Normalize(field, value string) string {
+	value = strings.TrimSpace(value)
+
+	switch field {
+	case "phone":
+		var b strings.Builder
+		for i, r := range value {
+			if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	case "email":
+		return strings.ToLower(value)
+	}
+
+	return value
+}
+
+// RowKey - развернутый DEK одной строки
+type RowKey struct {
+	KeyID   string
+	Wrapped []byte
+	dek     []byte
+}
+
+// Encrypt шифрует значение поля, field связывает шифротекст с колонкой
+func (rk *RowKey) Encrypt(field, plaintext string) (string, error) {
+	ciphertext, err := seal(rk.dek, []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (rk *RowKey) Decrypt(field, value string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", ErrMalformedValue
+	}
+
+	plaintext, err := open(rk.dek, ciphertext, []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// seal - AES-256-GCM, nonce пишется перед шифротекстом
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrMalformedValue
+	}
+
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,119 @@
+package encryption_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestKeyring_RowKeyRoundTrip(t *testing.T) {
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(9))
+	require.NoError(t, err)
+
+	rowKey, err := keyring.NewRowKey()
+	require.NoError(t, err)
+	assert.Equal(t, "k1", rowKey.KeyID)
+
+	ciphertext, err := rowKey.Encrypt("phone", "+79001234567")
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "9001234567")
+
+	opened, err := keyring.OpenRowKey(rowKey.KeyID, rowKey.Wrapped)
+	require.NoError(t, err)
+
+	plaintext, err := opened.Decrypt("phone", ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "+79001234567", plaintext)
+
+	// шифротекст привязан к полю: подставить телефон в email не выйдет
+	_, err = opened.Decrypt("email", ciphertext)
+	assert.Error(t, err)
+}
+
+func TestKeyring_RotationKeepsOldKeysReadable(t *testing.T) {
+	old, err := encryption.NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(9))
+	require.NoError(t, err)
+	rowKey, err := old.NewRowKey()
+	require.NoError(t, err)
+
+	rotated, err := encryption.NewKeyring("k2", map[string][]byte{"k1": key(1), "k2": key(2)}, key(9))
+	require.NoError(t, err)
+
+	_, err = rotated.OpenRowKey("k1", rowKey.Wrapped)
+	assert.NoError(t, err)
+
+	newRowKey, err := rotated.NewRowKey()
+	require.NoError(t, err)
+	assert.Equal(t, "k2", newRowKey.KeyID)
+
+	_, err = old.OpenRowKey("k2", newRowKey.Wrapped)
+	assert.ErrorIs(t, err, encryption.ErrUnknownKey)
+
+	// DEK, завернутый одним ключом, не открывается под чужим идентификатором
+	_, err = rotated.OpenRowKey("k2", rowKey.Wrapped)
+	assert.Error(t, err)
+}
+
+func TestKeyring_BlindIndexNormalizes(t *testing.T) {
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(9))
+	require.NoError(t, err)
+
+	assert.Equal(t, keyring.BlindIndex("phone", "+7 (900) 123-45-67"), keyring.BlindIndex("phone", "+79001234567"))
+	assert.Equal(t, keyring.BlindIndex("email", " Ivan@Mail.RU"), keyring.BlindIndex("email", "ivan@mail.ru"))
+	assert.NotEqual(t, keyring.BlindIndex("phone", "123"), keyring.BlindIndex("email", "123"))
+
+	other, err := encryption.NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(8))
+	require.NoError(t, err)
+	assert.NotEqual(t, keyring.BlindIndex("phone", "123"), other.BlindIndex("phone", "123"))
+}
+
+func TestNewKeyring_Validates(t *testing.T) {
+	_, err := encryption.NewKeyring("k1", map[string][]byte{"k1": []byte("short")}, key(9))
+	assert.ErrorIs(t, err, encryption.ErrInvalidKey)
+
+	_, err = encryption.NewKeyring("k2", map[string][]byte{"k1": key(1)}, key(9))
+	assert.ErrorIs(t, err, encryption.ErrUnknownKey)
+
+	_, err = encryption.NewKeyring("k1", map[string][]byte{"k1": key(1)}, nil)
+	assert.ErrorIs(t, err, encryption.ErrNoIndexKey)
+}
+
+func TestNewKeyringFromConfig(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+
+	keyring, err := encryption.NewKeyringFromConfig(config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, keyring)
+
+	keyring, err = encryption.NewKeyringFromConfig(config.Config{
+		CryptoKeys:          []string{"k1:" + b64(key(1)), "k2:" + b64(key(2))},
+		CryptoActiveKey:     "k2",
+		CryptoBlindIndexKey: b64(key(9)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyring.ActiveKeyID())
+
+	data, err := json.Marshal(map[string]any{
+		"active":          "k1",
+		"keys":            map[string]string{"k1": b64(key(1))},
+		"blind_index_key": b64(key(9)),
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	keyring, err = encryption.NewKeyringFromConfig(config.Config{CryptoKeyFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyring.ActiveKeyID())
+}
@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrKeyringMissing = errors.New("delivery is encrypted but no encryption keys configured")
+)
+
+// deliveryCrypto - служебные колонки deliveries для шифрования, key_id IS NULL - строка в открытом виде
+type deliveryCrypto struct {
+	KeyID      sql.NullString
+	DEK        []byte
+	PhoneIndex sql.NullString
+	EmailIndex sql.NullString
+}
+
+// sealedDelivery - колонки deliveries с персональными данными в том виде, в котором они пишутся в БД
+type sealedDelivery struct {
+	Name    string
+	Phone   string
+	Address string
+	Email   string
+	deliveryCrypto
+}
+
+// sealDelivery шифрует персональные данные доставки новым DEK, без ключей пишет их как есть
+func (pg *PgOrderRepo) sealDelivery(d *domain.Delivery) (sealedDelivery, error) {
+	sealed := sealedDelivery{
+		Name:    d.Name,
+		Phone:   d.Phone,
+		Address: d.Address,
+		Email:   d.Email,
+	}
+	if pg.keyring == nil {
+		return sealed, nil
+	}
+
+	rowKey, err := pg.keyring.NewRowKey()
+	if err != nil {
+		return sealed, err
+	}
+
+	for _, f := range []struct {
+		name string
+		v    *string
+	}{
+		{"name", &sealed.Name},
+		{"phone", &sealed.Phone},
+		{"address", &sealed.Address},
+		{"email", &sealed.Email},
+	} {
+		if *f.v, err = rowKey.Encrypt(f.name, *f.v); err != nil {
+			return sealed, err
+		}
+	}
+
+	sealed.KeyID = sql.NullString{String: rowKey.KeyID, Valid: true}
+	sealed.DEK = rowKey.Wrapped
+	sealed.PhoneIndex = nullString(pg.keyring.BlindIndex("phone", d.Phone))
+	sealed.EmailIndex = nullString(pg.keyring.BlindIndex("email", d.Email))
+
+	return sealed, nil
+}
+
+// openDelivery расшифровывает прочитанные из БД поля доставки на месте
+func (pg *PgOrderRepo) openDelivery(d *domain.Delivery, c *deliveryCrypto) error {
+	if !c.KeyID.Valid {
+		return nil
+	}
+	if pg.keyring == nil {
+		return ErrKeyringMissing
+	}
+
+	rowKey, err := pg.keyring.OpenRowKey(c.KeyID.String, c.DEK)
+	if err != nil {
+		return fmt.Errorf("delivery %s: %w", d.DeliveryUID, err)
+	}
+
+	for _, f := range []struct {
+		name string
+		v    *string
+	}{
+		{"name", &d.Name},
+		{"phone", &d.Phone},
+		{"address", &d.Address},
+		{"email", &d.Email},
+	} {
+		if *f.v, err = rowKey.Decrypt(f.name, *f.v); err != nil {
+			return fmt.Errorf("delivery %s: %s: %w", d.DeliveryUID, f.name, err)
+		}
+	}
+
+	return nil
+}
+
+// contactFilter - параметры поиска по телефону или email: blind index для зашифрованных строк
+// и само значение для строк, записанных до включения шифрования
+func (pg *PgOrderRepo) contactFilter(field, value string) (sql.NullString, sql.NullString) {
+	if value == "" {
+		return sql.NullString{}, sql.NullString{}
+	}
+	if pg.keyring == nil {
+		return sql.NullString{}, nullString(value)
+	}
+	return nullString(pg.keyring.BlindIndex(field, value)), nullString(value)
+}
+
+// ReencryptDeliveries перешифровывает доставки активным ключом пачками по batchSize строк,
+// каждая пачка - отдельная транзакция. Без all берутся только строки с другим ключом или в открытом виде,
+// с all - все строки (например, чтобы пересчитать blind index после смены его ключа).
+// progress вызывается после каждой пачки с общим числом перешифрованных строк
+func (pg *PgOrderRepo) ReencryptDeliveries(ctx context.Context, all bool, batchSize int, progress func(done int)) (int, error) {
+	if pg.keyring == nil {
+		return 0, ErrKeyringMissing
+	}
+
+	done := 0
+	after := uuid.Nil
+
+	for {
+		n, last, err := pg.reencryptBatch(ctx, all, after, batchSize)
+		if err != nil {
+			return done, err
+		}
+		if n == 0 {
+			return done, nil
+		}
+
+		done += n
+		after = last
+
+		if progress != nil {
+			progress(done)
+		}
+	}
+}
+
+func (pg *PgOrderRepo) reencryptBatch(ctx context.Context, all bool, after uuid.UUID, batchSize int) (int, uuid.UUID, error) {
+	funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(funcCtx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, after, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	r, err := tx.QueryContext(funcCtx, deliveryReencryptSelectQuery, after, all, pg.keyring.ActiveKeyID(), batchSize)
+	if err != nil {
+		return 0, after, err
+	}
+
+	var deliveries []domain.Delivery
+	for r.Next() {
+		var d domain.Delivery
+		var c deliveryCrypto
+
+		if err = r.Scan(&d.DeliveryUID, &d.Name, &d.Phone, &d.Address, &d.Email, &c.KeyID, &c.DEK); err != nil {
+			_ = r.Close()
+			return 0, after, err
+		}
+
+		if err = pg.openDelivery(&d, &c); err != nil {
+			_ = r.Close()
+			return 0, after, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+	_ = r.Close()
+
+	if err = r.Err(); err != nil {
+		return 0, after, err
+	}
+
+	for i := range deliveries {
+		d := &deliveries[i]
+
+		sealed, err := pg.sealDelivery(d)
+		if err != nil {
+			return 0, after, err
+		}
+
+		_, err = tx.ExecContext(funcCtx, deliveryReencryptUpdateQuery,
+			d.DeliveryUID,
+			sealed.Name,
+			sealed.Phone,
+			sealed.Address,
+			sealed.Email,
+			sealed.KeyID,
+			sealed.DEK,
+			sealed.PhoneIndex,
+			sealed.EmailIndex,
+		)
+		if err != nil {
+			return 0, after, err
+		}
+
+		after = d.DeliveryUID
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, after, err
+	}
+
+	return len(deliveries), after, nil
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"log/slog"
+)
+
+type PgIdempotencyRepo struct {
+	logger *slog.Logger
+	db     *sql.DB
+	cfg    config.Config
+}
+
+var _ usecase.IdempotencyRepo = (*PgIdempotencyRepo)(nil)
+
+func NewPgIdempotencyRepo(logger *slog.Logger, db *sql.DB, cfg config.Config) *PgIdempotencyRepo {
+	return &PgIdempotencyRepo{
+		logger: logger,
+		db:     db,
+		cfg:    cfg,
+	}
+}
+
+func (pg *PgIdempotencyRepo) Get(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	var record domain.IdempotencyKey
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		return pg.db.QueryRowContext(funcCtx, idempotencyKeyGetQuery, key).Scan(
+			&record.Key,
+			&record.RequestHash,
+			&record.OrderUID,
+			&record.CreatedAt,
+		)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, usecase.ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
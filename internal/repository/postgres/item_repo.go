@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"log/slog"
+)
+
+// PgItemRepo читает каталог товаров, пишется он вместе с заказом в PgOrderRepo.saveOrderTx
+type PgItemRepo struct {
+	logger *slog.Logger
+	db     *sql.DB
+	cfg    config.Config
+}
+
+var _ usecase.ItemRepo = (*PgItemRepo)(nil)
+
+func NewPgItemRepo(logger *slog.Logger, db *sql.DB, cfg config.Config) *PgItemRepo {
+	return &PgItemRepo{
+		logger: logger,
+		db:     db,
+		cfg:    cfg,
+	}
+}
+
+// GetByNmID - размеры артикула по chrt_id, к каждому история версий и не больше CATALOG_CONFLICTS_LIMIT
+// последних расхождений на артикул, новые первыми
+func (pg *PgItemRepo) GetByNmID(ctx context.Context, nmID int) ([]*domain.CatalogItem, error) {
+	var items []*domain.CatalogItem
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		// версии и расхождения согласованы с самими товарами
+		tx, err := pg.db.BeginTx(funcCtx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		funcItems, err := pg.getItemsTx(funcCtx, tx, nmID)
+		if err != nil {
+			return err
+		}
+
+		byChrtID := make(map[int]*domain.CatalogItem, len(funcItems))
+		for _, item := range funcItems {
+			byChrtID[item.ChrtID] = item
+		}
+
+		if err = pg.getVersionsTx(funcCtx, tx, nmID, byChrtID); err != nil {
+			return err
+		}
+		if err = pg.getConflictsTx(funcCtx, tx, nmID, byChrtID); err != nil {
+			return err
+		}
+
+		items = funcItems
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, usecase.ErrItemNotFound
+	}
+
+	return items, nil
+}
+
+func (pg *PgItemRepo) getItemsTx(ctx context.Context, tx *sql.Tx, nmID int) ([]*domain.CatalogItem, error) {
+	r, err := tx.QueryContext(ctx, catalogItemGetQuery, nmID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var items []*domain.CatalogItem
+	for r.Next() {
+		var item domain.CatalogItem
+		err = r.Scan(
+			&item.NmID,
+			&item.ChrtID,
+			&item.Name,
+			&item.Brand,
+			&item.Size,
+			&item.Version,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		item.CreatedAt = item.CreatedAt.UTC()
+		item.UpdatedAt = item.UpdatedAt.UTC()
+		items = append(items, &item)
+	}
+
+	return items, r.Err()
+}
+
+func (pg *PgItemRepo) getVersionsTx(ctx context.Context, tx *sql.Tx, nmID int, items map[int]*domain.CatalogItem) error {
+	r, err := tx.QueryContext(ctx, catalogItemVersionsGetQuery, nmID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	for r.Next() {
+		var (
+			chrtID  int
+			version domain.ItemVersion
+		)
+		err = r.Scan(
+			&chrtID,
+			&version.Version,
+			&version.Name,
+			&version.Brand,
+			&version.Size,
+			&version.ItemUID,
+			&version.OrderUID,
+			&version.ValidFrom,
+		)
+		if err != nil {
+			return err
+		}
+
+		if item, ok := items[chrtID]; ok {
+			version.ValidFrom = version.ValidFrom.UTC()
+			item.Versions = append(item.Versions, version)
+		}
+	}
+
+	return r.Err()
+}
+
+func (pg *PgItemRepo) getConflictsTx(ctx context.Context, tx *sql.Tx, nmID int, items map[int]*domain.CatalogItem) error {
+	r, err := tx.QueryContext(ctx, catalogItemConflictsGetQuery, nmID, pg.cfg.CatalogConflictsLimit)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	for r.Next() {
+		conflict := domain.ItemConflict{NmID: nmID}
+		err = r.Scan(
+			&conflict.ChrtID,
+			&conflict.ItemUID,
+			&conflict.OrderUID,
+			&conflict.Current.Name,
+			&conflict.Current.Brand,
+			&conflict.Current.Size,
+			&conflict.Incoming.Name,
+			&conflict.Incoming.Brand,
+			&conflict.Incoming.Size,
+			&conflict.Applied,
+			&conflict.DetectedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if item, ok := items[conflict.ChrtID]; ok {
+			conflict.Fields = conflict.Current.Diff(conflict.Incoming)
+			conflict.DetectedAt = conflict.DetectedAt.UTC()
+			item.Conflicts = append(item.Conflicts, conflict)
+		}
+	}
+
+	return r.Err()
+}
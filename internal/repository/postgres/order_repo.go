@@ -4,12 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/folivorra/get_order/internal/config"
 	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/encryption"
 	"github.com/folivorra/get_order/internal/usecase"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"log/slog"
 	"sort"
 	"time"
 )
@@ -22,23 +25,27 @@ var (
 )
 
 type PgOrderRepo struct {
-	db  *sql.DB
-	cfg config.Config
+	logger  *slog.Logger
+	db      *sql.DB
+	cfg     config.Config
+	keyring *encryption.Keyring // nil - персональные данные доставки пишутся в открытом виде
 }
 
 var _ usecase.OrderRepo = (*PgOrderRepo)(nil)
 
-func NewPgOrderRepo(db *sql.DB, cfg config.Config) *PgOrderRepo {
+func NewPgOrderRepo(logger *slog.Logger, db *sql.DB, cfg config.Config, keyring *encryption.Keyring) *PgOrderRepo {
 	return &PgOrderRepo{
-		db:  db,
-		cfg: cfg,
+		logger:  logger,
+		db:      db,
+		cfg:     cfg,
+		keyring: keyring,
 	}
 }
 
 func (pg *PgOrderRepo) Get(ctx context.Context, uid uuid.UUID) (*domain.Order, error) {
 	var order domain.Order
 
-	err := retry(ctx, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
 		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
 		defer cancel()
 
@@ -59,60 +66,7 @@ func (pg *PgOrderRepo) Get(ctx context.Context, uid uuid.UUID) (*domain.Order, e
 				Item: &domain.Item{},
 			}
 
-			if err = r.Scan(
-				&funcOrder.OrderUID,
-				&funcOrder.TrackNumber,
-				&funcOrder.Entry,
-				&funcOrder.Delivery.DeliveryUID,
-				&funcOrder.Payment.PaymentUID,
-				&funcOrder.Locale,
-				&funcOrder.InternalSignature,
-				&funcOrder.CustomerID,
-				&funcOrder.DeliveryService,
-				&funcOrder.ShardKey,
-				&funcOrder.SmID,
-				&funcOrder.DateCreated,
-				&funcOrder.OofShard,
-
-				&funcOrder.Delivery.DeliveryUID,
-				&funcOrder.Delivery.Name,
-				&funcOrder.Delivery.Phone,
-				&funcOrder.Delivery.Zip,
-				&funcOrder.Delivery.City,
-				&funcOrder.Delivery.Address,
-				&funcOrder.Delivery.Region,
-				&funcOrder.Delivery.Email,
-
-				&funcOrder.Payment.PaymentUID,
-				&funcOrder.Payment.Transaction,
-				&funcOrder.Payment.RequestID,
-				&funcOrder.Payment.Currency,
-				&funcOrder.Payment.Provider,
-				&funcOrder.Payment.Amount,
-				&funcOrder.Payment.PaymentDT,
-				&funcOrder.Payment.Bank,
-				&funcOrder.Payment.DeliveryCost,
-				&funcOrder.Payment.GoodsTotal,
-				&funcOrder.Payment.CustomFee,
-
-				&item.OrderItemUID,
-				&item.OrderUID,
-				&item.ItemUID,
-				&item.Price,
-				&item.Sale,
-				&item.TotalPrice,
-				&item.Quantity,
-
-				&item.Item.ItemUID,
-				&item.Item.ChrtID,
-				&item.Item.TrackNumber,
-				&item.Item.RID,
-				&item.Item.Name,
-				&item.Item.Size,
-				&item.Item.NmID,
-				&item.Item.Brand,
-				&item.Item.Status,
-			); err != nil {
+			if err = pg.scanOrderRow(r, &funcOrder, &item); err != nil {
 				return err
 			}
 
@@ -140,7 +94,17 @@ func (pg *PgOrderRepo) Get(ctx context.Context, uid uuid.UUID) (*domain.Order, e
 }
 
 func (pg *PgOrderRepo) Save(ctx context.Context, order *domain.Order) error {
-	err := retry(ctx, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+	return pg.save(ctx, order, nil)
+}
+
+// SaveWithIdempotencyKey вставляет ключ первым в транзакции заказа: параллельный запрос с тем же ключом
+// ждет на нем конца транзакции и получает ErrIdempotencyKeyExists, а при откате заказа ключ не остается
+func (pg *PgOrderRepo) SaveWithIdempotencyKey(ctx context.Context, order *domain.Order, key *domain.IdempotencyKey) error {
+	return pg.save(ctx, order, key)
+}
+
+func (pg *PgOrderRepo) save(ctx context.Context, order *domain.Order, key *domain.IdempotencyKey) error {
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
 		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout)
 		defer cancel()
 
@@ -153,96 +117,109 @@ func (pg *PgOrderRepo) Save(ctx context.Context, order *domain.Order) error {
 			_ = tx.Rollback()
 		}()
 
-		_, err = tx.ExecContext(funcCtx, deliverySaveQuery,
-			order.Delivery.DeliveryUID,
-			order.Delivery.Name,
-			order.Delivery.Phone,
-			order.Delivery.Zip,
-			order.Delivery.City,
-			order.Delivery.Address,
-			order.Delivery.Region,
-			order.Delivery.Email,
-		)
-		if err != nil {
+		if key != nil {
+			res, err := tx.ExecContext(funcCtx, idempotencyKeySaveQuery, key.Key, key.RequestHash, key.OrderUID)
+			if err != nil {
+				return err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected == 0 {
+				return usecase.ErrIdempotencyKeyExists
+			}
+		}
+
+		if err = pg.saveOrderTx(funcCtx, tx, order); err != nil {
 			return err
 		}
 
-		_, err = tx.ExecContext(funcCtx, paymentSaveQuery,
-			order.Payment.PaymentUID,
-			order.Payment.Transaction,
-			order.Payment.RequestID,
-			order.Payment.Currency,
-			order.Payment.Provider,
-			order.Payment.Amount,
-			order.Payment.PaymentDT,
-			order.Payment.Bank,
-			order.Payment.DeliveryCost,
-			order.Payment.GoodsTotal,
-			order.Payment.CustomFee,
-		)
+		return tx.Commit()
+	})
+
+	return err
+}
+
+// SaveBatch сохраняет пачку заказов одной транзакцией. Каждый заказ пишется под своей точкой сохранения,
+// поэтому ошибка одного заказа (дубликат, нарушение ограничений) не откатывает остальные.
+// errs[i] содержит ошибку для orders[i], err - ошибку всей пачки
+func (pg *PgOrderRepo) SaveBatch(ctx context.Context, orders []*domain.Order) ([]error, error) {
+	var errs []error
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout*time.Duration(len(orders)))
+		defer cancel()
+
+		tx, err := pg.db.BeginTx(funcCtx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
 		if err != nil {
 			return err
 		}
 
-		_, err = tx.ExecContext(funcCtx, orderSaveQuery,
-			order.OrderUID,
-			order.TrackNumber,
-			order.Entry,
-			order.Delivery.DeliveryUID,
-			order.Payment.PaymentUID,
-			order.Locale,
-			order.InternalSignature,
-			order.CustomerID,
-			order.DeliveryService,
-			order.ShardKey,
-			order.SmID,
-			order.DateCreated,
-			order.OofShard,
-		)
-		if err != nil {
-			return checkUnique(err)
-		}
-
-		for _, item := range order.Items {
-			_, err = tx.ExecContext(funcCtx, itemSaveQuery,
-				item.ItemUID,
-				item.Item.ChrtID,
-				item.Item.TrackNumber,
-				item.Item.RID,
-				item.Item.Name,
-				item.Item.Size,
-				item.Item.NmID,
-				item.Item.Brand,
-				item.Item.Status,
-			)
-			if err != nil {
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		funcErrs := make([]error, len(orders))
+
+		for i, order := range orders {
+			if _, err = tx.ExecContext(funcCtx, savepointQuery); err != nil {
 				return err
 			}
 
-			_, err = tx.ExecContext(funcCtx, itemOrderSaveQuery,
-				item.OrderItemUID,
-				item.OrderUID,
-				item.ItemUID,
-				item.Price,
-				item.Sale,
-				item.TotalPrice,
-				item.Quantity,
-			)
-			if err != nil {
+			saveErr := pg.saveOrderTx(funcCtx, tx, order)
+			if saveErr == nil {
+				if _, err = tx.ExecContext(funcCtx, releaseSavepointQuery); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var pgErr *pgconn.PgError
+			if !errors.Is(saveErr, ErrOrderAlreadyExists) && !errors.As(saveErr, &pgErr) {
+				return saveErr
+			}
+
+			if _, err = tx.ExecContext(funcCtx, rollbackSavepointQuery); err != nil {
 				return err
 			}
+
+			funcErrs[i] = saveErr
 		}
 
-		return tx.Commit()
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		errs = funcErrs
+
+		return nil
 	})
 
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+func (pg *PgOrderRepo) Exists(ctx context.Context, uid uuid.UUID) (bool, error) {
+	var exists bool
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgExistsTimeout)
+		defer cancel()
+
+		return pg.db.QueryRowContext(funcCtx, orderExistsQuery, uid).Scan(&exists)
+	})
+
+	return exists, err
 }
 
 func (pg *PgOrderRepo) GetLastN(ctx context.Context, n int) ([]*domain.Order, error) {
 	var orders []*domain.Order
 
-	err := retry(ctx, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
 		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout*time.Duration(n))
 		defer cancel()
 
@@ -262,60 +239,7 @@ func (pg *PgOrderRepo) GetLastN(ctx context.Context, n int) ([]*domain.Order, er
 				Item: &domain.Item{},
 			}
 
-			if err = r.Scan(
-				&order.OrderUID,
-				&order.TrackNumber,
-				&order.Entry,
-				&order.Delivery.DeliveryUID,
-				&order.Payment.PaymentUID,
-				&order.Locale,
-				&order.InternalSignature,
-				&order.CustomerID,
-				&order.DeliveryService,
-				&order.ShardKey,
-				&order.SmID,
-				&order.DateCreated,
-				&order.OofShard,
-
-				&order.Delivery.DeliveryUID,
-				&order.Delivery.Name,
-				&order.Delivery.Phone,
-				&order.Delivery.Zip,
-				&order.Delivery.City,
-				&order.Delivery.Address,
-				&order.Delivery.Region,
-				&order.Delivery.Email,
-
-				&order.Payment.PaymentUID,
-				&order.Payment.Transaction,
-				&order.Payment.RequestID,
-				&order.Payment.Currency,
-				&order.Payment.Provider,
-				&order.Payment.Amount,
-				&order.Payment.PaymentDT,
-				&order.Payment.Bank,
-				&order.Payment.DeliveryCost,
-				&order.Payment.GoodsTotal,
-				&order.Payment.CustomFee,
-
-				&item.OrderItemUID,
-				&item.OrderUID,
-				&item.ItemUID,
-				&item.Price,
-				&item.Sale,
-				&item.TotalPrice,
-				&item.Quantity,
-
-				&item.Item.ItemUID,
-				&item.Item.ChrtID,
-				&item.Item.TrackNumber,
-				&item.Item.RID,
-				&item.Item.Name,
-				&item.Item.Size,
-				&item.Item.NmID,
-				&item.Item.Brand,
-				&item.Item.Status,
-			); err != nil {
+			if err = pg.scanOrderRow(r, &order, &item); err != nil {
 				return err
 			}
 
@@ -333,9 +257,7 @@ func (pg *PgOrderRepo) GetLastN(ctx context.Context, n int) ([]*domain.Order, er
 
 		// чтобы кэш заполнялся соответствуя времени создания заказа
 		sort.Slice(funcOrders, func(i, j int) bool {
-			t1, _ := time.Parse(time.RFC3339, funcOrders[i].DateCreated)
-			t2, _ := time.Parse(time.RFC3339, funcOrders[j].DateCreated)
-			return t1.Before(t2)
+			return funcOrders[i].DateCreated.Before(funcOrders[j].DateCreated)
 		})
 
 		orders = funcOrders
@@ -350,7 +272,547 @@ func (pg *PgOrderRepo) GetLastN(ctx context.Context, n int) ([]*domain.Order, er
 	return orders, nil
 }
 
-func retry(ctx context.Context, maxRetries int, backoff time.Duration, fn func() error) error {
+// GetMany загружает несколько заказов одним запросом, отсутствующих заказов в ответе просто нет
+func (pg *PgOrderRepo) GetMany(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+	orders := make(map[uuid.UUID]*domain.Order, len(uids))
+	if len(uids) == 0 {
+		return orders, nil
+	}
+
+	keys := make([]string, len(uids))
+	for i, uid := range uids {
+		keys[i] = uid.String()
+	}
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		r, err := pg.db.QueryContext(funcCtx, orderGetManyQuery, keys)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+
+		funcOrders := make(map[uuid.UUID]*domain.Order, len(uids))
+
+		for r.Next() {
+			var order domain.Order
+			item := domain.OrderItem{
+				Item: &domain.Item{},
+			}
+
+			if err = pg.scanOrderRow(r, &order, &item); err != nil {
+				return err
+			}
+
+			if _, ok := funcOrders[order.OrderUID]; !ok {
+				funcOrders[order.OrderUID] = &order
+			}
+
+			funcOrders[order.OrderUID].Items = append(funcOrders[order.OrderUID].Items, item)
+		}
+
+		if err = r.Err(); err != nil {
+			return err
+		}
+
+		orders = funcOrders
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// List возвращает страницу заказов по фильтру в порядке (date_created, order_uid)
+func (pg *PgOrderRepo) List(ctx context.Context, filter domain.OrderFilter, page domain.OrderPage) ([]*domain.Order, error) {
+	var orders []*domain.Order
+
+	phoneIndex, phone := pg.contactFilter("phone", filter.Phone)
+	emailIndex, email := pg.contactFilter("email", filter.Email)
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		r, err := pg.db.QueryContext(funcCtx, orderListQuery,
+			nullTime(filter.DateFrom),
+			nullTime(filter.DateTo),
+			nullString(filter.DeliveryService),
+			nullString(filter.Region),
+			nullString(filter.Currency),
+			nullTime(page.AfterDateCreated),
+			page.AfterOrderUID,
+			page.Limit,
+			phoneIndex, phone,
+			emailIndex, email,
+			nullInt(filter.NmID),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+
+		var funcOrders []*domain.Order
+		index := make(map[uuid.UUID]int)
+
+		for r.Next() {
+			var order domain.Order
+			item := domain.OrderItem{
+				Item: &domain.Item{},
+			}
+
+			if err = pg.scanOrderRow(r, &order, &item); err != nil {
+				return err
+			}
+
+			i, ok := index[order.OrderUID]
+			if !ok {
+				i = len(funcOrders)
+				index[order.OrderUID] = i
+				funcOrders = append(funcOrders, &order)
+			}
+
+			funcOrders[i].Items = append(funcOrders[i].Items, item)
+		}
+
+		if err = r.Err(); err != nil {
+			return err
+		}
+
+		orders = funcOrders
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Stream читает заказы по фильтру через серверный курсор и отдает их в fn по одному,
+// поэтому память не растет с размером выборки
+func (pg *PgOrderRepo) Stream(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) error {
+	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	phoneIndex, phone := pg.contactFilter("phone", filter.Phone)
+	emailIndex, email := pg.contactFilter("email", filter.Email)
+
+	_, err = tx.ExecContext(ctx, orderStreamDeclareQuery,
+		nullTime(filter.DateFrom),
+		nullTime(filter.DateTo),
+		nullString(filter.DeliveryService),
+		nullString(filter.Region),
+		nullString(filter.Currency),
+		phoneIndex, phone,
+		emailIndex, email,
+		nullInt(filter.NmID),
+	)
+	if err != nil {
+		return err
+	}
+
+	fetchQuery := fmt.Sprintf(orderStreamFetchQuery, pg.cfg.PgStreamFetchSize)
+
+	var current *domain.Order
+
+	for {
+		n, err := pg.fetchBatch(ctx, tx, fetchQuery, func(order *domain.Order, item domain.OrderItem) error {
+			if current != nil && current.OrderUID != order.OrderUID {
+				if err := fn(current); err != nil {
+					return err
+				}
+				current = nil
+			}
+
+			if current == nil {
+				current = order
+			}
+			current.Items = append(current.Items, item)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			break
+		}
+	}
+
+	if current != nil {
+		if err = fn(current); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PgOrderRepo) fetchBatch(
+	ctx context.Context,
+	tx *sql.Tx,
+	query string,
+	fn func(order *domain.Order, item domain.OrderItem) error,
+) (int, error) {
+	funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+	defer cancel()
+
+	r, err := tx.QueryContext(funcCtx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	n := 0
+	for r.Next() {
+		n++
+		var order domain.Order
+		item := domain.OrderItem{
+			Item: &domain.Item{},
+		}
+
+		if err = pg.scanOrderRow(r, &order, &item); err != nil {
+			return n, err
+		}
+
+		if err = fn(&order, item); err != nil {
+			return n, err
+		}
+	}
+
+	return n, r.Err()
+}
+
+// saveOrderTx пишет заказ со всеми связанными сущностями в рамках переданной транзакции
+func (pg *PgOrderRepo) saveOrderTx(ctx context.Context, tx *sql.Tx, order *domain.Order) error {
+	delivery, err := pg.sealDelivery(&order.Delivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, deliverySaveQuery,
+		order.Delivery.DeliveryUID,
+		delivery.Name,
+		delivery.Phone,
+		order.Delivery.Zip,
+		order.Delivery.City,
+		delivery.Address,
+		order.Delivery.Region,
+		delivery.Email,
+		delivery.KeyID,
+		delivery.DEK,
+		delivery.PhoneIndex,
+		delivery.EmailIndex,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, paymentSaveQuery,
+		order.Payment.PaymentUID,
+		order.Payment.Transaction,
+		order.Payment.RequestID,
+		order.Payment.Currency,
+		order.Payment.Provider,
+		order.Payment.Amount.Amount,
+		order.Payment.PaymentDT,
+		order.Payment.Bank,
+		order.Payment.DeliveryCost.Amount,
+		order.Payment.GoodsTotal.Amount,
+		order.Payment.CustomFee.Amount,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, orderSaveQuery,
+		order.OrderUID,
+		order.TrackNumber,
+		order.Entry,
+		order.Delivery.DeliveryUID,
+		order.Payment.PaymentUID,
+		order.Locale,
+		order.InternalSignature,
+		order.CustomerID,
+		order.DeliveryService,
+		order.ShardKey,
+		order.SmID,
+		order.DateCreated,
+		order.OofShard,
+	)
+	if err != nil {
+		return checkUnique(err)
+	}
+
+	if pg.cfg.StatsRollupEnabled {
+		if _, err = tx.ExecContext(ctx, statsDirtySaveQuery, order.DateCreated, order.OrderUID); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range order.Items {
+		_, err = tx.ExecContext(ctx, itemSaveQuery,
+			item.ItemUID,
+			item.Item.ChrtID,
+			item.Item.TrackNumber,
+			item.Item.RID,
+			item.Item.Name,
+			item.Item.Size,
+			item.Item.NmID,
+			item.Item.Brand,
+			item.Item.Status,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err = pg.saveCatalogItemTx(ctx, tx, order, item); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, itemOrderSaveQuery,
+			item.OrderItemUID,
+			item.OrderUID,
+			item.ItemUID,
+			item.Price.Amount,
+			item.Sale,
+			item.TotalPrice.Amount,
+			item.Quantity,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveCatalogItemTx сверяет атрибуты позиции с каталогом. Новый товар получает версию 1, отличающиеся атрибуты
+// из заказа не старше текущей версии становятся новой версией, любое расхождение пишется в catalog_item_conflicts
+func (pg *PgOrderRepo) saveCatalogItemTx(ctx context.Context, tx *sql.Tx, order *domain.Order, item domain.OrderItem) error {
+	incoming := domain.ItemAttributes{
+		Name:  item.Item.Name,
+		Brand: item.Item.Brand,
+		Size:  item.Item.Size,
+	}
+
+	res, err := tx.ExecContext(ctx, catalogItemInsertQuery,
+		item.Item.NmID,
+		item.Item.ChrtID,
+		incoming.Name,
+		incoming.Brand,
+		incoming.Size,
+		order.DateCreated,
+	)
+	if err != nil {
+		return err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 1 {
+		return pg.saveCatalogVersionTx(ctx, tx, order, item, 1)
+	}
+
+	var (
+		current   domain.ItemAttributes
+		version   int
+		validFrom time.Time
+	)
+	err = tx.QueryRowContext(ctx, catalogItemLockQuery, item.Item.NmID, item.Item.ChrtID).
+		Scan(&current.Name, &current.Brand, &current.Size, &version, &validFrom)
+	if err != nil {
+		return err
+	}
+
+	fields := current.Diff(incoming)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// заказ старше текущей версии не откатывает каталог к устаревшим атрибутам
+	applied := !order.DateCreated.Before(validFrom)
+	if applied {
+		version++
+		_, err = tx.ExecContext(ctx, catalogItemUpdateQuery,
+			item.Item.NmID,
+			item.Item.ChrtID,
+			incoming.Name,
+			incoming.Brand,
+			incoming.Size,
+			version,
+			order.DateCreated,
+		)
+		if err != nil {
+			return err
+		}
+		if err = pg.saveCatalogVersionTx(ctx, tx, order, item, version); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, catalogItemConflictSaveQuery,
+		item.Item.NmID,
+		item.Item.ChrtID,
+		item.ItemUID,
+		order.OrderUID,
+		current.Name,
+		current.Brand,
+		current.Size,
+		incoming.Name,
+		incoming.Brand,
+		incoming.Size,
+		applied,
+	)
+	if err != nil {
+		return err
+	}
+
+	pg.logger.WarnContext(ctx, "item attributes conflict with catalog",
+		slog.Int("nm_id", item.Item.NmID),
+		slog.Int("chrt_id", item.Item.ChrtID),
+		slog.String("uuid", order.OrderUID.String()),
+		slog.Any("fields", fields),
+		slog.Bool("applied", applied),
+	)
+
+	return nil
+}
+
+func (pg *PgOrderRepo) saveCatalogVersionTx(ctx context.Context, tx *sql.Tx, order *domain.Order, item domain.OrderItem, version int) error {
+	_, err := tx.ExecContext(ctx, catalogItemVersionSaveQuery,
+		item.Item.NmID,
+		item.Item.ChrtID,
+		version,
+		item.Item.Name,
+		item.Item.Brand,
+		item.Item.Size,
+		item.ItemUID,
+		order.OrderUID,
+		order.DateCreated,
+	)
+	return err
+}
+
+// scanOrderRow сканирует строку запроса вида orders JOIN deliveries JOIN payments JOIN order_item JOIN items
+// и расшифровывает персональные данные доставки
+func (pg *PgOrderRepo) scanOrderRow(r *sql.Rows, order *domain.Order, item *domain.OrderItem) error {
+	var crypto deliveryCrypto
+
+	err := r.Scan(
+		&order.OrderUID,
+		&order.TrackNumber,
+		&order.Entry,
+		&order.Delivery.DeliveryUID,
+		&order.Payment.PaymentUID,
+		&order.Locale,
+		&order.InternalSignature,
+		&order.CustomerID,
+		&order.DeliveryService,
+		&order.ShardKey,
+		&order.SmID,
+		&order.DateCreated,
+		&order.OofShard,
+
+		&order.Delivery.DeliveryUID,
+		&order.Delivery.Name,
+		&order.Delivery.Phone,
+		&order.Delivery.Zip,
+		&order.Delivery.City,
+		&order.Delivery.Address,
+		&order.Delivery.Region,
+		&order.Delivery.Email,
+		&crypto.KeyID,
+		&crypto.DEK,
+		&crypto.PhoneIndex,
+		&crypto.EmailIndex,
+
+		&order.Payment.PaymentUID,
+		&order.Payment.Transaction,
+		&order.Payment.RequestID,
+		&order.Payment.Currency,
+		&order.Payment.Provider,
+		&order.Payment.Amount.Amount,
+		&order.Payment.PaymentDT,
+		&order.Payment.Bank,
+		&order.Payment.DeliveryCost.Amount,
+		&order.Payment.GoodsTotal.Amount,
+		&order.Payment.CustomFee.Amount,
+
+		&item.OrderItemUID,
+		&item.OrderUID,
+		&item.ItemUID,
+		&item.Price.Amount,
+		&item.Sale,
+		&item.TotalPrice.Amount,
+		&item.Quantity,
+
+		&item.Item.ItemUID,
+		&item.Item.ChrtID,
+		&item.Item.TrackNumber,
+		&item.Item.RID,
+		&item.Item.Name,
+		&item.Item.Size,
+		&item.Item.NmID,
+		&item.Item.Brand,
+		&item.Item.Status,
+	)
+	if err != nil {
+		return err
+	}
+
+	// timestamptz драйвер отдает в зоне соединения, в домене время в UTC
+	order.DateCreated = order.DateCreated.UTC()
+	order.Payment.PaymentDT = order.Payment.PaymentDT.UTC()
+
+	// суммы хранятся в минимальных единицах, валюта у всех сумм заказа общая - валюта платежа
+	currency := order.Payment.Currency
+	order.Payment.Amount.Currency = currency
+	order.Payment.DeliveryCost.Currency = currency
+	order.Payment.GoodsTotal.Currency = currency
+	order.Payment.CustomFee.Currency = currency
+	item.Price.Currency = currency
+	item.TotalPrice.Currency = currency
+
+	return pg.openDelivery(&order.Delivery, &crypto)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// retry повторяет fn с паузой backoff + jitter, пока ошибка не станет окончательной или не кончатся попытки
+func retry(ctx context.Context, logger *slog.Logger, maxRetries int, backoff time.Duration, fn func() error) error {
 	var err error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
@@ -358,10 +820,17 @@ func retry(ctx context.Context, maxRetries int, backoff time.Duration, fn func()
 		if err == nil ||
 			errors.Is(err, sql.ErrNoRows) ||
 			errors.Is(err, ErrOrderDoesNotExists) ||
-			errors.Is(err, ErrOrderAlreadyExists) {
+			errors.Is(err, ErrOrderAlreadyExists) ||
+			errors.Is(err, usecase.ErrIdempotencyKeyExists) {
 			return err
 		}
 
+		logger.WarnContext(ctx, "db operation failed",
+			slog.Int("attempt", attempt+1),
+			slog.Int("max_attempts", maxRetries),
+			slog.String("error", err.Error()),
+		)
+
 		jitter := time.Duration(gofakeit.IntN(500)) * time.Millisecond
 
 		select {
@@ -371,7 +840,7 @@ func retry(ctx context.Context, maxRetries int, backoff time.Duration, fn func()
 		}
 	}
 
-	return ErrMaxRetryAttemptsExceeded
+	return fmt.Errorf("%w: %w", ErrMaxRetryAttemptsExceeded, err)
 }
 
 func checkUnique(err error) error {
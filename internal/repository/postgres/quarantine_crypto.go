@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// payloadCrypto - служебные колонки quarantined_orders для шифрования, key_id IS NULL - payload в открытом виде
+type payloadCrypto struct {
+	KeyID sql.NullString
+	DEK   []byte
+}
+
+// sealedPayload - payload в том виде, в котором он пишется в БД
+type sealedPayload struct {
+	Payload []byte
+	payloadCrypto
+}
+
+// sealPayload шифрует сообщение целиком новым DEK: в нем персональные данные доставки,
+// а разобрать его на поля не всегда возможно. Без ключей пишет как есть
+func (pg *PgQuarantineRepo) sealPayload(payload []byte) (sealedPayload, error) {
+	sealed := sealedPayload{Payload: payload}
+	if pg.keyring == nil {
+		return sealed, nil
+	}
+
+	rowKey, err := pg.keyring.NewRowKey()
+	if err != nil {
+		return sealed, err
+	}
+
+	ciphertext, err := rowKey.Encrypt("payload", string(payload))
+	if err != nil {
+		return sealed, err
+	}
+
+	sealed.Payload = []byte(ciphertext)
+	sealed.KeyID = sql.NullString{String: rowKey.KeyID, Valid: true}
+	sealed.DEK = rowKey.Wrapped
+
+	return sealed, nil
+}
+
+// openPayload расшифровывает прочитанный из БД payload записи id
+func (pg *PgQuarantineRepo) openPayload(id uuid.UUID, payload []byte, c *payloadCrypto) ([]byte, error) {
+	if !c.KeyID.Valid {
+		return payload, nil
+	}
+	if pg.keyring == nil {
+		return nil, ErrKeyringMissing
+	}
+
+	rowKey, err := pg.keyring.OpenRowKey(c.KeyID.String, c.DEK)
+	if err != nil {
+		return nil, fmt.Errorf("quarantined order %s: %w", id, err)
+	}
+
+	plaintext, err := rowKey.Decrypt("payload", string(payload))
+	if err != nil {
+		return nil, fmt.Errorf("quarantined order %s: payload: %w", id, err)
+	}
+
+	return []byte(plaintext), nil
+}
+
+// ReencryptPayloads перешифровывает payload карантина активным ключом, как ReencryptDeliveries доставки
+func (pg *PgQuarantineRepo) ReencryptPayloads(ctx context.Context, all bool, batchSize int, progress func(done int)) (int, error) {
+	if pg.keyring == nil {
+		return 0, ErrKeyringMissing
+	}
+
+	done := 0
+	after := uuid.Nil
+
+	for {
+		n, last, err := pg.reencryptBatch(ctx, all, after, batchSize)
+		if err != nil {
+			return done, err
+		}
+		if n == 0 {
+			return done, nil
+		}
+
+		done += n
+		after = last
+
+		if progress != nil {
+			progress(done)
+		}
+	}
+}
+
+func (pg *PgQuarantineRepo) reencryptBatch(ctx context.Context, all bool, after uuid.UUID, batchSize int) (int, uuid.UUID, error) {
+	funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(funcCtx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, after, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	r, err := tx.QueryContext(funcCtx, quarantineReencryptSelectQuery, after, all, pg.keyring.ActiveKeyID(), batchSize)
+	if err != nil {
+		return 0, after, err
+	}
+
+	type row struct {
+		id      uuid.UUID
+		payload []byte
+	}
+
+	var rows []row
+	for r.Next() {
+		var e row
+		var c payloadCrypto
+
+		if err = r.Scan(&e.id, &e.payload, &c.KeyID, &c.DEK); err != nil {
+			_ = r.Close()
+			return 0, after, err
+		}
+
+		if e.payload, err = pg.openPayload(e.id, e.payload, &c); err != nil {
+			_ = r.Close()
+			return 0, after, err
+		}
+
+		rows = append(rows, e)
+	}
+	_ = r.Close()
+
+	if err = r.Err(); err != nil {
+		return 0, after, err
+	}
+
+	for _, e := range rows {
+		sealed, err := pg.sealPayload(e.payload)
+		if err != nil {
+			return 0, after, err
+		}
+
+		if _, err = tx.ExecContext(funcCtx, quarantineReencryptUpdateQuery, e.id, sealed.Payload, sealed.KeyID, sealed.DEK); err != nil {
+			return 0, after, err
+		}
+
+		after = e.id
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, after, err
+	}
+
+	return len(rows), after, nil
+}
@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/encryption"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"log/slog"
+)
+
+type PgQuarantineRepo struct {
+	logger  *slog.Logger
+	db      *sql.DB
+	cfg     config.Config
+	keyring *encryption.Keyring
+}
+
+var _ usecase.QuarantineRepo = (*PgQuarantineRepo)(nil)
+
+// NewPgQuarantineRepo - keyring nil: payload пишется в открытом виде
+func NewPgQuarantineRepo(logger *slog.Logger, db *sql.DB, cfg config.Config, keyring *encryption.Keyring) *PgQuarantineRepo {
+	return &PgQuarantineRepo{
+		logger:  logger,
+		db:      db,
+		cfg:     cfg,
+		keyring: keyring,
+	}
+}
+
+func (pg *PgQuarantineRepo) Save(ctx context.Context, entry *domain.QuarantinedOrder) error {
+	sealed, err := pg.sealPayload(entry.Payload)
+	if err != nil {
+		return err
+	}
+
+	return retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout)
+		defer cancel()
+
+		_, err := pg.db.ExecContext(funcCtx, quarantineSaveQuery,
+			entry.ID,
+			nullUUID(entry.OrderUID),
+			entry.Source,
+			entry.Topic,
+			entry.Partition,
+			entry.Offset,
+			entry.SchemaVersion,
+			sealed.Payload,
+			entry.Reason,
+			string(entry.Report),
+			entry.Status,
+			sealed.KeyID,
+			sealed.DEK,
+		)
+
+		return err
+	})
+}
+
+func (pg *PgQuarantineRepo) Get(ctx context.Context, id uuid.UUID) (*domain.QuarantinedOrder, error) {
+	var entry *domain.QuarantinedOrder
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		var err error
+		entry, err = pg.scanQuarantinedOrder(pg.db.QueryRowContext(funcCtx, quarantineGetQuery, id))
+		return err
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, usecase.ErrQuarantineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (pg *PgQuarantineRepo) List(ctx context.Context, page domain.QuarantinePage) ([]*domain.QuarantinedOrder, error) {
+	var entries []*domain.QuarantinedOrder
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		r, err := pg.db.QueryContext(funcCtx, quarantineListQuery,
+			nullString(string(page.Status)),
+			nullUUID(page.AfterID),
+			page.Limit,
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+
+		var funcEntries []*domain.QuarantinedOrder
+		for r.Next() {
+			entry, err := pg.scanQuarantinedOrder(r)
+			if err != nil {
+				return err
+			}
+			funcEntries = append(funcEntries, entry)
+		}
+
+		if err = r.Err(); err != nil {
+			return err
+		}
+
+		entries = funcEntries
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (pg *PgQuarantineRepo) Resolve(ctx context.Context, entry *domain.QuarantinedOrder) error {
+	var affected int64
+
+	sealed, err := pg.sealPayload(entry.Payload)
+	if err != nil {
+		return err
+	}
+
+	err = retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout)
+		defer cancel()
+
+		res, err := pg.db.ExecContext(funcCtx, quarantineResolveQuery,
+			entry.ID,
+			entry.Status,
+			nullUUID(entry.OrderUID),
+			sealed.Payload,
+			entry.ReviewedBy,
+			entry.ReviewNote,
+			entry.ReviewedAt,
+			sealed.KeyID,
+			sealed.DEK,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err = res.RowsAffected()
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+	// запись уже разобрал другой сотрудник
+	if affected == 0 {
+		return usecase.ErrQuarantineResolved
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanQuarantinedOrder читает запись и расшифровывает payload
+func (pg *PgQuarantineRepo) scanQuarantinedOrder(row rowScanner) (*domain.QuarantinedOrder, error) {
+	var (
+		entry      domain.QuarantinedOrder
+		orderUID   uuid.NullUUID
+		reviewedAt sql.NullTime
+		crypto     payloadCrypto
+	)
+
+	err := row.Scan(
+		&entry.ID,
+		&orderUID,
+		&entry.Source,
+		&entry.Topic,
+		&entry.Partition,
+		&entry.Offset,
+		&entry.SchemaVersion,
+		&entry.Payload,
+		&entry.Reason,
+		&entry.Report,
+		&entry.Status,
+		&entry.ReviewedBy,
+		&entry.ReviewNote,
+		&reviewedAt,
+		&entry.CreatedAt,
+		&crypto.KeyID,
+		&crypto.DEK,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Payload, err = pg.openPayload(entry.ID, entry.Payload, &crypto); err != nil {
+		return nil, err
+	}
+
+	entry.OrderUID = orderUID.UUID
+	entry.ReviewedAt = reviewedAt.Time
+
+	return &entry, nil
+}
+
+func nullUUID(uid uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: uid, Valid: uid != uuid.Nil}
+}
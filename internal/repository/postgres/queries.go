@@ -3,9 +3,9 @@ package postgres
 const (
 	deliverySaveQuery = `
 	INSERT INTO deliveries (
-		delivery_uid, name, phone, zip, city, address, region, email
+		delivery_uid, name, phone, zip, city, address, region, email, key_id, dek, phone_bidx, email_bidx
 	) VALUES (
-		$1, $2, $3, $4, $5, $6, $7, $8
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
 	);
 	`
 	paymentSaveQuery = `
@@ -22,6 +22,7 @@ const (
 		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 	);
 	`
+	// позиция пишется один раз, новые атрибуты того же товара попадают в каталог, см. saveCatalogItemTx
 	itemSaveQuery = `
 	INSERT INTO items (
 	    item_uid, chrt_id, track_number, rid, name, size, nm_id, brand, status
@@ -30,6 +31,60 @@ const (
 	)
 	ON CONFLICT (item_uid) DO NOTHING;
 	`
+	catalogItemInsertQuery = `
+	INSERT INTO catalog_items (
+	    nm_id, chrt_id, name, brand, size, version, valid_from
+	) VALUES (
+	    $1, $2, $3, $4, $5, 1, $6
+	)
+	ON CONFLICT (nm_id, chrt_id) DO NOTHING;
+	`
+	catalogItemLockQuery = `
+	SELECT name, brand, size, version, valid_from
+	FROM catalog_items
+	WHERE nm_id = $1 AND chrt_id = $2
+	FOR UPDATE;
+	`
+	catalogItemUpdateQuery = `
+	UPDATE catalog_items
+	SET name = $3, brand = $4, size = $5, version = $6, valid_from = $7, updated_at = now()
+	WHERE nm_id = $1 AND chrt_id = $2;
+	`
+	catalogItemVersionSaveQuery = `
+	INSERT INTO catalog_item_versions (
+	    nm_id, chrt_id, version, name, brand, size, item_uid, order_uid, valid_from
+	) VALUES (
+	    $1, $2, $3, $4, $5, $6, $7, $8, $9
+	);
+	`
+	catalogItemConflictSaveQuery = `
+	INSERT INTO catalog_item_conflicts (
+	    nm_id, chrt_id, item_uid, order_uid, current_name, current_brand, current_size,
+	    incoming_name, incoming_brand, incoming_size, applied
+	) VALUES (
+	    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+	);
+	`
+	catalogItemGetQuery = `
+	SELECT nm_id, chrt_id, name, brand, size, version, created_at, updated_at
+	FROM catalog_items
+	WHERE nm_id = $1
+	ORDER BY chrt_id;
+	`
+	catalogItemVersionsGetQuery = `
+	SELECT chrt_id, version, name, brand, size, item_uid, order_uid, valid_from
+	FROM catalog_item_versions
+	WHERE nm_id = $1
+	ORDER BY chrt_id, version;
+	`
+	catalogItemConflictsGetQuery = `
+	SELECT chrt_id, item_uid, order_uid, current_name, current_brand, current_size,
+	       incoming_name, incoming_brand, incoming_size, applied, detected_at
+	FROM catalog_item_conflicts
+	WHERE nm_id = $1
+	ORDER BY id DESC
+	LIMIT $2;
+	`
 	itemOrderSaveQuery = `
 	INSERT INTO order_item (
 	    order_item_uid, order_uid, item_uid, price, sale, total_price, quantity
@@ -61,4 +116,274 @@ const (
 	JOIN order_item oi ON oi.order_uid = o.order_uid
 	JOIN items i       ON oi.item_uid = i.item_uid
 	`
+	orderStreamDeclareQuery = `
+	DECLARE orders_stream NO SCROLL CURSOR FOR
+	SELECT *
+	FROM orders o
+	JOIN deliveries d ON d.delivery_uid = o.delivery_uid
+	JOIN payments p   ON p.payment_uid = o.payment_uid
+	JOIN order_item oi ON oi.order_uid = o.order_uid
+	JOIN items i       ON oi.item_uid = i.item_uid
+	WHERE ($1::timestamptz IS NULL OR o.date_created >= $1)
+	  AND ($2::timestamptz IS NULL OR o.date_created < $2)
+	  AND ($3::text IS NULL OR o.delivery_service = $3)
+	  AND ($4::text IS NULL OR d.region = $4)
+	  AND ($5::text IS NULL OR p.currency = $5)
+	  AND ($7::text IS NULL OR d.phone_bidx = $6 OR (d.key_id IS NULL AND d.phone = $7))
+	  AND ($9::text IS NULL OR d.email_bidx = $8 OR (d.key_id IS NULL AND d.email = $9))
+	  AND ($10::int IS NULL OR EXISTS (
+	      SELECT 1 FROM order_item f JOIN items fi ON fi.item_uid = f.item_uid
+	      WHERE f.order_uid = o.order_uid AND fi.nm_id = $10
+	  ))
+	ORDER BY o.date_created, o.order_uid;
+	`
+	orderStreamFetchQuery = `
+	FETCH FORWARD %d FROM orders_stream;
+	`
+	orderExistsQuery = `
+	SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1);
+	`
+	savepointQuery         = `SAVEPOINT order_save;`
+	releaseSavepointQuery  = `RELEASE SAVEPOINT order_save;`
+	rollbackSavepointQuery = `ROLLBACK TO SAVEPOINT order_save;`
+	idempotencyKeyGetQuery = `
+	SELECT key, request_hash, order_uid, created_at
+	FROM idempotency_keys
+	WHERE key = $1;
+	`
+	idempotencyKeySaveQuery = `
+	INSERT INTO idempotency_keys (
+		key, request_hash, order_uid
+	) VALUES (
+		$1, $2, $3
+	)
+	ON CONFLICT (key) DO NOTHING;
+	`
+	orderListQuery = `
+	WITH page AS (
+		SELECT o.*
+		FROM orders o
+		JOIN deliveries d ON d.delivery_uid = o.delivery_uid
+		JOIN payments p   ON p.payment_uid = o.payment_uid
+		WHERE ($1::timestamptz IS NULL OR o.date_created >= $1)
+		  AND ($2::timestamptz IS NULL OR o.date_created < $2)
+		  AND ($3::text IS NULL OR o.delivery_service = $3)
+		  AND ($4::text IS NULL OR d.region = $4)
+		  AND ($5::text IS NULL OR p.currency = $5)
+		  AND ($6::timestamptz IS NULL OR (o.date_created, o.order_uid) > ($6, $7::uuid))
+		  AND ($10::text IS NULL OR d.phone_bidx = $9 OR (d.key_id IS NULL AND d.phone = $10))
+		  AND ($12::text IS NULL OR d.email_bidx = $11 OR (d.key_id IS NULL AND d.email = $12))
+		  AND ($13::int IS NULL OR EXISTS (
+		      SELECT 1 FROM order_item f JOIN items fi ON fi.item_uid = f.item_uid
+		      WHERE f.order_uid = o.order_uid AND fi.nm_id = $13
+		  ))
+		ORDER BY o.date_created, o.order_uid
+		LIMIT $8
+	)
+
+	SELECT *
+	FROM page o
+	JOIN deliveries d ON d.delivery_uid = o.delivery_uid
+	JOIN payments p   ON p.payment_uid = o.payment_uid
+	JOIN order_item oi ON oi.order_uid = o.order_uid
+	JOIN items i       ON oi.item_uid = i.item_uid
+	ORDER BY o.date_created, o.order_uid;
+	`
+	orderGetManyQuery = `
+	SELECT *
+	FROM orders o
+	JOIN deliveries d ON d.delivery_uid = o.delivery_uid
+	JOIN payments p ON p.payment_uid = o.payment_uid
+	JOIN order_item oi ON oi.order_uid = o.order_uid
+	JOIN items i ON oi.item_uid = i.item_uid
+	WHERE o.order_uid = ANY($1::uuid[]);
+	`
+	deliveryReencryptSelectQuery = `
+	SELECT delivery_uid, name, phone, address, email, key_id, dek
+	FROM deliveries
+	WHERE delivery_uid > $1
+	  AND ($2::boolean OR key_id IS DISTINCT FROM $3)
+	ORDER BY delivery_uid
+	LIMIT $4
+	FOR UPDATE;
+	`
+	deliveryReencryptUpdateQuery = `
+	UPDATE deliveries
+	SET name = $2, phone = $3, address = $4, email = $5, key_id = $6, dek = $7, phone_bidx = $8, email_bidx = $9
+	WHERE delivery_uid = $1;
+	`
+	// отметка видна пересчету только вместе с закоммиченным заказом
+	statsDirtySaveQuery = `
+	INSERT INTO order_stats_dirty (bucket, order_uid)
+	VALUES (date_trunc('hour', $1::timestamptz, 'UTC'), $2)
+	ON CONFLICT DO NOTHING;
+	`
+	// отметки заказов, закоммиченных после DELETE, остаются и вернут час на следующем проходе
+	statsDirtyTakeQuery = `
+	WITH taken AS (
+		DELETE FROM order_stats_dirty
+		WHERE (bucket, order_uid) IN (
+			SELECT bucket, order_uid
+			FROM order_stats_dirty
+			WHERE bucket IN (
+				SELECT DISTINCT bucket
+				FROM order_stats_dirty
+				ORDER BY bucket
+				LIMIT $1
+			)
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING bucket
+	)
+	SELECT DISTINCT bucket FROM taken ORDER BY bucket;
+	`
+	statsHourlyClearQuery = `
+	DELETE FROM order_stats_hourly WHERE bucket = ANY($1::timestamptz[]);
+	`
+	statsBrandHourlyClearQuery = `
+	DELETE FROM order_brand_stats_hourly WHERE bucket = ANY($1::timestamptz[]);
+	`
+	statsHourlyRefreshQuery = `
+	INSERT INTO order_stats_hourly (
+		bucket, delivery_service, region, currency, orders, items, revenue
+	)
+	SELECT date_trunc('hour', o.date_created, 'UTC'), o.delivery_service, d.region, p.currency,
+	       COUNT(*), COALESCE(SUM(l.items), 0), COALESCE(SUM(l.revenue), 0)
+	FROM orders o
+	JOIN deliveries d ON d.delivery_uid = o.delivery_uid
+	JOIN payments p   ON p.payment_uid = o.payment_uid
+	CROSS JOIN LATERAL (
+		SELECT SUM(oi.quantity) AS items, SUM(oi.total_price) AS revenue
+		FROM order_item oi
+		WHERE oi.order_uid = o.order_uid
+	) l
+	WHERE o.date_created >= $2 AND o.date_created < $3
+	  AND date_trunc('hour', o.date_created, 'UTC') = ANY($1::timestamptz[])
+	GROUP BY 1, 2, 3, 4;
+	`
+	statsBrandHourlyRefreshQuery = `
+	INSERT INTO order_brand_stats_hourly (
+		bucket, delivery_service, region, brand, currency, orders, items, revenue
+	)
+	SELECT date_trunc('hour', o.date_created, 'UTC'), o.delivery_service, d.region, i.brand, p.currency,
+	       COUNT(DISTINCT o.order_uid), SUM(oi.quantity), SUM(oi.total_price)
+	FROM orders o
+	JOIN deliveries d  ON d.delivery_uid = o.delivery_uid
+	JOIN payments p    ON p.payment_uid = o.payment_uid
+	JOIN order_item oi ON oi.order_uid = o.order_uid
+	JOIN items i       ON i.item_uid = oi.item_uid
+	WHERE o.date_created >= $2 AND o.date_created < $3
+	  AND date_trunc('hour', o.date_created, 'UTC') = ANY($1::timestamptz[])
+	GROUP BY 1, 2, 3, 4, 5;
+	`
+	orderStatsQuery = `
+	SELECT date_trunc($1, o.date_created, 'UTC'),
+	       CASE WHEN $4::boolean THEN o.delivery_service ELSE '' END,
+	       CASE WHEN $5::boolean THEN d.region ELSE '' END,
+	       '',
+	       p.currency,
+	       COUNT(*),
+	       COALESCE(SUM(l.items), 0)::bigint,
+	       COALESCE(SUM(l.revenue), 0)::bigint
+	FROM orders o
+	JOIN deliveries d ON d.delivery_uid = o.delivery_uid
+	JOIN payments p   ON p.payment_uid = o.payment_uid
+	CROSS JOIN LATERAL (
+		SELECT SUM(oi.quantity) AS items, SUM(oi.total_price) AS revenue
+		FROM order_item oi
+		WHERE oi.order_uid = o.order_uid
+	) l
+	WHERE o.date_created >= $2 AND o.date_created < $3
+	GROUP BY 1, 2, 3, 4, 5
+	ORDER BY 1, 2, 3, 4, 5;
+	`
+	orderBrandStatsQuery = `
+	SELECT date_trunc($1, o.date_created, 'UTC'),
+	       CASE WHEN $4::boolean THEN o.delivery_service ELSE '' END,
+	       CASE WHEN $5::boolean THEN d.region ELSE '' END,
+	       i.brand,
+	       p.currency,
+	       COUNT(DISTINCT o.order_uid),
+	       SUM(oi.quantity)::bigint,
+	       SUM(oi.total_price)::bigint
+	FROM orders o
+	JOIN deliveries d  ON d.delivery_uid = o.delivery_uid
+	JOIN payments p    ON p.payment_uid = o.payment_uid
+	JOIN order_item oi ON oi.order_uid = o.order_uid
+	JOIN items i       ON i.item_uid = oi.item_uid
+	WHERE o.date_created >= $2 AND o.date_created < $3
+	GROUP BY 1, 2, 3, 4, 5
+	ORDER BY 1, 2, 3, 4, 5;
+	`
+	orderStatsRollupQuery = `
+	SELECT date_trunc($1, s.bucket, 'UTC'),
+	       CASE WHEN $4::boolean THEN s.delivery_service ELSE '' END,
+	       CASE WHEN $5::boolean THEN s.region ELSE '' END,
+	       '',
+	       s.currency,
+	       SUM(s.orders)::bigint,
+	       SUM(s.items)::bigint,
+	       SUM(s.revenue)::bigint
+	FROM order_stats_hourly s
+	WHERE s.bucket >= $2 AND s.bucket < $3
+	GROUP BY 1, 2, 3, 4, 5
+	ORDER BY 1, 2, 3, 4, 5;
+	`
+	// заказ относится к одной службе доставки и региону, поэтому сумма orders по бренду не задваивается
+	orderBrandStatsRollupQuery = `
+	SELECT date_trunc($1, s.bucket, 'UTC'),
+	       CASE WHEN $4::boolean THEN s.delivery_service ELSE '' END,
+	       CASE WHEN $5::boolean THEN s.region ELSE '' END,
+	       s.brand,
+	       s.currency,
+	       SUM(s.orders)::bigint,
+	       SUM(s.items)::bigint,
+	       SUM(s.revenue)::bigint
+	FROM order_brand_stats_hourly s
+	WHERE s.bucket >= $2 AND s.bucket < $3
+	GROUP BY 1, 2, 3, 4, 5
+	ORDER BY 1, 2, 3, 4, 5;
+	`
+	quarantineSaveQuery = `
+	INSERT INTO quarantined_orders (
+		id, order_uid, source, topic, partition, "offset", schema_version, payload, reason, report, status, key_id, dek
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+	);
+	`
+	quarantineGetQuery = `
+	SELECT id, order_uid, source, topic, partition, "offset", schema_version, payload, reason, report,
+	       status, reviewed_by, review_note, reviewed_at, created_at, key_id, dek
+	FROM quarantined_orders
+	WHERE id = $1;
+	`
+	quarantineListQuery = `
+	SELECT id, order_uid, source, topic, partition, "offset", schema_version, payload, reason, report,
+	       status, reviewed_by, review_note, reviewed_at, created_at, key_id, dek
+	FROM quarantined_orders q
+	WHERE ($1::text IS NULL OR q.status = $1)
+	  AND ($2::uuid IS NULL OR (q.created_at, q.id) > (SELECT a.created_at, a.id FROM quarantined_orders a WHERE a.id = $2))
+	ORDER BY q.created_at, q.id
+	LIMIT $3;
+	`
+	quarantineResolveQuery = `
+	UPDATE quarantined_orders
+	SET status = $2, order_uid = $3, payload = $4, reviewed_by = $5, review_note = $6, reviewed_at = $7,
+	    key_id = $8, dek = $9
+	WHERE id = $1 AND status = 'pending';
+	`
+	quarantineReencryptSelectQuery = `
+	SELECT id, payload, key_id, dek
+	FROM quarantined_orders
+	WHERE id > $1
+	  AND ($2::boolean OR key_id IS DISTINCT FROM $3)
+	ORDER BY id
+	LIMIT $4
+	FOR UPDATE;
+	`
+	quarantineReencryptUpdateQuery = `
+	UPDATE quarantined_orders
+	SET payload = $2, key_id = $3, dek = $4
+	WHERE id = $1;
+	`
 )
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"log/slog"
+	"time"
+)
+
+type PgStatsRepo struct {
+	logger *slog.Logger
+	db     *sql.DB
+	cfg    config.Config
+}
+
+var _ usecase.StatsRepo = (*PgStatsRepo)(nil)
+
+func NewPgStatsRepo(logger *slog.Logger, db *sql.DB, cfg config.Config) *PgStatsRepo {
+	return &PgStatsRepo{
+		logger: logger,
+		db:     db,
+		cfg:    cfg,
+	}
+}
+
+// OrderStats считает статистику по таблицам заказов
+func (pg *PgStatsRepo) OrderStats(ctx context.Context, query domain.OrderStatsQuery) ([]domain.OrderStatsRow, error) {
+	sqlQuery := orderStatsQuery
+	if query.Has(domain.StatsByBrand) {
+		sqlQuery = orderBrandStatsQuery
+	}
+	return pg.queryStats(ctx, sqlQuery, query)
+}
+
+// OrderStatsRollup читает статистику из почасовых агрегатов, From и To должны быть выровнены по часу
+func (pg *PgStatsRepo) OrderStatsRollup(ctx context.Context, query domain.OrderStatsQuery) ([]domain.OrderStatsRow, error) {
+	sqlQuery := orderStatsRollupQuery
+	if query.Has(domain.StatsByBrand) {
+		sqlQuery = orderBrandStatsRollupQuery
+	}
+	return pg.queryStats(ctx, sqlQuery, query)
+}
+
+func (pg *PgStatsRepo) queryStats(ctx context.Context, sqlQuery string, query domain.OrderStatsQuery) ([]domain.OrderStatsRow, error) {
+	var rows []domain.OrderStatsRow
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgGetTimeout)
+		defer cancel()
+
+		r, err := pg.db.QueryContext(funcCtx, sqlQuery,
+			string(query.Bucket),
+			query.From,
+			query.To,
+			query.Has(domain.StatsByDeliveryService),
+			query.Has(domain.StatsByRegion),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+
+		var funcRows []domain.OrderStatsRow
+		for r.Next() {
+			var (
+				row     domain.OrderStatsRow
+				revenue int64
+			)
+			err = r.Scan(
+				&row.Bucket,
+				&row.DeliveryService,
+				&row.Region,
+				&row.Brand,
+				&row.Currency,
+				&row.Orders,
+				&row.Items,
+				&revenue,
+			)
+			if err != nil {
+				return err
+			}
+
+			row.Bucket = row.Bucket.UTC()
+			row.Revenue = domain.NewMoney(revenue, row.Currency)
+			funcRows = append(funcRows, row)
+		}
+
+		if err = r.Err(); err != nil {
+			return err
+		}
+
+		rows = funcRows
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// RefreshRollup пересчитывает агрегаты не больше limit часов с новыми заказами и возвращает их число.
+// Часы забираются с SKIP LOCKED, поэтому несколько реплик не мешают друг другу
+func (pg *PgStatsRepo) RefreshRollup(ctx context.Context, limit int) (int, error) {
+	var refreshed int
+
+	err := retry(ctx, pg.logger, pg.cfg.PgMaxRetries, pg.cfg.PgBackoff, func() error {
+		funcCtx, cancel := context.WithTimeout(ctx, pg.cfg.PgSaveTimeout)
+		defer cancel()
+
+		tx, err := pg.db.BeginTx(funcCtx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		buckets, err := takeDirtyBuckets(funcCtx, tx, limit)
+		if err != nil {
+			return err
+		}
+		if len(buckets) == 0 {
+			refreshed = 0
+			return nil
+		}
+
+		// границы по самому часу нужны, чтобы отбор шел по индексу date_created
+		keys := make([]string, len(buckets))
+		from, to := buckets[0], buckets[0]
+		for i, bucket := range buckets {
+			keys[i] = bucket.Format(time.RFC3339)
+			if bucket.Before(from) {
+				from = bucket
+			}
+			if bucket.After(to) {
+				to = bucket
+			}
+		}
+		to = to.Add(time.Hour)
+
+		for _, step := range []struct {
+			query string
+			args  []any
+		}{
+			{statsHourlyClearQuery, []any{keys}},
+			{statsBrandHourlyClearQuery, []any{keys}},
+			{statsHourlyRefreshQuery, []any{keys, from, to}},
+			{statsBrandHourlyRefreshQuery, []any{keys, from, to}},
+		} {
+			if _, err = tx.ExecContext(funcCtx, step.query, step.args...); err != nil {
+				return err
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		refreshed = len(buckets)
+
+		return nil
+	})
+
+	return refreshed, err
+}
+
+func takeDirtyBuckets(ctx context.Context, tx *sql.Tx, limit int) ([]time.Time, error) {
+	r, err := tx.QueryContext(ctx, statsDirtyTakeQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var buckets []time.Time
+	for r.Next() {
+		var bucket time.Time
+		if err = r.Scan(&bucket); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket.UTC())
+	}
+
+	return buckets, r.Err()
+}
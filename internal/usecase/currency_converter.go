@@ -0,0 +1,222 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"gopkg.in/yaml.v3"
+	"math/big"
+	"os"
+	"time"
+)
+
+// ErrNoExchangeRate - в таблице курсов нет валюты платежа
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ExchangeRates - курсы к базовой валюте: сколько единиц Base стоит одна единица валюты.
+// Курс самой базовой валюты равен 1 и может не указываться
+type ExchangeRates struct {
+	Base      domain.Currency
+	Rates     map[domain.Currency]*big.Rat
+	UpdatedAt time.Time // когда таблица менялась последний раз, нулевое - неизвестно
+}
+
+// CurrencyConverter пересчитывает суммы в валюту отчетности, курс между двумя валютами
+// считается через базовую валюту таблицы. Результат округляется до минимальной единицы
+// валюты отчетности, половина - от нуля
+type CurrencyConverter struct {
+	reporting domain.Currency
+	rates     map[domain.Currency]*big.Rat // цена единицы валюты в валюте отчетности
+	updatedAt time.Time
+}
+
+// NewCurrencyConverter - без курса в таблице пересчитываются только суммы в самой валюте отчетности
+func NewCurrencyConverter(reporting domain.Currency, table ExchangeRates) (*CurrencyConverter, error) {
+	if !reporting.Valid() {
+		return nil, fmt.Errorf("reporting currency: %w %q", domain.ErrUnknownCurrency, reporting)
+	}
+
+	base := table.Base
+	if base == "" {
+		base = reporting
+	}
+	if !base.Valid() {
+		return nil, fmt.Errorf("base currency: %w %q", domain.ErrUnknownCurrency, base)
+	}
+
+	inBase := map[domain.Currency]*big.Rat{base: big.NewRat(1, 1)}
+	for currency, rate := range table.Rates {
+		if !currency.Valid() {
+			return nil, fmt.Errorf("rate: %w %q", domain.ErrUnknownCurrency, currency)
+		}
+		if rate == nil || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("rate for %s must be > 0", currency)
+		}
+		inBase[currency] = rate
+	}
+
+	reportingRate, ok := inBase[reporting]
+	if !ok {
+		return nil, fmt.Errorf("%w: reporting currency %s is missing in rates with base %s", ErrNoExchangeRate, reporting, base)
+	}
+
+	c := &CurrencyConverter{
+		reporting: reporting,
+		rates:     make(map[domain.Currency]*big.Rat, len(inBase)),
+		updatedAt: table.UpdatedAt,
+	}
+	for currency, rate := range inBase {
+		c.rates[currency] = new(big.Rat).Quo(rate, reportingRate)
+	}
+
+	return c, nil
+}
+
+// NewCurrencyConverterFromConfig - валюта отчетности MONEY_REPORTING_CURRENCY, курсы из MONEY_RATES_FILE.
+// Пустая валюта отчетности отключает пересчет, возвращается nil
+func NewCurrencyConverterFromConfig(cfg config.Config) (*CurrencyConverter, error) {
+	if cfg.MoneyReportingCurrency == "" {
+		return nil, nil
+	}
+
+	reporting, err := domain.ParseCurrency(cfg.MoneyReportingCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	var table ExchangeRates
+	if cfg.MoneyRatesFile != "" {
+		if table, err = LoadExchangeRates(cfg.MoneyRatesFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewCurrencyConverter(reporting, table)
+}
+
+// LoadExchangeRates читает таблицу курсов из YAML:
+//
+//	base: RUB
+//	rates:
+//	  USD: "81.25"
+//	  EUR: 94.1
+//
+// Курсы лучше задавать строками, десятичная дробь разбирается без потери точности.
+// Время изменения таблицы - время изменения файла
+func LoadExchangeRates(path string) (ExchangeRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ExchangeRates{}, fmt.Errorf("read exchange rates: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ExchangeRates{}, fmt.Errorf("read exchange rates: %w", err)
+	}
+
+	var file struct {
+		Base  string            `yaml:"base"`
+		Rates map[string]string `yaml:"rates"`
+	}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return ExchangeRates{}, fmt.Errorf("parse exchange rates: %w", err)
+	}
+
+	table := ExchangeRates{
+		Rates:     make(map[domain.Currency]*big.Rat, len(file.Rates)),
+		UpdatedAt: info.ModTime().UTC(),
+	}
+	if file.Base != "" {
+		if table.Base, err = domain.ParseCurrency(file.Base); err != nil {
+			return ExchangeRates{}, fmt.Errorf("exchange rates base: %w", err)
+		}
+	}
+	for code, value := range file.Rates {
+		currency, err := domain.ParseCurrency(code)
+		if err != nil {
+			return ExchangeRates{}, fmt.Errorf("exchange rates: %w", err)
+		}
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok {
+			return ExchangeRates{}, fmt.Errorf("exchange rates: rate for %s is not a number: %q", currency, value)
+		}
+		table.Rates[currency] = rate
+	}
+
+	return table, nil
+}
+
+// Reporting - валюта отчетности
+func (c *CurrencyConverter) Reporting() domain.Currency {
+	return c.reporting
+}
+
+// UpdatedAt - время изменения таблицы курсов, нулевое - неизвестно
+func (c *CurrencyConverter) UpdatedAt() time.Time {
+	return c.updatedAt
+}
+
+// Convert переводит сумму в валюту отчетности с учетом точности обеих валют
+func (c *CurrencyConverter) Convert(m domain.Money) (domain.Money, error) {
+	if m.Currency == c.reporting {
+		return m, nil
+	}
+
+	rate, ok := c.rates[m.Currency]
+	if !ok {
+		return domain.Money{}, fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, m.Currency, c.reporting)
+	}
+
+	// amount / 10^exp(from) * rate * 10^exp(to)
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	value.Mul(value, new(big.Rat).SetFrac(pow10(c.reporting.Exponent()), pow10(m.Currency.Exponent())))
+
+	amount, err := roundHalfAway(value)
+	if err != nil {
+		return domain.Money{}, fmt.Errorf("convert %s to %s: %w", m, c.reporting, err)
+	}
+
+	return domain.NewMoney(amount, c.reporting), nil
+}
+
+// Totals переводит итоги платежа в валюту отчетности
+func (c *CurrencyConverter) Totals(totals domain.Totals) (domain.Totals, error) {
+	var (
+		converted domain.Totals
+		err       error
+	)
+	for _, pair := range []struct {
+		from domain.Money
+		to   *domain.Money
+	}{
+		{totals.Amount, &converted.Amount},
+		{totals.GoodsTotal, &converted.GoodsTotal},
+		{totals.DeliveryCost, &converted.DeliveryCost},
+		{totals.CustomFee, &converted.CustomFee},
+	} {
+		if *pair.to, err = c.Convert(pair.from); err != nil {
+			return domain.Totals{}, err
+		}
+	}
+
+	return converted, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func roundHalfAway(value *big.Rat) (int64, error) {
+	quo, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+
+	// |rem| * 2 >= denom - округление от нуля
+	twice := new(big.Int).Abs(rem)
+	if twice.Lsh(twice, 1).Cmp(value.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(int64(value.Sign())))
+	}
+	if !quo.IsInt64() {
+		return 0, errors.New("amount overflows int64")
+	}
+
+	return quo.Int64(), nil
+}
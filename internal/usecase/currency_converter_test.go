@@ -0,0 +1,174 @@
+package usecase_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rat(t *testing.T, value string) *big.Rat {
+	t.Helper()
+	r, ok := new(big.Rat).SetString(value)
+	require.True(t, ok)
+	return r
+}
+
+func testConverter(t *testing.T) *usecase.CurrencyConverter {
+	t.Helper()
+	converter, err := usecase.NewCurrencyConverter("RUB", usecase.ExchangeRates{
+		Base: "EUR",
+		Rates: map[domain.Currency]*big.Rat{
+			"RUB": rat(t, "0.01"),
+			"USD": rat(t, "0.8"),
+			"JPY": rat(t, "0.00625"),
+			"KWD": rat(t, "2.5"),
+		},
+	})
+	require.NoError(t, err)
+	return converter
+}
+
+func TestMoney_Decimal(t *testing.T) {
+	tests := []struct {
+		money domain.Money
+		want  string
+	}{
+		{domain.NewMoney(1817, "USD"), "18.17"},
+		{domain.NewMoney(5, "RUB"), "0.05"},
+		{domain.NewMoney(-5, "RUB"), "-0.05"},
+		{domain.NewMoney(1817, "JPY"), "1817"},
+		{domain.NewMoney(1817, "KWD"), "1.817"},
+		{domain.NewMoney(0, "EUR"), "0.00"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.money.Decimal(), tt.money.Currency)
+	}
+	assert.Equal(t, "18.17 USD", domain.NewMoney(1817, "USD").String())
+}
+
+func TestParseCurrency(t *testing.T) {
+	currency, err := domain.ParseCurrency(" usd ")
+	require.NoError(t, err)
+	assert.Equal(t, domain.Currency("USD"), currency)
+
+	_, err = domain.ParseCurrency("ABC")
+	assert.ErrorIs(t, err, domain.ErrUnknownCurrency)
+}
+
+func TestCurrencyConverter_CrossRateAndPrecision(t *testing.T) {
+	converter := testConverter(t)
+
+	tests := []struct {
+		from domain.Money
+		want domain.Money
+	}{
+		// 10.00 USD = 8 EUR = 800 RUB
+		{domain.NewMoney(1000, "USD"), domain.NewMoney(80000, "RUB")},
+		// 160 JPY = 1 EUR, у иены нет дробной части
+		{domain.NewMoney(160, "JPY"), domain.NewMoney(10000, "RUB")},
+		// 1.000 KWD = 2.5 EUR, у динара три знака
+		{domain.NewMoney(1000, "KWD"), domain.NewMoney(25000, "RUB")},
+		// 1 JPY = 0.625 RUB, половина копейки округляется от нуля
+		{domain.NewMoney(1, "JPY"), domain.NewMoney(63, "RUB")},
+		{domain.NewMoney(-1, "JPY"), domain.NewMoney(-63, "RUB")},
+		{domain.NewMoney(1817, "RUB"), domain.NewMoney(1817, "RUB")},
+	}
+	for _, tt := range tests {
+		got, err := converter.Convert(tt.from)
+		require.NoError(t, err, tt.from.String())
+		assert.Equal(t, tt.want, got, tt.from.String())
+	}
+
+	_, err := converter.Convert(domain.NewMoney(1, "GBP"))
+	assert.ErrorIs(t, err, usecase.ErrNoExchangeRate)
+}
+
+func TestNewCurrencyConverter_InvalidTable(t *testing.T) {
+	_, err := usecase.NewCurrencyConverter("XYZ", usecase.ExchangeRates{})
+	assert.ErrorIs(t, err, domain.ErrUnknownCurrency)
+
+	_, err = usecase.NewCurrencyConverter("RUB", usecase.ExchangeRates{Base: "EUR"})
+	assert.ErrorIs(t, err, usecase.ErrNoExchangeRate)
+
+	_, err = usecase.NewCurrencyConverter("RUB", usecase.ExchangeRates{
+		Rates: map[domain.Currency]*big.Rat{"USD": rat(t, "0")},
+	})
+	assert.Error(t, err)
+}
+
+func TestLoadExchangeRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("base: rub\nrates:\n  USD: \"81.25\"\n  EUR: 94.1\n"), 0o600))
+
+	table, err := usecase.LoadExchangeRates(path)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Currency("RUB"), table.Base)
+	assert.Equal(t, rat(t, "81.25"), table.Rates["USD"])
+	assert.Equal(t, rat(t, "94.1"), table.Rates["EUR"])
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(table.UpdatedAt))
+
+	require.NoError(t, os.WriteFile(path, []byte("rates:\n  USD: abc\n"), 0o600))
+	_, err = usecase.LoadExchangeRates(path)
+	assert.Error(t, err)
+}
+
+func TestNewCurrencyConverterFromConfig_RepoRates(t *testing.T) {
+	converter, err := usecase.NewCurrencyConverterFromConfig(config.Config{
+		MoneyReportingCurrency: "RUB",
+		MoneyRatesFile:         "../../rates/exchange_rates.yaml",
+	})
+	require.NoError(t, err)
+
+	got, err := converter.Convert(domain.NewMoney(1817, "USD"))
+	require.NoError(t, err)
+	assert.Equal(t, "1476.31", got.Decimal())
+
+	converter, err = usecase.NewCurrencyConverterFromConfig(config.Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, converter)
+}
+
+func TestGetOrder_ReportingTotals(t *testing.T) {
+	uid := uuid.New()
+	usd := func(amount int64) domain.Money { return domain.NewMoney(amount, "USD") }
+	order := &domain.Order{OrderUID: uid, Payment: domain.Payment{
+		Currency:     "USD",
+		Amount:       usd(1817),
+		GoodsTotal:   usd(317),
+		DeliveryCost: usd(1500),
+		CustomFee:    usd(0),
+	}}
+
+	cache := new(MockCache)
+	cache.On("Get", uid).Return(order, nil)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := usecase.NewOrderService(logger, config.Config{}, new(MockRepo), cache, nil, nil, testConverter(t))
+
+	got, err := service.GetOrder(context.Background(), uid)
+	require.NoError(t, err)
+	require.NotNil(t, got.Reporting)
+	assert.Equal(t, domain.NewMoney(145360, "RUB"), got.Reporting.Amount)
+	assert.Equal(t, domain.NewMoney(120000, "RUB"), got.Reporting.DeliveryCost)
+	// заказ из кэша не меняется
+	assert.Nil(t, order.Reporting)
+
+	order.Payment = domain.Payment{Currency: "GBP", Amount: domain.NewMoney(100, "GBP")}
+	got, err = service.GetOrder(context.Background(), uid)
+	require.NoError(t, err)
+	assert.Nil(t, got.Reporting)
+}
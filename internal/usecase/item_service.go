@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"log/slog"
+)
+
+var ErrItemNotFound = errors.New("item not found")
+
+type ItemRepo interface {
+	// GetByNmID - все размеры артикула с историей версий и последними расхождениями, иначе ErrItemNotFound
+	GetByNmID(ctx context.Context, nmID int) (items []*domain.CatalogItem, err error)
+}
+
+// ItemService - каталог товаров и заказы с ними
+type ItemService struct {
+	logger *slog.Logger
+	repo   ItemRepo
+	orders *OrderService
+}
+
+func NewItemService(logger *slog.Logger, repo ItemRepo, orders *OrderService) *ItemService {
+	return &ItemService{
+		logger: logger,
+		repo:   repo,
+		orders: orders,
+	}
+}
+
+func (s *ItemService) GetItem(ctx context.Context, nmID int) ([]*domain.CatalogItem, error) {
+	return s.repo.GetByNmID(ctx, nmID)
+}
+
+// ItemOrders - страница заказов с артикулом nmID. Каталог заполняется только из заказов,
+// поэтому пустая первая страница означает неизвестный артикул - ErrItemNotFound
+func (s *ItemService) ItemOrders(ctx context.Context, nmID int, page domain.OrderPage) ([]*domain.Order, error) {
+	orders, err := s.orders.ListOrders(ctx, domain.OrderFilter{NmID: nmID}, page)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 && page.AfterOrderUID == uuid.Nil {
+		return nil, ErrItemNotFound
+	}
+
+	return orders, nil
+}
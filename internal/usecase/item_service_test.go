@@ -0,0 +1,50 @@
+package usecase_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemAttributes_Diff(t *testing.T) {
+	current := domain.ItemAttributes{Name: "Mascaras", Brand: "Vivienne Sabo", Size: "0"}
+
+	assert.Empty(t, current.Diff(current))
+	assert.Equal(t, []string{"name", "size"},
+		current.Diff(domain.ItemAttributes{Name: "Mascara", Brand: "Vivienne Sabo", Size: "1"}))
+}
+
+func TestItemOrders(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := new(MockRepo)
+	service := usecase.NewItemService(logger, nil, usecase.NewOrderService(logger, config.Config{}, repo, new(MockCache), nil, nil, nil))
+
+	order := &domain.Order{OrderUID: uuid.New(), DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)}
+	first := domain.OrderPage{Limit: 10}
+	repo.On("List", context.Background(), domain.OrderFilter{NmID: 2389212}, first).Return([]*domain.Order{order}, nil)
+
+	orders, err := service.ItemOrders(context.Background(), 2389212, first)
+	require.NoError(t, err)
+	assert.Equal(t, []*domain.Order{order}, orders)
+
+	// пустая первая страница - артикула нет в заказах
+	repo.On("List", context.Background(), domain.OrderFilter{NmID: 1}, first).Return([]*domain.Order(nil), nil)
+	_, err = service.ItemOrders(context.Background(), 1, first)
+	assert.ErrorIs(t, err, usecase.ErrItemNotFound)
+
+	// пустая следующая страница - конец выборки
+	next := domain.OrderPage{Limit: 10, AfterDateCreated: order.DateCreated, AfterOrderUID: order.OrderUID}
+	repo.On("List", context.Background(), domain.OrderFilter{NmID: 2389212}, next).Return([]*domain.Order(nil), nil)
+	orders, err = service.ItemOrders(context.Background(), 2389212, next)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
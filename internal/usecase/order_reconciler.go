@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/config"
+	"math"
+	"strconv"
+)
+
+// ReconcileMode - реакция на расхождение сумм в заказе
+type ReconcileMode string
+
+const (
+	ReconcileOff     ReconcileMode = "off"     // суммы не сверяются
+	ReconcileLenient ReconcileMode = "lenient" // расхождения пишутся в лог как предупреждения, заказ сохраняется
+	ReconcileStrict  ReconcileMode = "strict"  // заказ с расхождением не сохраняется и уходит в карантин
+)
+
+// CheckReconcile - значение Violation.Check для расхождения сумм
+const CheckReconcile = "reconcile"
+
+// ErrOrderQuarantined - заказ корректен по правилам, но суммы в нем не сходятся, его нужно разобрать вручную
+var ErrOrderQuarantined = errors.New("order quarantined")
+
+// OrderReconciler сверяет суммы заказа:
+// total_price позиции = price * quantity * (100 - sale) / 100,
+// goods_total = сумма total_price, amount = goods_total + delivery_cost + custom_fee.
+// Tolerance - допустимое расхождение в минимальных единицах валюты
+type OrderReconciler struct {
+	mode      ReconcileMode
+	tolerance int
+}
+
+// NewOrderReconciler - пустой mode равен ReconcileOff
+func NewOrderReconciler(mode ReconcileMode, tolerance int) (*OrderReconciler, error) {
+	switch mode {
+	case "":
+		mode = ReconcileOff
+	case ReconcileOff, ReconcileLenient, ReconcileStrict:
+	default:
+		return nil, fmt.Errorf("unknown reconcile mode %q", mode)
+	}
+	if tolerance < 0 {
+		return nil, fmt.Errorf("reconcile tolerance must be >= 0, got %d", tolerance)
+	}
+
+	return &OrderReconciler{
+		mode:      mode,
+		tolerance: tolerance,
+	}, nil
+}
+
+func NewOrderReconcilerFromConfig(cfg config.Config) (*OrderReconciler, error) {
+	return NewOrderReconciler(ReconcileMode(cfg.ValidationReconcileMode), cfg.ValidationReconcileTolerance)
+}
+
+// Reconcile возвращает расхождения сумм. В режиме strict они уровня error, в lenient - warning
+func (r *OrderReconciler) Reconcile(order *mapper.OrderIntoDomainDTO) []Violation {
+	if r == nil || r.mode == ReconcileOff {
+		return nil
+	}
+
+	severity := SeverityError
+	if r.mode == ReconcileLenient {
+		severity = SeverityWarning
+	}
+
+	var violations []Violation
+	mismatch := func(path string, expected, actual int) {
+		violations = append(violations, Violation{
+			Path:     path,
+			Check:    CheckReconcile,
+			Severity: severity,
+			Message:  "expected " + strconv.Itoa(expected) + ", got " + strconv.Itoa(actual),
+		})
+	}
+
+	goodsTotal := 0
+	for i, item := range order.Items {
+		goodsTotal += item.TotalPrice
+
+		quantity := item.Quantity
+		if quantity <= 0 {
+			// в сообщениях без quantity позиция - одна штука
+			quantity = 1
+		}
+
+		// скидка дает дробную сумму, округление до целого в любую сторону не считается расхождением
+		expected := float64(item.Price*quantity) * float64(100-item.Sale) / 100
+		if math.Abs(float64(item.TotalPrice)-expected) >= float64(r.tolerance)+1 {
+			mismatch("items["+strconv.Itoa(i)+"].total_price", int(math.Round(expected)), item.TotalPrice)
+		}
+	}
+
+	if abs(order.Payment.GoodsTotal-goodsTotal) > r.tolerance {
+		mismatch("payment.goods_total", goodsTotal, order.Payment.GoodsTotal)
+	}
+
+	amount := order.Payment.GoodsTotal + order.Payment.DeliveryCost + order.Payment.CustomFee
+	if abs(order.Payment.Amount-amount) > r.tolerance {
+		mismatch("payment.amount", amount, order.Payment.Amount)
+	}
+
+	return violations
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
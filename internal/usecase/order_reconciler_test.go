@@ -0,0 +1,108 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reconciledOrder - заказ из примера WB: 453 со скидкой 30% = 317, плюс доставка 1500
+func reconciledOrder() *mapper.OrderIntoDomainDTO {
+	order := validOrder()
+	order.Items = []mapper.ItemIntoDomainDTO{
+		{NmID: 2389212, Price: 453, Sale: 30, TotalPrice: 317, Quantity: 1},
+		{NmID: 2389213, Price: 100, Sale: 0, TotalPrice: 200, Quantity: 2},
+	}
+	order.Payment.GoodsTotal = 517
+	order.Payment.DeliveryCost = 1500
+	order.Payment.CustomFee = 0
+	order.Payment.Amount = 2017
+	return order
+}
+
+func newReconciler(t *testing.T, mode usecase.ReconcileMode, tolerance int) *usecase.OrderReconciler {
+	t.Helper()
+	reconciler, err := usecase.NewOrderReconciler(mode, tolerance)
+	require.NoError(t, err)
+	return reconciler
+}
+
+func TestReconcile_ConsistentOrder(t *testing.T) {
+	assert.Empty(t, newReconciler(t, usecase.ReconcileStrict, 0).Reconcile(reconciledOrder()))
+
+	// без quantity позиция считается одной штукой
+	order := reconciledOrder()
+	order.Items[0].Quantity = 0
+	assert.Empty(t, newReconciler(t, usecase.ReconcileStrict, 0).Reconcile(order))
+}
+
+func TestReconcile_Mismatches(t *testing.T) {
+	order := reconciledOrder()
+	order.Items[1].TotalPrice = 150
+	order.Payment.CustomFee = 10
+
+	violations := newReconciler(t, usecase.ReconcileStrict, 0).Reconcile(order)
+	assert.Equal(t, []string{"items[1].total_price", "payment.goods_total", "payment.amount"}, violationPaths(violations))
+	assert.Equal(t, "expected 200, got 150", violations[0].Message)
+	assert.Equal(t, "expected 467, got 517", violations[1].Message)
+	assert.Equal(t, "expected 2027, got 2017", violations[2].Message)
+	for _, v := range violations {
+		assert.Equal(t, usecase.CheckReconcile, v.Check)
+		assert.Equal(t, usecase.SeverityError, v.Severity)
+	}
+}
+
+func TestReconcile_Tolerance(t *testing.T) {
+	order := reconciledOrder()
+	order.Items[1].TotalPrice = 202
+	order.Payment.GoodsTotal = 519
+	order.Payment.Amount = 2020
+
+	assert.Len(t, newReconciler(t, usecase.ReconcileStrict, 0).Reconcile(order), 2)
+	assert.Empty(t, newReconciler(t, usecase.ReconcileStrict, 2).Reconcile(order))
+}
+
+func TestReconcile_Modes(t *testing.T) {
+	order := reconciledOrder()
+	order.Payment.Amount = 1
+
+	assert.Empty(t, newReconciler(t, usecase.ReconcileOff, 0).Reconcile(order))
+
+	violations := newReconciler(t, usecase.ReconcileLenient, 0).Reconcile(order)
+	require.Len(t, violations, 1)
+	assert.Equal(t, usecase.SeverityWarning, violations[0].Severity)
+
+	_, err := usecase.NewOrderReconciler("paranoid", 0)
+	assert.Error(t, err)
+	_, err = usecase.NewOrderReconciler(usecase.ReconcileStrict, -1)
+	assert.Error(t, err)
+}
+
+func TestValidate_QuarantinesUnreconciledOrder(t *testing.T) {
+	order := reconciledOrder()
+	order.Payment.GoodsTotal = 600
+
+	validator, err := usecase.NewOrderValidatorFromConfig(config.Config{ValidationReconcileMode: "strict"})
+	require.NoError(t, err)
+	_, err = validator.Validate(order)
+	assert.True(t, errors.Is(err, usecase.ErrOrderQuarantined))
+	assert.Equal(t, []string{"payment.goods_total", "payment.amount"}, violationPaths(usecase.ValidationViolations(err)))
+
+	// заказ, не прошедший правила, отклоняется, а не уходит в карантин
+	order.TrackNumber = ""
+	_, err = validator.Validate(order)
+	assert.False(t, errors.Is(err, usecase.ErrOrderQuarantined))
+	assert.Equal(t, []string{"track_number"}, violationPaths(usecase.ValidationViolations(err)))
+
+	validator, err = usecase.NewOrderValidatorFromConfig(config.Config{ValidationReconcileMode: "lenient"})
+	require.NoError(t, err)
+	order.TrackNumber = "TRACK123"
+	warnings, err := validator.Validate(order)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"payment.goods_total", "payment.amount"}, violationPaths(warnings))
+}
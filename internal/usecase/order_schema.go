@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SchemaRuleSet - значение ValidationError.RuleSet для нарушений JSON Schema
+const SchemaRuleSet = "schema"
+
+var (
+	// ErrInvalidOrderJSON - сообщение не является JSON
+	ErrInvalidOrderJSON = errors.New("order is not a valid JSON")
+	// ErrUnsupportedOrderVersion - версия формата не зарегистрирована или указана с ошибкой
+	ErrUnsupportedOrderVersion = errors.New("unsupported order schema version")
+	// ErrOrderEncoding - бинарное сообщение (Avro, Protobuf) не удалось перевести в JSON заказа
+	ErrOrderEncoding = errors.New("order message cannot be decoded")
+)
+
+// quotedName - имя свойства в сообщениях jsonschema о required и additionalProperties
+var quotedName = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// OrderDecoder проверяет JSON заказа по JSON Schema его версии, поднимает до CurrentOrderSchema
+// и раскладывает в DTO. В строгом режиме неизвестные поля запрещены и схемой, и при разборе в DTO
+type OrderDecoder struct {
+	versions       map[int]*orderVersion
+	defaultVersion int
+	strict         bool
+}
+
+type orderVersion struct {
+	schema *jsonschema.Schema
+	raw    []byte
+	upcast Upcaster
+}
+
+// NewOrderDecoder - defaultVersion применяется к сообщениям без версии в заголовке и без конверта
+func NewOrderDecoder(strict bool, defaultVersion int) (*OrderDecoder, error) {
+	d := &OrderDecoder{
+		versions:       make(map[int]*orderVersion, len(orderSchemas)),
+		defaultVersion: defaultVersion,
+		strict:         strict,
+	}
+
+	for _, registered := range orderSchemas {
+		raw := registered.schema
+		if strict {
+			var err error
+			if raw, err = strictSchema(raw); err != nil {
+				return nil, err
+			}
+		}
+
+		name := fmt.Sprintf("order.v%d.schema.json", registered.version)
+		compiler := jsonschema.NewCompiler()
+		compiler.AssertFormat = true
+		if err := compiler.AddResource(name, bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("load order schema v%d: %w", registered.version, err)
+		}
+		schema, err := compiler.Compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("compile order schema v%d: %w", registered.version, err)
+		}
+
+		d.versions[registered.version] = &orderVersion{
+			schema: schema,
+			raw:    raw,
+			upcast: registered.upcast,
+		}
+	}
+
+	if _, ok := d.versions[defaultVersion]; !ok {
+		return nil, fmt.Errorf("%w: default version %d", ErrUnsupportedOrderVersion, defaultVersion)
+	}
+
+	return d, nil
+}
+
+// Schema - действующая схема версии: в строгом режиме с additionalProperties: false
+func (d *OrderDecoder) Schema(version int) ([]byte, error) {
+	v, ok := d.versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedOrderVersion, version)
+	}
+	return v.raw, nil
+}
+
+// Decode возвращает ErrInvalidOrderJSON для не-JSON, ErrUnsupportedOrderVersion для неизвестной версии
+// и *ValidationError с RuleSet "schema" для нарушений схемы, пути нарушений - как у правил валидации: items[0].nm_id.
+// version - версия из заголовка транспорта, 0 - не указана: берется из конверта или версия по умолчанию
+func (d *OrderDecoder) Decode(data []byte, version int) (*mapper.OrderIntoDomainDTO, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOrderJSON, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: unexpected data after order", ErrInvalidOrderJSON)
+	}
+
+	doc, version, err := d.unwrap(doc, version)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := d.versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedOrderVersion, version)
+	}
+
+	if err = v.schema.Validate(doc); err != nil {
+		var schemaErr *jsonschema.ValidationError
+		if !errors.As(err, &schemaErr) {
+			return nil, err
+		}
+		violations := schemaViolations(schemaErr, nil)
+		// порядок причин в jsonschema не определен
+		slices.SortStableFunc(violations, func(a, b Violation) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+		return nil, &ValidationError{RuleSet: SchemaRuleSet, Violations: violations}
+	}
+
+	// схема версии гарантирует, что заказ - объект
+	order := doc.(map[string]any)
+	for ; version < CurrentOrderSchema; version++ {
+		if err = d.versions[version].upcast(order); err != nil {
+			return nil, fmt.Errorf("upcast order from v%d: %w", version, err)
+		}
+	}
+
+	if data, err = json.Marshal(order); err != nil {
+		return nil, err
+	}
+
+	dec = json.NewDecoder(bytes.NewReader(data))
+	if d.strict {
+		dec.DisallowUnknownFields()
+	}
+
+	var orderDTO mapper.OrderIntoDomainDTO
+	if err = dec.Decode(&orderDTO); err != nil {
+		// схема пропустила то, что не ложится в DTO: схема и DTO разошлись
+		return nil, &ValidationError{RuleSet: SchemaRuleSet, Violations: []Violation{{
+			Check:    "decode",
+			Severity: SeverityError,
+			Message:  err.Error(),
+		}}}
+	}
+
+	return &orderDTO, nil
+}
+
+// unwrap достает заказ из конверта {"schema_version": 2, "order": {...}} и определяет версию.
+// Версия из заголовка и из конверта должны совпадать
+func (d *OrderDecoder) unwrap(doc any, version int) (any, int, error) {
+	envelope, ok := doc.(map[string]any)
+	if !ok {
+		return doc, d.versionOrDefault(version), nil
+	}
+	rawVersion, ok := envelope[envelopeVersionKey]
+	if !ok {
+		return doc, d.versionOrDefault(version), nil
+	}
+
+	number, ok := rawVersion.(json.Number)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s must be an integer", ErrUnsupportedOrderVersion, envelopeVersionKey)
+	}
+	envelopeVersion, err := strconv.Atoi(number.String())
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s must be an integer", ErrUnsupportedOrderVersion, envelopeVersionKey)
+	}
+	if version != 0 && version != envelopeVersion {
+		return nil, 0, fmt.Errorf("%w: header version %d, envelope version %d", ErrUnsupportedOrderVersion, version, envelopeVersion)
+	}
+
+	order, ok := envelope[envelopeOrderKey]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: envelope has no %s", ErrInvalidOrderJSON, envelopeOrderKey)
+	}
+
+	return order, envelopeVersion, nil
+}
+
+func (d *OrderDecoder) versionOrDefault(version int) int {
+	if version == 0 {
+		return d.defaultVersion
+	}
+	return version
+}
+
+// schemaViolations собирает листья дерева ошибок jsonschema
+func schemaViolations(err *jsonschema.ValidationError, violations []Violation) []Violation {
+	// oneOf в схемах описывает только формы даты, провал всех веток - одно нарушение формата
+	if strings.HasSuffix(err.KeywordLocation, "/oneOf") {
+		return append(violations, Violation{
+			Path:     pointerToPath(err.InstanceLocation),
+			Check:    "format",
+			Severity: SeverityError,
+			Message:  "must be RFC3339 date-time or unix seconds",
+		})
+	}
+
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			violations = schemaViolations(cause, violations)
+		}
+		return violations
+	}
+
+	path := pointerToPath(err.InstanceLocation)
+	check := err.KeywordLocation[strings.LastIndex(err.KeywordLocation, "/")+1:]
+
+	// required и additionalProperties относятся к объекту, нарушение пишется на каждое поле
+	if check == "required" || check == "additionalProperties" {
+		message := "is required"
+		if check == "additionalProperties" {
+			message = "is not allowed"
+		}
+		for _, match := range quotedName.FindAllStringSubmatch(err.Message, -1) {
+			violations = append(violations, Violation{
+				Path:     joinPath(path, strings.ReplaceAll(match[1], `\'`, `'`)),
+				Check:    check,
+				Severity: SeverityError,
+				Message:  message,
+			})
+		}
+		return violations
+	}
+
+	return append(violations, Violation{
+		Path:     path,
+		Check:    check,
+		Severity: SeverityError,
+		Message:  err.Message,
+	})
+}
+
+// pointerToPath переводит JSON Pointer /items/0/nm_id в items[0].nm_id
+func pointerToPath(pointer string) string {
+	var path string
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		if _, err := strconv.Atoi(segment); err == nil {
+			path += "[" + segment + "]"
+			continue
+		}
+		path = joinPath(path, segment)
+	}
+	return path
+}
+
+// strictSchema добавляет additionalProperties: false всем объектам схемы, где оно не задано
+func strictSchema(raw []byte) ([]byte, error) {
+	var schema any
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if _, ok := v["properties"]; ok {
+				if _, ok = v["additionalProperties"]; !ok {
+					v["additionalProperties"] = false
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(schema)
+
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,130 @@
+package usecase_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDecoder(t *testing.T, strict bool) *usecase.OrderDecoder {
+	t.Helper()
+	decoder, err := usecase.NewOrderDecoder(strict, usecase.OrderSchemaV1)
+	require.NoError(t, err)
+	return decoder
+}
+
+// orderDocument - validOrder в виде JSON-объекта, чтобы портить отдельные поля
+func orderDocument(t *testing.T) map[string]any {
+	t.Helper()
+	raw, err := json.Marshal(validOrder())
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(raw, &doc))
+	return doc
+}
+
+func marshalDocument(t *testing.T, doc map[string]any) []byte {
+	t.Helper()
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestDecodeOrder_Valid(t *testing.T) {
+	order := validOrder()
+	order.Items[0].Quantity = 1
+	raw, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	for _, strict := range []bool{false, true} {
+		decoded, err := newDecoder(t, strict).Decode(raw, usecase.CurrentOrderSchema)
+		require.NoError(t, err)
+		assert.Equal(t, order.OrderUID, decoded.OrderUID)
+		assert.Equal(t, order.Items, decoded.Items)
+	}
+}
+
+func TestDecodeOrder_SchemaViolations(t *testing.T) {
+	doc := orderDocument(t)
+	delete(doc["payment"].(map[string]any), "amount")
+	doc["items"].([]any)[0].(map[string]any)["nm_id"] = "1"
+	doc["date_created"] = "yesterday"
+
+	_, err := newDecoder(t, false).Decode(marshalDocument(t, doc), 0)
+
+	var validationErr *usecase.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, usecase.SchemaRuleSet, validationErr.RuleSet)
+	assert.Equal(t, []string{"date_created", "items[0].nm_id", "payment.amount"}, violationPaths(validationErr.Violations))
+	assert.Equal(t, "format", validationErr.Violations[0].Check)
+	assert.Equal(t, "type", validationErr.Violations[1].Check)
+	assert.Equal(t, "required", validationErr.Violations[2].Check)
+}
+
+func TestDecodeOrder_UnknownFields(t *testing.T) {
+	doc := orderDocument(t)
+	doc["items"].([]any)[0].(map[string]any)["color"] = "red"
+	raw := marshalDocument(t, doc)
+
+	_, err := newDecoder(t, false).Decode(raw, 0)
+	assert.NoError(t, err)
+
+	_, err = newDecoder(t, true).Decode(raw, 0)
+	violations := usecase.ValidationViolations(err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "items[0].color", violations[0].Path)
+	assert.Equal(t, "additionalProperties", violations[0].Check)
+	for _, version := range []int{usecase.OrderSchemaV1, usecase.OrderSchemaV2} {
+		strictSchema, err := newDecoder(t, true).Schema(version)
+		require.NoError(t, err)
+		assert.Contains(t, string(strictSchema), `"additionalProperties": false`)
+
+		schema, err := newDecoder(t, false).Schema(version)
+		require.NoError(t, err)
+		assert.NotContains(t, string(schema), `"additionalProperties"`)
+	}
+}
+
+func TestDecodeOrder_InvalidJSON(t *testing.T) {
+	decoder := newDecoder(t, false)
+	raw, err := json.Marshal(validOrder())
+	require.NoError(t, err)
+
+	for _, data := range [][]byte{[]byte("{"), []byte("not json"), append(raw, []byte("{}")...)} {
+		_, err := decoder.Decode(data, 0)
+		assert.ErrorIs(t, err, usecase.ErrInvalidOrderJSON)
+	}
+}
+
+func TestDecodeOrder_Timestamps(t *testing.T) {
+	created := time.Date(2021, 11, 26, 3, 22, 19, 0, time.UTC)
+	decoder := newDecoder(t, false)
+
+	unix := strconv.FormatInt(created.Unix(), 10)
+	for _, value := range []any{"2021-11-26T06:22:19+03:00", created.Unix(), unix} {
+		doc := orderDocument(t)
+		doc["date_created"] = value
+		doc["payment"].(map[string]any)["payment_dt"] = value
+
+		decoded, err := decoder.Decode(marshalDocument(t, doc), 0)
+		require.NoError(t, err, value)
+		assert.True(t, decoded.DateCreated.Equal(created), value)
+		assert.Equal(t, time.UTC, decoded.DateCreated.Location())
+		assert.True(t, decoded.Payment.PaymentDT.Equal(created), value)
+	}
+
+	// время без зоны и дробные секунды не принимаются
+	for _, value := range []any{"2021-11-26T06:22:19", "1637907727.5", 1637907727.5} {
+		doc := orderDocument(t)
+		doc["date_created"] = value
+		_, err := decoder.Decode(marshalDocument(t, doc), 0)
+		violations := usecase.ValidationViolations(err)
+		assert.Equal(t, []string{"date_created"}, violationPaths(violations), value)
+		assert.Equal(t, "format", violations[0].Check, value)
+	}
+}
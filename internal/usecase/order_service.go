@@ -2,16 +2,27 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
 	"github.com/folivorra/get_order/internal/config"
 	"github.com/folivorra/get_order/internal/domain"
 	"github.com/google/uuid"
 	"log/slog"
+	"time"
 )
 
 type OrderRepo interface {
 	Get(ctx context.Context, uid uuid.UUID) (order *domain.Order, err error)
+	GetMany(ctx context.Context, uids []uuid.UUID) (orders map[uuid.UUID]*domain.Order, err error)
 	Save(ctx context.Context, order *domain.Order) (err error)
+	// SaveWithIdempotencyKey сохраняет заказ и ключ одной транзакцией, ключ уже занят - ErrIdempotencyKeyExists
+	SaveWithIdempotencyKey(ctx context.Context, order *domain.Order, key *domain.IdempotencyKey) (err error)
+	SaveBatch(ctx context.Context, orders []*domain.Order) (errs []error, err error)
+	Exists(ctx context.Context, uid uuid.UUID) (exists bool, err error)
 	GetLastN(ctx context.Context, n int) (orders []*domain.Order, err error)
+	List(ctx context.Context, filter domain.OrderFilter, page domain.OrderPage) (orders []*domain.Order, err error)
+	Stream(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) (err error)
 }
 
 type OrderCache interface {
@@ -19,39 +30,149 @@ type OrderCache interface {
 	Set(order *domain.Order)
 }
 
+// OrderPublisher оповещает подписчиков о сохраненных заказах, Publish не должен блокироваться
+type OrderPublisher interface {
+	Publish(order *domain.Order)
+}
+
 type OrderService struct {
-	logger *slog.Logger
-	cfg    config.Config
-	repo   OrderRepo
-	cache  OrderCache
+	logger    *slog.Logger
+	cfg       config.Config
+	repo      OrderRepo
+	cache     OrderCache
+	publisher OrderPublisher
+	validator *OrderValidator
+	converter *CurrencyConverter
 }
 
-func NewOrderService(logger *slog.Logger, cfg config.Config, repo OrderRepo, cache OrderCache) *OrderService {
+// NewOrderService создает сервис, publisher может быть nil, если рассылка новых заказов не нужна,
+// validator - nil, если достаточно правил DefaultRuleSets, converter - nil, если итоги в валюте отчетности не нужны
+func NewOrderService(logger *slog.Logger, cfg config.Config, repo OrderRepo, cache OrderCache, publisher OrderPublisher, validator *OrderValidator, converter *CurrencyConverter) *OrderService {
+	if validator == nil {
+		validator, _ = NewOrderValidator(DefaultRuleSets())
+	}
+
 	return &OrderService{
-		logger: logger,
-		cfg:    cfg,
-		repo:   repo,
-		cache:  cache,
+		logger:    logger,
+		cfg:       cfg,
+		repo:      repo,
+		cache:     cache,
+		publisher: publisher,
+		validator: validator,
+		converter: converter,
+	}
+}
+
+// DecodeOrder разбирает JSON заказа с проверкой по JSON Schema и поднимает его до текущей версии.
+// version - версия из заголовка сообщения или запроса, 0 - не указана
+func (s *OrderService) DecodeOrder(data []byte, version int) (*mapper.OrderIntoDomainDTO, error) {
+	return s.validator.Decode(data, version)
+}
+
+// DecodeProtoOrder - DecodeOrder для order.v1.Order: сообщение проверяется схемой и поднимается
+// до текущей версии так же, как JSON версии ProtoOrderSchema, откуда бы оно ни пришло
+func (s *OrderService) DecodeProtoOrder(order *orderv1.Order) (*mapper.OrderIntoDomainDTO, error) {
+	data, err := json.Marshal(mapper.ConvertProtoToDTO(order))
+	if err != nil {
+		return nil, err
 	}
+	return s.DecodeOrder(data, ProtoOrderSchema)
+}
+
+// OrderSchema - JSON Schema входящего заказа версии version для продюсеров
+func (s *OrderService) OrderSchema(version int) ([]byte, error) {
+	return s.validator.Schema(version)
+}
+
+// ValidateOrder проверяет заказ правилами валидатора, предупреждения только пишутся в лог
+func (s *OrderService) ValidateOrder(ctx context.Context, order *mapper.OrderIntoDomainDTO) error {
+	warnings, err := s.validator.Validate(order)
+	for _, warning := range warnings {
+		s.logger.WarnContext(ctx, "order validation warning",
+			slog.String("uuid", order.OrderUID.String()),
+			slog.String("path", warning.Path),
+			slog.String("check", warning.Check),
+			slog.String("message", warning.Message),
+		)
+	}
+	return err
 }
 
 func (s *OrderService) ProcessIncomingOrder(ctx context.Context, order *domain.Order) error {
-	// need to give uuid for objects before save in repo
-	order.Delivery.DeliveryUID = uuid.New()
-	order.Payment.PaymentUID = uuid.New()
-	for i := range order.Items {
-		order.Items[i].OrderItemUID = uuid.New()
+	assignUIDs(order)
+
+	if err := s.repo.Save(ctx, order); err != nil {
+		return err
+	}
+
+	s.publish(order)
+
+	return nil
+}
+
+// ProcessIdempotentOrder - ProcessIncomingOrder, который сохраняет ключ идемпотентности вместе с заказом
+func (s *OrderService) ProcessIdempotentOrder(ctx context.Context, order *domain.Order, key *domain.IdempotencyKey) error {
+	assignUIDs(order)
+
+	if err := s.repo.SaveWithIdempotencyKey(ctx, order, key); err != nil {
+		return err
 	}
 
-	return s.repo.Save(ctx, order)
+	s.publish(order)
+
+	return nil
+}
+
+// ImportOrders сохраняет заказы пачкой, errs[i] - результат для orders[i]
+func (s *OrderService) ImportOrders(ctx context.Context, orders []*domain.Order) ([]error, error) {
+	for _, order := range orders {
+		assignUIDs(order)
+	}
+
+	errs, err := s.repo.SaveBatch(ctx, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, order := range orders {
+		if errs[i] == nil {
+			s.publish(order)
+		}
+	}
+
+	return errs, nil
+}
+
+func (s *OrderService) ListOrders(ctx context.Context, filter domain.OrderFilter, page domain.OrderPage) ([]*domain.Order, error) {
+	orders, err := s.repo.List(ctx, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, order := range orders {
+		orders[i] = s.withReporting(ctx, order)
+	}
+	return orders, nil
+}
+
+func (s *OrderService) OrderExists(ctx context.Context, uid uuid.UUID) (bool, error) {
+	if _, err := s.cache.Get(uid); err == nil {
+		return true, nil
+	}
+
+	return s.repo.Exists(ctx, uid)
 }
 
 func (s *OrderService) GetOrder(ctx context.Context, uuid uuid.UUID) (*domain.Order, error) {
 	order, err := s.cache.Get(uuid)
 	if err == nil {
-		return order, nil
+		return s.withReporting(ctx, order), nil
 	}
 
+	s.logger.DebugContext(ctx, "order cache miss, loading from db",
+		slog.String("uuid", uuid.String()),
+	)
+
 	order, err = s.repo.Get(ctx, uuid)
 	if err != nil {
 		return nil, err
@@ -59,7 +180,37 @@ func (s *OrderService) GetOrder(ctx context.Context, uuid uuid.UUID) (*domain.Or
 
 	s.cache.Set(order)
 
-	return order, err
+	return s.withReporting(ctx, order), nil
+}
+
+// GetOrders отдает заказы из кэша, а недостающие загружает из репозитория одним запросом
+func (s *OrderService) GetOrders(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+	orders := make(map[uuid.UUID]*domain.Order, len(uids))
+	var missing []uuid.UUID
+
+	for _, uid := range uids {
+		if order, err := s.cache.Get(uid); err == nil {
+			orders[uid] = s.withReporting(ctx, order)
+			continue
+		}
+		missing = append(missing, uid)
+	}
+
+	if len(missing) == 0 {
+		return orders, nil
+	}
+
+	loaded, err := s.repo.GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for uid, order := range loaded {
+		s.cache.Set(order)
+		orders[uid] = s.withReporting(ctx, order)
+	}
+
+	return orders, nil
 }
 
 func (s *OrderService) WarmUpCache(ctx context.Context, warmUpSize int) error {
@@ -74,3 +225,60 @@ func (s *OrderService) WarmUpCache(ctx context.Context, warmUpSize int) error {
 
 	return nil
 }
+
+// RatesUpdatedAt - время изменения курсов, по которым считаются итоги в валюте отчетности.
+// Нулевое, если пересчета нет или время неизвестно
+func (s *OrderService) RatesUpdatedAt() time.Time {
+	if s.converter == nil {
+		return time.Time{}
+	}
+	return s.converter.UpdatedAt()
+}
+
+func (s *OrderService) ExportOrders(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) error {
+	return s.repo.Stream(ctx, filter, fn)
+}
+
+// withReporting возвращает копию заказа с итогами в валюте отчетности: исходный заказ может лежать в кэше.
+// Заказ в валюте без курса отдается без них
+func (s *OrderService) withReporting(ctx context.Context, order *domain.Order) *domain.Order {
+	if s.converter == nil || order == nil {
+		return order
+	}
+
+	totals, err := s.converter.Totals(order.Payment.Totals())
+	if err != nil {
+		s.logger.DebugContext(ctx, "order totals are not converted",
+			slog.String("uuid", order.OrderUID.String()),
+			slog.String("error", err.Error()),
+		)
+		return order
+	}
+
+	converted := *order
+	converted.Reporting = &totals
+	return &converted
+}
+
+func assignUIDs(order *domain.Order) {
+	// need to give uuid for objects before save in repo
+	order.Delivery.DeliveryUID = uuid.New()
+	order.Payment.PaymentUID = uuid.New()
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.OrderItemUID = uuid.New()
+		// товар без item_uid иначе делил бы строку items с другими заказами
+		if item.ItemUID == uuid.Nil {
+			item.ItemUID = uuid.New()
+			if item.Item != nil {
+				item.Item.ItemUID = item.ItemUID
+			}
+		}
+	}
+}
+
+func (s *OrderService) publish(order *domain.Order) {
+	if s.publisher != nil {
+		s.publisher.Publish(order)
+	}
+}
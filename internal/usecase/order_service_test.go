@@ -24,16 +24,46 @@ func (m *MockRepo) Get(ctx context.Context, uid uuid.UUID) (*domain.Order, error
 	return args.Get(0).(*domain.Order), args.Error(1)
 }
 
+func (m *MockRepo) GetMany(ctx context.Context, uids []uuid.UUID) (map[uuid.UUID]*domain.Order, error) {
+	args := m.Called(ctx, uids)
+	return args.Get(0).(map[uuid.UUID]*domain.Order), args.Error(1)
+}
+
 func (m *MockRepo) Save(ctx context.Context, order *domain.Order) error {
 	args := m.Called(ctx, order)
 	return args.Error(0)
 }
 
+func (m *MockRepo) SaveWithIdempotencyKey(ctx context.Context, order *domain.Order, key *domain.IdempotencyKey) error {
+	args := m.Called(ctx, order, key)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SaveBatch(ctx context.Context, orders []*domain.Order) ([]error, error) {
+	args := m.Called(ctx, orders)
+	return args.Get(0).([]error), args.Error(1)
+}
+
+func (m *MockRepo) Exists(ctx context.Context, uid uuid.UUID) (bool, error) {
+	args := m.Called(ctx, uid)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockRepo) GetLastN(ctx context.Context, n int) ([]*domain.Order, error) {
 	args := m.Called(ctx, n)
 	return args.Get(0).([]*domain.Order), args.Error(1)
 }
 
+func (m *MockRepo) List(ctx context.Context, filter domain.OrderFilter, page domain.OrderPage) ([]*domain.Order, error) {
+	args := m.Called(ctx, filter, page)
+	return args.Get(0).([]*domain.Order), args.Error(1)
+}
+
+func (m *MockRepo) Stream(ctx context.Context, filter domain.OrderFilter, fn func(order *domain.Order) error) error {
+	args := m.Called(ctx, filter, fn)
+	return args.Error(0)
+}
+
 type MockCache struct {
 	mock.Mock
 }
@@ -47,6 +77,14 @@ func (m *MockCache) Set(order *domain.Order) {
 	m.Called(order)
 }
 
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(order *domain.Order) {
+	m.Called(order)
+}
+
 func TestProcessIncomingOrder_SavesOrder(t *testing.T) {
 	ctx := context.Background()
 	repo := new(MockRepo)
@@ -60,7 +98,7 @@ func TestProcessIncomingOrder_SavesOrder(t *testing.T) {
 		),
 	)
 	cfg := config.NewConfig(logger)
-	service := usecase.NewOrderService(logger, cfg, repo, cache)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, nil, nil, nil)
 
 	order := &domain.Order{
 		Items:    []domain.OrderItem{{}},
@@ -93,7 +131,7 @@ func TestGetOrder_CacheHit(t *testing.T) {
 		),
 	)
 	cfg := config.NewConfig(logger)
-	service := usecase.NewOrderService(logger, cfg, repo, cache)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, nil, nil, nil)
 
 	cache.On("Get", uid).Return(order, nil)
 
@@ -120,7 +158,7 @@ func TestGetOrder_CacheMiss(t *testing.T) {
 		),
 	)
 	cfg := config.NewConfig(logger)
-	service := usecase.NewOrderService(logger, cfg, repo, cache)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, nil, nil, nil)
 
 	cache.On("Get", uid).Return(&domain.Order{}, errors.New("not found"))
 	repo.On("Get", ctx, uid).Return(order, nil)
@@ -151,7 +189,7 @@ func TestWarmUpCache_SetsOrders(t *testing.T) {
 		),
 	)
 	cfg := config.NewConfig(logger)
-	service := usecase.NewOrderService(logger, cfg, repo, cache)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, nil, nil, nil)
 
 	repo.On("GetLastN", ctx, 2).Return(orders, nil)
 	cache.On("Set", orders[0]).Return()
@@ -163,3 +201,134 @@ func TestWarmUpCache_SetsOrders(t *testing.T) {
 	cache.AssertCalled(t, "Set", orders[0])
 	cache.AssertCalled(t, "Set", orders[1])
 }
+
+func TestImportOrders_AssignsUIDsAndSavesBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	cache := new(MockCache)
+	logger := slog.New(
+		slog.NewTextHandler(
+			os.Stdout, &slog.HandlerOptions{
+				Level:     slog.LevelDebug,
+				AddSource: true,
+			},
+		),
+	)
+	cfg := config.NewConfig(logger)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, nil, nil, nil)
+
+	orders := []*domain.Order{
+		{Items: []domain.OrderItem{{}}},
+		{Items: []domain.OrderItem{{}, {}}},
+	}
+	errs := []error{nil, errors.New("duplicate")}
+
+	repo.On("SaveBatch", ctx, orders).Return(errs, nil)
+
+	got, err := service.ImportOrders(ctx, orders)
+
+	assert.NoError(t, err)
+	assert.Equal(t, errs, got)
+	for _, order := range orders {
+		assert.NotEqual(t, uuid.Nil, order.Delivery.DeliveryUID)
+		assert.NotEqual(t, uuid.Nil, order.Payment.PaymentUID)
+		for _, item := range order.Items {
+			assert.NotEqual(t, uuid.Nil, item.OrderItemUID)
+		}
+	}
+}
+
+func TestProcessIncomingOrder_PublishesOnlySavedOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	cache := new(MockCache)
+	publisher := new(MockPublisher)
+	logger := slog.New(
+		slog.NewTextHandler(
+			os.Stdout, &slog.HandlerOptions{
+				Level:     slog.LevelDebug,
+				AddSource: true,
+			},
+		),
+	)
+	cfg := config.NewConfig(logger)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, publisher, nil, nil)
+
+	saved := &domain.Order{OrderUID: uuid.New()}
+	failed := &domain.Order{OrderUID: uuid.New()}
+
+	repo.On("Save", ctx, saved).Return(nil)
+	repo.On("Save", ctx, failed).Return(errors.New("db is down"))
+	publisher.On("Publish", saved).Return()
+
+	assert.NoError(t, service.ProcessIncomingOrder(ctx, saved))
+	assert.Error(t, service.ProcessIncomingOrder(ctx, failed))
+
+	publisher.AssertCalled(t, "Publish", saved)
+	publisher.AssertNotCalled(t, "Publish", failed)
+}
+
+func TestGetOrders_LoadsOnlyMissingInOneCall(t *testing.T) {
+	ctx := context.Background()
+	cached := &domain.Order{OrderUID: uuid.New()}
+	stored := &domain.Order{OrderUID: uuid.New()}
+	unknown := uuid.New()
+
+	repo := new(MockRepo)
+	cache := new(MockCache)
+	logger := slog.New(
+		slog.NewTextHandler(
+			os.Stdout, &slog.HandlerOptions{
+				Level:     slog.LevelDebug,
+				AddSource: true,
+			},
+		),
+	)
+	cfg := config.NewConfig(logger)
+	service := usecase.NewOrderService(logger, cfg, repo, cache, nil, nil, nil)
+
+	cache.On("Get", cached.OrderUID).Return(cached, nil)
+	cache.On("Get", stored.OrderUID).Return(&domain.Order{}, errors.New("not found"))
+	cache.On("Get", unknown).Return(&domain.Order{}, errors.New("not found"))
+	repo.On("GetMany", ctx, []uuid.UUID{stored.OrderUID, unknown}).
+		Return(map[uuid.UUID]*domain.Order{stored.OrderUID: stored}, nil)
+	cache.On("Set", stored).Return()
+
+	got, err := service.GetOrders(ctx, []uuid.UUID{cached.OrderUID, stored.OrderUID, unknown})
+
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, cached, got[cached.OrderUID])
+	assert.Equal(t, stored, got[stored.OrderUID])
+	repo.AssertNumberOfCalls(t, "GetMany", 1)
+}
+
+func TestProcessIncomingOrder_AssignsItemUIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := usecase.NewOrderService(logger, config.NewConfig(logger), repo, new(MockCache), nil, nil, nil)
+
+	// товары сохраняются как в items: повторный item_uid не перезаписывает строку
+	items := make(map[uuid.UUID]domain.Item)
+	repo.On("Save", ctx, mock.AnythingOfType("*domain.Order")).Run(func(args mock.Arguments) {
+		for _, item := range args.Get(1).(*domain.Order).Items {
+			if _, ok := items[item.ItemUID]; !ok {
+				items[item.ItemUID] = *item.Item
+			}
+		}
+	}).Return(nil)
+
+	first := &domain.Order{Items: []domain.OrderItem{{Item: &domain.Item{Name: "Mascaras", Brand: "Vivienne Sabo"}}}}
+	second := &domain.Order{Items: []domain.OrderItem{{Item: &domain.Item{Name: "Lipstick", Brand: "Maybelline"}}}}
+	assert.NoError(t, service.ProcessIncomingOrder(ctx, first))
+	assert.NoError(t, service.ProcessIncomingOrder(ctx, second))
+
+	for _, order := range []*domain.Order{first, second} {
+		item := order.Items[0]
+		assert.NotEqual(t, uuid.Nil, item.ItemUID)
+		assert.Equal(t, item.ItemUID, item.Item.ItemUID)
+		assert.Equal(t, *item.Item, items[item.ItemUID])
+	}
+	assert.NotEqual(t, first.Items[0].ItemUID, second.Items[0].ItemUID)
+}
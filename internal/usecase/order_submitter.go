@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"log/slog"
+)
+
+var (
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrIdempotencyKeyExists   = errors.New("idempotency key already exists")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key reused with different payload")
+)
+
+// IdempotencyRepo читает ключи, сохраняются они вместе с заказом через OrderRepo.SaveWithIdempotencyKey
+type IdempotencyRepo interface {
+	Get(ctx context.Context, key string) (record *domain.IdempotencyKey, err error)
+}
+
+// OrderSubmitter принимает заказы в обход Kafka (например, по HTTP) с поддержкой ключей идемпотентности
+type OrderSubmitter struct {
+	logger  *slog.Logger
+	service *OrderService
+	keys    IdempotencyRepo
+}
+
+func NewOrderSubmitter(logger *slog.Logger, service *OrderService, keys IdempotencyRepo) *OrderSubmitter {
+	return &OrderSubmitter{
+		logger:  logger,
+		service: service,
+		keys:    keys,
+	}
+}
+
+// Submit сохраняет заказ через ProcessIncomingOrder. Если ключ уже встречался с тем же requestHash,
+// заказ повторно не сохраняется и возвращается replayed = true. Ключ пишется в одной транзакции с заказом,
+// поэтому из параллельных запросов с одним ключом заказ сохраняет только первый, остальные получают его ответ
+func (s *OrderSubmitter) Submit(ctx context.Context, key, requestHash string, order *domain.Order) (uuid.UUID, bool, error) {
+	if key == "" {
+		return order.OrderUID, false, s.service.ProcessIncomingOrder(ctx, order)
+	}
+
+	if uid, replayed, err := s.replay(ctx, key, requestHash); replayed || err != nil {
+		return uid, replayed, err
+	}
+
+	err := s.service.ProcessIdempotentOrder(ctx, order, &domain.IdempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+		OrderUID:    order.OrderUID,
+	})
+	if errors.Is(err, ErrIdempotencyKeyExists) {
+		// параллельный запрос с тем же ключом успел сохранить заказ первым
+		if uid, replayed, replayErr := s.replay(ctx, key, requestHash); replayed || replayErr != nil {
+			return uid, replayed, replayErr
+		}
+	}
+	if err != nil {
+		return order.OrderUID, false, err
+	}
+
+	return order.OrderUID, false, nil
+}
+
+func (s *OrderSubmitter) replay(ctx context.Context, key, requestHash string) (uuid.UUID, bool, error) {
+	record, err := s.keys.Get(ctx, key)
+	if errors.Is(err, ErrIdempotencyKeyNotFound) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	if record.RequestHash != requestHash {
+		return uuid.Nil, false, ErrIdempotencyKeyReused
+	}
+
+	return record.OrderUID, true, nil
+}
@@ -0,0 +1,128 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdempotencyRepo struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepo) Get(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	args := m.Called(ctx, key)
+	record, _ := args.Get(0).(*domain.IdempotencyKey)
+	return record, args.Error(1)
+}
+
+func newTestSubmitter() (*usecase.OrderSubmitter, *MockRepo, *MockIdempotencyRepo) {
+	repo := new(MockRepo)
+	keys := new(MockIdempotencyRepo)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cfg := config.NewConfig(logger)
+	service := usecase.NewOrderService(logger, cfg, repo, new(MockCache), nil, nil, nil)
+
+	return usecase.NewOrderSubmitter(logger, service, keys), repo, keys
+}
+
+func TestSubmit_WithoutKeySavesOrder(t *testing.T) {
+	ctx := context.Background()
+	submitter, repo, keys := newTestSubmitter()
+	order := &domain.Order{OrderUID: uuid.New()}
+
+	repo.On("Save", ctx, order).Return(nil)
+
+	uid, replayed, err := submitter.Submit(ctx, "", "hash", order)
+
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, order.OrderUID, uid)
+	keys.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestSubmit_NewKeySavesOrderAndKey(t *testing.T) {
+	ctx := context.Background()
+	submitter, repo, keys := newTestSubmitter()
+	order := &domain.Order{OrderUID: uuid.New()}
+
+	keys.On("Get", ctx, "key").Return(nil, usecase.ErrIdempotencyKeyNotFound)
+	repo.On("SaveWithIdempotencyKey", ctx, order, mock.MatchedBy(func(record *domain.IdempotencyKey) bool {
+		return record.Key == "key" && record.RequestHash == "hash" && record.OrderUID == order.OrderUID
+	})).Return(nil)
+
+	uid, replayed, err := submitter.Submit(ctx, "key", "hash", order)
+
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, order.OrderUID, uid)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestSubmit_ConcurrentSameKeyReplays(t *testing.T) {
+	ctx := context.Background()
+	submitter, repo, keys := newTestSubmitter()
+	order := &domain.Order{OrderUID: uuid.New()}
+	stored := uuid.New()
+
+	// ключа еще нет, но параллельный запрос вставил его раньше, чем закоммитился этот заказ
+	keys.On("Get", ctx, "key").Return(nil, usecase.ErrIdempotencyKeyNotFound).Once()
+	repo.On("SaveWithIdempotencyKey", ctx, order, mock.Anything).Return(usecase.ErrIdempotencyKeyExists)
+	keys.On("Get", ctx, "key").Return(&domain.IdempotencyKey{Key: "key", RequestHash: "hash", OrderUID: stored}, nil)
+
+	uid, replayed, err := submitter.Submit(ctx, "key", "hash", order)
+
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, stored, uid)
+}
+
+func TestSubmit_SameKeySamePayloadReplays(t *testing.T) {
+	ctx := context.Background()
+	submitter, repo, keys := newTestSubmitter()
+	stored := uuid.New()
+
+	keys.On("Get", ctx, "key").Return(&domain.IdempotencyKey{Key: "key", RequestHash: "hash", OrderUID: stored}, nil)
+
+	uid, replayed, err := submitter.Submit(ctx, "key", "hash", &domain.Order{OrderUID: stored})
+
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, stored, uid)
+	repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestSubmit_SameKeyDifferentPayload(t *testing.T) {
+	ctx := context.Background()
+	submitter, _, keys := newTestSubmitter()
+
+	keys.On("Get", ctx, "key").Return(&domain.IdempotencyKey{Key: "key", RequestHash: "other", OrderUID: uuid.New()}, nil)
+
+	_, _, err := submitter.Submit(ctx, "key", "hash", &domain.Order{OrderUID: uuid.New()})
+
+	assert.ErrorIs(t, err, usecase.ErrIdempotencyKeyReused)
+}
+
+func TestSubmit_SaveErrorIsReturned(t *testing.T) {
+	ctx := context.Background()
+	submitter, repo, keys := newTestSubmitter()
+	order := &domain.Order{OrderUID: uuid.New()}
+	saveErr := errors.New("db is down")
+
+	keys.On("Get", ctx, "key").Return(nil, usecase.ErrIdempotencyKeyNotFound)
+	repo.On("SaveWithIdempotencyKey", ctx, order, mock.Anything).Return(saveErr)
+
+	_, _, err := submitter.Submit(ctx, "key", "hash", order)
+
+	assert.ErrorIs(t, err, saveErr)
+}
@@ -1,52 +1,519 @@
 package usecase
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
 	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"net/mail"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var (
-	ErrOrderUIDIsEmpty         = errors.New("order_uid is empty")
-	ErrTrackNumberIsEmpty      = errors.New("track_number is empty")
-	ErrDeliveryInfoIncomplete  = errors.New("delivery info is incomplete")
-	ErrPaymentAmountInvalid    = errors.New("payment amount is invalid")
-	ErrItemsListIsEmpty        = errors.New("items list is empty")
-	ErrItemNmIdIsInvalid       = errors.New("nm_id is invalid")
-	ErrItemTotalPriceIsInvalid = errors.New("total_price is invalid")
-	ErrDateCreatedIsInvalid    = errors.New("date_created is invalid")
+// DefaultRuleSet - имя набора правил, который применяется, если ни один when не подошел
+const DefaultRuleSet = "default"
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
 )
 
-func ValidateOrder(order *mapper.OrderIntoDomainDTO) error {
-	if order.OrderUID == uuid.Nil {
-		return ErrOrderUIDIsEmpty
+// Rule - ограничения на одно поле заказа. Field - путь в JSON заказа, "[]" обходит все элементы
+// массива: items[].nm_id. Пустое необязательное поле (нет значения или "") остальными ограничениями не проверяется
+type Rule struct {
+	Field    string   `yaml:"field"`
+	Required bool     `yaml:"required"`
+	Min      *float64 `yaml:"min"`
+	Max      *float64 `yaml:"max"`
+	MinLen   *int     `yaml:"min_len"` // для строк - символы, для массивов - элементы
+	MaxLen   *int     `yaml:"max_len"`
+	Pattern  string   `yaml:"pattern"`
+	OneOf    []string `yaml:"one_of"`
+	Format   string   `yaml:"format"` // rfc3339, email, uuid или currency (код ISO 4217)
+	Severity Severity `yaml:"severity"`
+	Message  string   `yaml:"message"` // заменяет сообщение по умолчанию
+
+	pattern *regexp.Regexp
+}
+
+// RuleSetSelector выбирает набор правил по полям заказа, пустое поле не проверяется
+type RuleSetSelector struct {
+	Entry           string `yaml:"entry"`
+	DeliveryService string `yaml:"delivery_service"`
+}
+
+func (s RuleSetSelector) empty() bool {
+	return s.Entry == "" && s.DeliveryService == ""
+}
+
+func (s RuleSetSelector) match(order *mapper.OrderIntoDomainDTO) bool {
+	return !s.empty() &&
+		(s.Entry == "" || s.Entry == order.Entry) &&
+		(s.DeliveryService == "" || s.DeliveryService == order.DeliveryService)
+}
+
+// RuleSet - именованный набор правил. Extends подключает правила другого набора перед своими
+type RuleSet struct {
+	Name    string          `yaml:"name"`
+	When    RuleSetSelector `yaml:"when"`
+	Extends string          `yaml:"extends"`
+	Rules   []Rule          `yaml:"rules"`
+}
+
+// Violation - нарушение правила, Path - путь к значению с индексами массивов: items[2].nm_id
+type Violation struct {
+	Path     string   `json:"path"`
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// ValidationError - все нарушения уровня error
+type ValidationError struct {
+	RuleSet    string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Path + ": " + v.Message
+	}
+	return "order validation failed: " + strings.Join(parts, "; ")
+}
+
+// ValidationViolations возвращает нарушения из ошибки валидации, nil - ошибка другого рода
+func ValidationViolations(err error) []Violation {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Violations
+	}
+	return nil
+}
+
+var zeroUUID = uuid.Nil.String()
+
+// DefaultRuleSets - правила, которые действуют без файла правил
+func DefaultRuleSets() []RuleSet {
+	one, zero, hundred := 1.0, 0.0, 100.0
+	minItems := 1
+
+	return []RuleSet{{
+		Name: DefaultRuleSet,
+		Rules: []Rule{
+			{Field: "order_uid", Required: true, Format: "uuid"},
+			{Field: "track_number", Required: true},
+			{Field: "entry", Required: true, Severity: SeverityWarning},
+			{Field: "delivery.name", Required: true},
+			{Field: "delivery.city", Required: true},
+			{Field: "delivery.phone", Pattern: `^\+?[0-9]{10,15}$`, Severity: SeverityWarning},
+			{Field: "delivery.email", Format: "email", Severity: SeverityWarning},
+			{Field: "payment.amount", Min: &one},
+			{Field: "payment.currency", Required: true, Format: "currency"},
+			{Field: "payment.delivery_cost", Min: &zero},
+			{Field: "items", MinLen: &minItems},
+			{Field: "items[].nm_id", Min: &one},
+			{Field: "items[].total_price", Min: &one},
+			{Field: "items[].price", Min: &zero, Severity: SeverityWarning},
+			{Field: "items[].sale", Min: &zero, Max: &hundred, Severity: SeverityWarning},
+			{Field: "date_created", Required: true, Format: "rfc3339"},
+		},
+	}}
+}
+
+// OrderValidator проверяет заказы набором правил, выбранным по entry и delivery_service
+type OrderValidator struct {
+	sets       []RuleSet // наборы с when в порядке объявления
+	fallback   RuleSet
+	reconciler *OrderReconciler // nil - суммы не сверяются
+	decoder    *OrderDecoder
+}
+
+// NewOrderValidator проверяет правила и разворачивает extends. Набор default обязателен
+func NewOrderValidator(sets []RuleSet) (*OrderValidator, error) {
+	byName := make(map[string]RuleSet, len(sets))
+	for _, set := range sets {
+		if set.Name == "" {
+			return nil, errors.New("rule set without name")
+		}
+		if _, ok := byName[set.Name]; ok {
+			return nil, fmt.Errorf("duplicate rule set %q", set.Name)
+		}
+		byName[set.Name] = set
+	}
+
+	// сообщения без версии приходят от продюсеров, написанных до версионирования
+	decoder, err := NewOrderDecoder(false, OrderSchemaV1)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &OrderValidator{decoder: decoder}
+	for _, set := range sets {
+		rules, err := resolveRules(byName, set.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		set.Rules = rules
+
+		switch {
+		case set.Name == DefaultRuleSet:
+			v.fallback = set
+		case set.When.empty():
+			return nil, fmt.Errorf("rule set %q: when is required", set.Name)
+		default:
+			v.sets = append(v.sets, set)
+		}
 	}
-	if order.TrackNumber == "" {
-		return ErrTrackNumberIsEmpty
+
+	if v.fallback.Name == "" {
+		return nil, fmt.Errorf("rule set %q is required", DefaultRuleSet)
+	}
+
+	return v, nil
+}
+
+// NewOrderValidatorFromConfig читает правила из VALIDATION_RULES_FILE, без файла действуют DefaultRuleSets.
+// Сверка сумм настраивается VALIDATION_RECONCILE_MODE и VALIDATION_RECONCILE_TOLERANCE,
+// строгая проверка схемы и версия сообщений без версии - VALIDATION_SCHEMA_STRICT и VALIDATION_SCHEMA_DEFAULT_VERSION
+func NewOrderValidatorFromConfig(cfg config.Config) (*OrderValidator, error) {
+	sets, err := loadRuleSets(cfg.ValidationRulesFile)
+	if err != nil {
+		return nil, err
 	}
-	if order.Delivery.Name == "" || order.Delivery.City == "" {
-		return ErrDeliveryInfoIncomplete
+
+	v, err := NewOrderValidator(sets)
+	if err != nil {
+		return nil, err
 	}
-	if order.Payment.Amount <= 0 {
-		return ErrPaymentAmountInvalid
+
+	v.reconciler, err = NewOrderReconcilerFromConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(order.Items) == 0 {
-		return ErrItemsListIsEmpty
+	defaultVersion := cfg.ValidationSchemaDefaultVersion
+	if defaultVersion == 0 {
+		defaultVersion = OrderSchemaV1
+	}
+	if v.decoder, err = NewOrderDecoder(cfg.ValidationSchemaStrict, defaultVersion); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func loadRuleSets(path string) ([]RuleSet, error) {
+	if path == "" {
+		return DefaultRuleSets(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read validation rules: %w", err)
+	}
+
+	var file struct {
+		RuleSets []RuleSet `yaml:"rule_sets"`
+	}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse validation rules: %w", err)
 	}
-	for _, item := range order.Items {
-		if item.NmID <= 0 {
-			return ErrItemNmIdIsInvalid
+
+	return file.RuleSets, nil
+}
+
+func resolveRules(byName map[string]RuleSet, name string, chain []string) ([]Rule, error) {
+	if slices.Contains(chain, name) {
+		return nil, fmt.Errorf("rule set %q: cyclic extends", name)
+	}
+	set, ok := byName[name]
+	if !ok {
+		return nil, fmt.Errorf("rule set %q not found", name)
+	}
+
+	var rules []Rule
+	if set.Extends != "" {
+		parent, err := resolveRules(byName, set.Extends, append(chain, name))
+		if err != nil {
+			return nil, err
 		}
-		if item.TotalPrice <= 0 {
-			return ErrItemTotalPriceIsInvalid
+		rules = append(rules, parent...)
+	}
+
+	for _, rule := range set.Rules {
+		if err := compileRule(&rule); err != nil {
+			return nil, fmt.Errorf("rule set %q, field %q: %w", name, rule.Field, err)
 		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func compileRule(rule *Rule) error {
+	if rule.Field == "" {
+		return errors.New("field is required")
+	}
+
+	switch rule.Severity {
+	case "":
+		rule.Severity = SeverityError
+	case SeverityError, SeverityWarning:
+	default:
+		return fmt.Errorf("unknown severity %q", rule.Severity)
 	}
 
-	if _, err := time.Parse(time.RFC3339, order.DateCreated); err != nil {
-		return ErrDateCreatedIsInvalid
+	switch rule.Format {
+	case "", "rfc3339", "email", "uuid", "currency":
+	default:
+		return fmt.Errorf("unknown format %q", rule.Format)
+	}
+
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return err
+		}
+		rule.pattern = pattern
 	}
 
 	return nil
 }
+
+// Decode разбирает JSON заказа с проверкой по JSON Schema его версии, см. OrderDecoder.Decode
+func (v *OrderValidator) Decode(data []byte, version int) (*mapper.OrderIntoDomainDTO, error) {
+	return v.decoder.Decode(data, version)
+}
+
+// Schema - JSON Schema версии, по которой проверяются входящие заказы
+func (v *OrderValidator) Schema(version int) ([]byte, error) {
+	return v.decoder.Schema(version)
+}
+
+// RuleSetFor - имя набора правил, которым будет проверен заказ
+func (v *OrderValidator) RuleSetFor(order *mapper.OrderIntoDomainDTO) string {
+	return v.ruleSet(order).Name
+}
+
+func (v *OrderValidator) ruleSet(order *mapper.OrderIntoDomainDTO) RuleSet {
+	for _, set := range v.sets {
+		if set.When.match(order) {
+			return set
+		}
+	}
+	return v.fallback
+}
+
+// Validate проверяет заказ всеми правилами набора. Нарушения уровня error возвращаются
+// как *ValidationError, предупреждения - отдельно и заказ не отклоняют.
+// Суммы сверяются только у прошедшего правила заказа, расхождение в режиме strict
+// возвращается как *ValidationError, обернутая в ErrOrderQuarantined
+func (v *OrderValidator) Validate(order *mapper.OrderIntoDomainDTO) (warnings []Violation, err error) {
+	set := v.ruleSet(order)
+
+	// код валюты принимается в любом регистре, к верхнему его приводит маппер
+	_, currencyErr := domain.ParseCurrency(order.Payment.Currency)
+
+	// правила адресуют поля по JSON-путям, поэтому проверяется JSON-представление заказа
+	raw, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	var violations []Violation
+	for _, rule := range set.Rules {
+		for _, field := range lookup(doc, rule.Field) {
+			violations = append(violations, rule.check(field)...)
+		}
+	}
+
+	var errs []Violation
+	currencyReported := false
+	for _, violation := range violations {
+		if violation.Severity == SeverityWarning {
+			warnings = append(warnings, violation)
+		} else {
+			errs = append(errs, violation)
+			currencyReported = currencyReported || violation.Path == currencyPath
+		}
+	}
+
+	// без валюты суммы не разобрать, поэтому она проверяется, даже если в наборе нет такого правила
+	if currencyErr != nil && !currencyReported {
+		check, message := "format", "must be a valid currency"
+		if order.Payment.Currency == "" {
+			check, message = "required", "is required"
+		}
+		errs = append(errs, Violation{Path: currencyPath, Check: check, Severity: SeverityError, Message: message})
+	}
+
+	if len(errs) > 0 {
+		return warnings, &ValidationError{RuleSet: set.Name, Violations: errs}
+	}
+
+	for _, violation := range v.reconciler.Reconcile(order) {
+		if violation.Severity == SeverityWarning {
+			warnings = append(warnings, violation)
+		} else {
+			errs = append(errs, violation)
+		}
+	}
+	if len(errs) > 0 {
+		return warnings, fmt.Errorf("%w: %w", ErrOrderQuarantined, &ValidationError{RuleSet: set.Name, Violations: errs})
+	}
+
+	return warnings, nil
+}
+
+const currencyPath = "payment.currency"
+
+type fieldValue struct {
+	path  string
+	value any
+}
+
+// lookup находит значения по пути, "[]" в сегменте разворачивает массив
+func lookup(doc any, path string) []fieldValue {
+	values := []fieldValue{{value: doc}}
+
+	for _, segment := range strings.Split(path, ".") {
+		key, each := strings.CutSuffix(segment, "[]")
+
+		var next []fieldValue
+		for _, current := range values {
+			object, _ := current.value.(map[string]any)
+			value := object[key]
+			valuePath := joinPath(current.path, key)
+
+			if !each {
+				next = append(next, fieldValue{path: valuePath, value: value})
+				continue
+			}
+			array, _ := value.([]any)
+			for i, element := range array {
+				next = append(next, fieldValue{path: valuePath + "[" + strconv.Itoa(i) + "]", value: element})
+			}
+		}
+		values = next
+	}
+
+	return values
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func (rule Rule) check(field fieldValue) []Violation {
+	var violations []Violation
+	fail := func(check, message string) {
+		if rule.Message != "" {
+			message = rule.Message
+		}
+		violations = append(violations, Violation{Path: field.path, Check: check, Severity: rule.Severity, Message: message})
+	}
+
+	if rule.Required && isZero(field.value) {
+		fail("required", "is required")
+		return violations
+	}
+
+	switch value := field.value.(type) {
+	case float64:
+		if rule.Min != nil && value < *rule.Min {
+			fail("min", "must be >= "+formatNumber(*rule.Min))
+		}
+		if rule.Max != nil && value > *rule.Max {
+			fail("max", "must be <= "+formatNumber(*rule.Max))
+		}
+	case string:
+		// пустая необязательная строка остальными ограничениями не проверяется
+		if value == "" {
+			break
+		}
+		length := len([]rune(value))
+		if rule.MinLen != nil && length < *rule.MinLen {
+			fail("min_len", "must be at least "+strconv.Itoa(*rule.MinLen)+" characters")
+		}
+		if rule.MaxLen != nil && length > *rule.MaxLen {
+			fail("max_len", "must be at most "+strconv.Itoa(*rule.MaxLen)+" characters")
+		}
+		if rule.pattern != nil && !rule.pattern.MatchString(value) {
+			fail("pattern", "must match "+rule.Pattern)
+		}
+		if len(rule.OneOf) > 0 && !slices.Contains(rule.OneOf, value) {
+			fail("one_of", "must be one of "+strings.Join(rule.OneOf, ", "))
+		}
+		if rule.Format != "" && !validFormat(rule.Format, value) {
+			fail("format", "must be a valid "+rule.Format)
+		}
+	case nil:
+		// nil-срез сериализуется в null, для min_len это пустой список
+		if rule.MinLen != nil && *rule.MinLen > 0 {
+			fail("min_len", "must contain at least "+strconv.Itoa(*rule.MinLen)+" items")
+		}
+	case []any:
+		if rule.MinLen != nil && len(value) < *rule.MinLen {
+			fail("min_len", "must contain at least "+strconv.Itoa(*rule.MinLen)+" items")
+		}
+		if rule.MaxLen != nil && len(value) > *rule.MaxLen {
+			fail("max_len", "must contain at most "+strconv.Itoa(*rule.MaxLen)+" items")
+		}
+	}
+
+	return violations
+}
+
+func isZero(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == "" || v == zeroUUID
+	case float64:
+		return v == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func validFormat(format, value string) bool {
+	switch format {
+	case "rfc3339":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "uuid":
+		_, err := uuid.Parse(value)
+		return err == nil
+	case "currency":
+		_, err := domain.ParseCurrency(value)
+		return err == nil
+	}
+	return true
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
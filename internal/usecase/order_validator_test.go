@@ -1,114 +1,222 @@
 package usecase_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
 	"github.com/folivorra/get_order/internal/usecase"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestValidateOrder_ValidOrder(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
+func validOrder() *mapper.OrderIntoDomainDTO {
+	return &mapper.OrderIntoDomainDTO{
 		OrderUID:    uuid.New(),
 		TrackNumber: "TRACK123",
+		Entry:       "WBIL",
 		Delivery: mapper.DeliveryIntoDomainDTO{
-			Name: "Test User",
-			City: "Test City",
+			Name:  "Test User",
+			City:  "Test City",
+			Phone: "+79720000000",
+			Email: "test@gmail.com",
 		},
 		Payment: mapper.PaymentIntoDomainDTO{
-			Amount: 100,
+			Amount:    100,
+			Currency:  "RUB",
+			PaymentDT: mapper.NewTimestamp(time.Now()),
 		},
 		Items: []mapper.ItemIntoDomainDTO{
 			{
 				NmID:       1,
+				Price:      100,
 				TotalPrice: 100,
 			},
 		},
-		DateCreated: time.Now().Format(time.RFC3339),
+		DeliveryService: "meest",
+		DateCreated:     mapper.NewTimestamp(time.Now()),
+	}
+}
+
+func defaultValidator(t *testing.T) *usecase.OrderValidator {
+	t.Helper()
+	validator, err := usecase.NewOrderValidator(usecase.DefaultRuleSets())
+	require.NoError(t, err)
+	return validator
+}
+
+func violationPaths(violations []usecase.Violation) []string {
+	paths := make([]string, len(violations))
+	for i, v := range violations {
+		paths[i] = v.Path
 	}
+	return paths
+}
 
-	err := usecase.ValidateOrder(order)
+func TestValidateOrder_ValidOrder(t *testing.T) {
+	warnings, err := defaultValidator(t).Validate(validOrder())
 	assert.NoError(t, err)
+	assert.Empty(t, warnings)
 }
 
-func TestValidateOrder_OrderUIDEmpty(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrOrderUIDIsEmpty, err)
+func TestValidateOrder_CollectsAllViolations(t *testing.T) {
+	order := validOrder()
+	order.OrderUID = uuid.Nil
+	order.TrackNumber = ""
+	order.Delivery = mapper.DeliveryIntoDomainDTO{}
+	order.Payment.Amount = 0
+	order.Items = []mapper.ItemIntoDomainDTO{{NmID: 1, TotalPrice: 10}, {NmID: 0, TotalPrice: 0}}
+	order.DateCreated = mapper.Timestamp{}
+
+	_, err := defaultValidator(t).Validate(order)
+	require.Error(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"order_uid",
+		"track_number",
+		"delivery.name",
+		"delivery.city",
+		"payment.amount",
+		"items[1].nm_id",
+		"items[1].total_price",
+		"date_created",
+	}, violationPaths(usecase.ValidationViolations(err)))
 }
 
-func TestValidateOrder_TrackNumberEmpty(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
-		OrderUID: uuid.New(),
-	}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrTrackNumberIsEmpty, err)
+func TestValidateOrder_ItemsEmpty(t *testing.T) {
+	order := validOrder()
+	order.Items = nil
+
+	_, err := defaultValidator(t).Validate(order)
+	violations := usecase.ValidationViolations(err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "items", violations[0].Path)
+	assert.Equal(t, "min_len", violations[0].Check)
 }
 
-func TestValidateOrder_DeliveryIncomplete(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
-		OrderUID:    uuid.New(),
-		TrackNumber: "TRACK123",
-		Delivery:    mapper.DeliveryIntoDomainDTO{},
-		Payment:     mapper.PaymentIntoDomainDTO{Amount: 10},
-		Items:       []mapper.ItemIntoDomainDTO{{NmID: 1, TotalPrice: 10}},
-		DateCreated: time.Now().Format(time.RFC3339),
+func TestValidateOrder_WarningsDoNotReject(t *testing.T) {
+	order := validOrder()
+	order.Delivery.Email = "not-an-email"
+	order.Items[0].Sale = 150
+
+	warnings, err := defaultValidator(t).Validate(order)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"delivery.email", "items[0].sale"}, violationPaths(warnings))
+	for _, w := range warnings {
+		assert.Equal(t, usecase.SeverityWarning, w.Severity)
 	}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrDeliveryInfoIncomplete, err)
 }
 
-func TestValidateOrder_PaymentInvalid(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
-		OrderUID:    uuid.New(),
-		TrackNumber: "TRACK123",
-		Delivery:    mapper.DeliveryIntoDomainDTO{Name: "A", City: "B"},
-		Payment:     mapper.PaymentIntoDomainDTO{Amount: 0},
-		Items:       []mapper.ItemIntoDomainDTO{{NmID: 1, TotalPrice: 10}},
-		DateCreated: time.Now().Format(time.RFC3339),
-	}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrPaymentAmountInvalid, err)
+const rulesYAML = `
+rule_sets:
+  - name: default
+    rules:
+      - field: order_uid
+        required: true
+      - field: items[].total_price
+        min: 1
+  - name: express
+    when:
+      delivery_service: express
+    extends: default
+    rules:
+      - field: delivery.phone
+        required: true
+        message: express delivery needs a phone
+      - field: items
+        max_len: 1
+`
+
+func TestOrderValidatorFromConfig_SwitchesRuleSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(rulesYAML), 0o600))
+
+	validator, err := usecase.NewOrderValidatorFromConfig(config.Config{ValidationRulesFile: path})
+	require.NoError(t, err)
+
+	order := validOrder()
+	order.Delivery.Phone = ""
+	order.Items = append(order.Items, mapper.ItemIntoDomainDTO{TotalPrice: 0})
+
+	// у meest действует default: телефон не проверяется
+	assert.Equal(t, "default", validator.RuleSetFor(order))
+	_, err = validator.Validate(order)
+	assert.Equal(t, []string{"items[1].total_price"}, violationPaths(usecase.ValidationViolations(err)))
+
+	order.DeliveryService = "express"
+	assert.Equal(t, "express", validator.RuleSetFor(order))
+	_, err = validator.Validate(order)
+	violations := usecase.ValidationViolations(err)
+	assert.Equal(t, []string{"items[1].total_price", "delivery.phone", "items"}, violationPaths(violations))
+	assert.Equal(t, "express delivery needs a phone", violations[1].Message)
 }
 
-func TestValidateOrder_ItemsInvalid(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
-		OrderUID:    uuid.New(),
-		TrackNumber: "TRACK123",
-		Delivery:    mapper.DeliveryIntoDomainDTO{Name: "A", City: "B"},
-		Payment:     mapper.PaymentIntoDomainDTO{Amount: 10},
-		Items:       []mapper.ItemIntoDomainDTO{},
-		DateCreated: time.Now().Format(time.RFC3339),
-	}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrItemsListIsEmpty, err)
+func TestNewOrderValidator_InvalidRuleSets(t *testing.T) {
+	_, err := usecase.NewOrderValidator([]usecase.RuleSet{{Name: "custom", When: usecase.RuleSetSelector{Entry: "WBIL"}}})
+	assert.Error(t, err, "default rule set is required")
+
+	_, err = usecase.NewOrderValidator([]usecase.RuleSet{
+		{Name: "default", Extends: "strict"},
+		{Name: "strict", Extends: "default", When: usecase.RuleSetSelector{Entry: "WBIL"}},
+	})
+	assert.Error(t, err, "cyclic extends")
+
+	_, err = usecase.NewOrderValidator([]usecase.RuleSet{{Name: "default", Rules: []usecase.Rule{{Field: "track_number", Pattern: "("}}}})
+	assert.Error(t, err, "invalid pattern")
 }
 
-func TestValidateOrder_ItemFieldsInvalid(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
-		OrderUID:    uuid.New(),
-		TrackNumber: "TRACK123",
-		Delivery:    mapper.DeliveryIntoDomainDTO{Name: "A", City: "B"},
-		Payment:     mapper.PaymentIntoDomainDTO{Amount: 10},
-		Items:       []mapper.ItemIntoDomainDTO{{NmID: 0, TotalPrice: 0}},
-		DateCreated: time.Now().Format(time.RFC3339),
-	}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrItemNmIdIsInvalid, err)
+func TestOrderValidatorFromConfig_SampleRules(t *testing.T) {
+	validator, err := usecase.NewOrderValidatorFromConfig(config.Config{ValidationRulesFile: "../../rules/order_validation.yaml"})
+	require.NoError(t, err)
+
+	_, err = validator.Validate(validOrder())
+	assert.NoError(t, err)
+
+	order := validOrder()
+	order.DeliveryService = "courier"
+	_, err = validator.Validate(order)
+	assert.Equal(t, []string{"delivery.address"}, violationPaths(usecase.ValidationViolations(err)))
 }
 
-func TestValidateOrder_DateCreatedInvalid(t *testing.T) {
-	order := &mapper.OrderIntoDomainDTO{
-		OrderUID:    uuid.New(),
-		TrackNumber: "TRACK123",
-		Delivery:    mapper.DeliveryIntoDomainDTO{Name: "A", City: "B"},
-		Payment:     mapper.PaymentIntoDomainDTO{Amount: 10},
-		Items:       []mapper.ItemIntoDomainDTO{{NmID: 1, TotalPrice: 10}},
-		DateCreated: "invalid-date",
-	}
-	err := usecase.ValidateOrder(order)
-	assert.Equal(t, usecase.ErrDateCreatedIsInvalid, err)
+func TestValidateOrder_UnknownCurrency(t *testing.T) {
+	order := validOrder()
+	order.Payment.Currency = "ABC"
+
+	_, err := defaultValidator(t).Validate(order)
+	require.Error(t, err)
+
+	violations := usecase.ValidationViolations(err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "payment.currency", violations[0].Path)
+	assert.Equal(t, "format", violations[0].Check)
+}
+
+func TestValidateOrder_AcceptsCurrencyInAnyCase(t *testing.T) {
+	order := validOrder()
+	order.Payment.Currency = " rub"
+
+	_, err := defaultValidator(t).Validate(order)
+	require.NoError(t, err)
+	// валидатор заказ не меняет, код нормализует маппер
+	assert.Equal(t, " rub", order.Payment.Currency)
+	assert.Equal(t, domain.Currency("RUB"), mapper.ConvertToDomain(order).Payment.Currency)
+}
+
+func TestValidateOrder_CurrencyCheckedWithoutRule(t *testing.T) {
+	validator, err := usecase.NewOrderValidator([]usecase.RuleSet{{Name: usecase.DefaultRuleSet}})
+	require.NoError(t, err)
+
+	order := validOrder()
+	order.Payment.Currency = "rubles"
+
+	_, err = validator.Validate(order)
+	violations := usecase.ValidationViolations(err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "payment.currency", violations[0].Path)
+	assert.Equal(t, "format", violations[0].Check)
 }
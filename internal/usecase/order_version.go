@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	orderv1 "github.com/folivorra/get_order/api/order/v1"
+	orderv2 "github.com/folivorra/get_order/api/order/v2"
+	"strconv"
+	"strings"
+)
+
+// версии формата сообщения о заказе
+const (
+	// OrderSchemaV1 - исходный формат, quantity позиции необязательно
+	OrderSchemaV1 = 1
+	// OrderSchemaV2 - quantity позиции обязательно и не меньше 1
+	OrderSchemaV2 = 2
+	// CurrentOrderSchema - версия, до которой поднимается любое сообщение перед разбором в DTO
+	CurrentOrderSchema = OrderSchemaV2
+	// ProtoOrderSchema - версия order.v1.Order из api/order/v1/order.proto в gRPC и Kafka
+	ProtoOrderSchema = OrderSchemaV1
+)
+
+// поля конверта {"schema_version": 2, "order": {...}}
+const (
+	envelopeVersionKey = "schema_version"
+	envelopeOrderKey   = "order"
+)
+
+// Upcaster поднимает заказ, прошедший схему своей версии, на одну версию вверх. Документ меняется на месте,
+// числа в нем - json.Number
+type Upcaster func(order map[string]any) error
+
+// orderSchemas - реестр версий: схема и апкастер в следующую версию, у текущей версии апкастера нет.
+// Новая версия - схема в api/order/vN, апкастер из предыдущей и новая CurrentOrderSchema
+var orderSchemas = []struct {
+	version int
+	schema  []byte
+	upcast  Upcaster
+}{
+	{version: OrderSchemaV1, schema: orderv1.OrderJSONSchema, upcast: upcastOrderV1},
+	{version: OrderSchemaV2, schema: orderv2.OrderJSONSchema},
+}
+
+// ParseOrderVersion разбирает версию из заголовка: "2" или "v2", пустая строка - 0 (не указана)
+func ParseOrderVersion(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(value), "v"))
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrUnsupportedOrderVersion, value)
+	}
+
+	return version, nil
+}
+
+// upcastOrderV1 - в v1 отсутствующее или нулевое quantity означало одну штуку, в v2 оно указывается явно
+func upcastOrderV1(order map[string]any) error {
+	items, _ := order["items"].([]any)
+	for _, raw := range items {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("item is %T, want object", raw)
+		}
+		if quantity, ok := item["quantity"].(json.Number); !ok || quantity.String() == "0" {
+			item["quantity"] = json.Number("1")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"os"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseOrderVersion(t *testing.T) {
+	for value, want := range map[string]int{"": 0, "1": 1, "v2": 2, " V2 ": 2} {
+		version, err := usecase.ParseOrderVersion(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, want, version, value)
+	}
+
+	for _, value := range []string{"two", "v", "0", "-1"} {
+		_, err := usecase.ParseOrderVersion(value)
+		assert.ErrorIs(t, err, usecase.ErrUnsupportedOrderVersion, value)
+	}
+}
+
+// старые продюсеры не знают о версиях: сообщение без заголовка и конверта - v1
+func TestDecodeOrder_V1FixtureStillIngests(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepo)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	validator, err := usecase.NewOrderValidatorFromConfig(config.Config{ValidationReconcileMode: "strict"})
+	require.NoError(t, err)
+	service := usecase.NewOrderService(logger, config.NewConfig(logger), repo, new(MockCache), nil, validator, nil)
+
+	orderDTO, err := service.DecodeOrder(readFixture(t, "order_v1.json"), 0)
+	require.NoError(t, err)
+	require.Len(t, orderDTO.Items, 1)
+	assert.Equal(t, 1, orderDTO.Items[0].Quantity)
+
+	require.NoError(t, service.ValidateOrder(ctx, orderDTO))
+
+	repo.On("Save", ctx, mock.MatchedBy(func(order *domain.Order) bool {
+		return order.OrderUID == orderDTO.OrderUID && order.Items[0].Quantity == 1
+	})).Return(nil)
+	require.NoError(t, service.ProcessIncomingOrder(ctx, mapper.ConvertToDomain(orderDTO)))
+	repo.AssertExpectations(t)
+}
+
+func TestDecodeOrder_VersionSources(t *testing.T) {
+	decoder := newDecoder(t, false)
+	v1 := readFixture(t, "order_v1.json")
+	v2 := readFixture(t, "order_v2.json")
+
+	// версия из заголовка
+	orderDTO, err := decoder.Decode(v1, usecase.OrderSchemaV1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, orderDTO.Items[0].Quantity)
+
+	// версия из конверта, quantity не трогается
+	orderDTO, err = decoder.Decode(v2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, orderDTO.Items[0].Quantity)
+	assert.Equal(t, 634, orderDTO.Items[0].TotalPrice)
+
+	// заголовок совпадает с конвертом
+	_, err = decoder.Decode(v2, usecase.OrderSchemaV2)
+	assert.NoError(t, err)
+
+	_, err = decoder.Decode(v2, usecase.OrderSchemaV1)
+	assert.ErrorIs(t, err, usecase.ErrUnsupportedOrderVersion)
+
+	_, err = decoder.Decode(v1, 99)
+	assert.ErrorIs(t, err, usecase.ErrUnsupportedOrderVersion)
+
+	_, err = decoder.Decode([]byte(`{"schema_version": 2}`), 0)
+	assert.ErrorIs(t, err, usecase.ErrInvalidOrderJSON)
+}
+
+func TestDecodeOrder_V1PayloadFailsV2Schema(t *testing.T) {
+	decoder := newDecoder(t, false)
+
+	_, err := decoder.Decode(readFixture(t, "order_v1.json"), usecase.OrderSchemaV2)
+	violations := usecase.ValidationViolations(err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "items[0].quantity", violations[0].Path)
+	assert.Equal(t, "required", violations[0].Check)
+}
+
+func TestDecodeOrder_UpcastsZeroQuantity(t *testing.T) {
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(readFixture(t, "order_v1.json"), &doc))
+	first := doc["items"].([]any)[0].(map[string]any)
+	second := maps.Clone(first)
+	first["quantity"] = 0
+	second["quantity"] = 3
+	doc["items"] = []any{first, second}
+
+	orderDTO, err := newDecoder(t, false).Decode(marshalDocument(t, doc), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, orderDTO.Items[0].Quantity)
+	assert.Equal(t, 3, orderDTO.Items[1].Quantity)
+}
+
+func TestOrderSchema_UnknownVersion(t *testing.T) {
+	_, err := newDecoder(t, false).Schema(3)
+	assert.ErrorIs(t, err, usecase.ErrUnsupportedOrderVersion)
+
+	_, err = usecase.NewOrderDecoder(false, 3)
+	assert.ErrorIs(t, err, usecase.ErrUnsupportedOrderVersion)
+}
@@ -0,0 +1,214 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/adapter/mapper"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// причины карантина
+const (
+	QuarantineReasonInvalidJSON    = "invalid_json"
+	QuarantineReasonVersion        = "unsupported_version"
+	QuarantineReasonEncoding       = "encoding_failed"
+	QuarantineReasonSchema         = "schema_failed"
+	QuarantineReasonValidation     = "validation_failed"
+	QuarantineReasonReconciliation = "reconciliation_failed"
+)
+
+var (
+	ErrQuarantineNotFound = errors.New("quarantined order not found")
+	ErrQuarantineResolved = errors.New("quarantined order already resolved")
+	ErrQuarantinePayload  = errors.New("quarantined payload is not a valid order")
+)
+
+type QuarantineRepo interface {
+	Save(ctx context.Context, entry *domain.QuarantinedOrder) (err error)
+	Get(ctx context.Context, id uuid.UUID) (entry *domain.QuarantinedOrder, err error)
+	List(ctx context.Context, page domain.QuarantinePage) (entries []*domain.QuarantinedOrder, err error)
+	// Resolve записывает решение по записи в статусе pending, иначе ErrQuarantineResolved
+	Resolve(ctx context.Context, entry *domain.QuarantinedOrder) (err error)
+}
+
+// QuarantineReport - отчет о причине карантина, хранится в QuarantinedOrder.Report
+type QuarantineReport struct {
+	RuleSet    string      `json:"rule_set,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// QuarantineSource - откуда пришло сообщение
+type QuarantineSource struct {
+	Source    string
+	Topic     string
+	Partition int
+	Offset    int64
+	// SchemaVersion - версия формата из заголовка сообщения, 0 - не указана
+	SchemaVersion int
+}
+
+// QuarantineService сохраняет не прошедшие проверки заказы и ведет их ручной разбор
+type QuarantineService struct {
+	logger  *slog.Logger
+	repo    QuarantineRepo
+	service *OrderService
+}
+
+func NewQuarantineService(logger *slog.Logger, repo QuarantineRepo, service *OrderService) *QuarantineService {
+	return &QuarantineService{
+		logger:  logger,
+		repo:    repo,
+		service: service,
+	}
+}
+
+// Quarantine сохраняет сообщение с отчетом. cause - ошибка DecodeOrder или ValidateOrder
+func (q *QuarantineService) Quarantine(ctx context.Context, source QuarantineSource, orderUID uuid.UUID, payload []byte, cause error) (*domain.QuarantinedOrder, error) {
+	reason := QuarantineReasonInvalidJSON
+	switch {
+	case errors.Is(cause, ErrUnsupportedOrderVersion):
+		reason = QuarantineReasonVersion
+	case errors.Is(cause, ErrOrderEncoding):
+		reason = QuarantineReasonEncoding
+	}
+	report := QuarantineReport{Error: cause.Error()}
+
+	var validationErr *ValidationError
+	if errors.As(cause, &validationErr) {
+		switch {
+		case errors.Is(cause, ErrOrderQuarantined):
+			reason = QuarantineReasonReconciliation
+		case validationErr.RuleSet == SchemaRuleSet:
+			reason = QuarantineReasonSchema
+		default:
+			reason = QuarantineReasonValidation
+		}
+		report = QuarantineReport{RuleSet: validationErr.RuleSet, Violations: validationErr.Violations}
+	}
+
+	rawReport, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &domain.QuarantinedOrder{
+		ID:            uuid.New(),
+		OrderUID:      orderUID,
+		Source:        source.Source,
+		Topic:         source.Topic,
+		Partition:     source.Partition,
+		Offset:        source.Offset,
+		SchemaVersion: source.SchemaVersion,
+		Payload:       payload,
+		Reason:        reason,
+		Report:        rawReport,
+		Status:        domain.QuarantinePending,
+	}
+
+	if err = q.repo.Save(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (q *QuarantineService) Get(ctx context.Context, id uuid.UUID) (*domain.QuarantinedOrder, error) {
+	return q.repo.Get(ctx, id)
+}
+
+func (q *QuarantineService) List(ctx context.Context, page domain.QuarantinePage) ([]*domain.QuarantinedOrder, error) {
+	return q.repo.List(ctx, page)
+}
+
+// Approve повторно проверяет заказ (схема, правила, суммы) и сохраняет его как пришедший из Kafka.
+// payload - исправленный заказ, nil - заказ без правок. Оба разбираются в версии исходного сообщения,
+// исправленный заказ может указать другую версию конвертом. Если проверка не прошла,
+// запись остается в карантине без изменений
+func (q *QuarantineService) Approve(ctx context.Context, id uuid.UUID, payload []byte, reviewer, note string) (uuid.UUID, error) {
+	entry, err := q.pending(ctx, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if payload == nil {
+		payload = entry.Payload
+	}
+
+	orderDTO, err := q.service.DecodeOrder(payload, entry.SchemaVersion)
+	if errors.Is(err, ErrInvalidOrderJSON) {
+		return uuid.Nil, fmt.Errorf("%w: %w", ErrQuarantinePayload, err)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = q.service.ValidateOrder(ctx, orderDTO); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = q.service.ProcessIncomingOrder(ctx, mapper.ConvertToDomain(orderDTO)); err != nil {
+		return uuid.Nil, err
+	}
+
+	var compacted bytes.Buffer
+	if json.Compact(&compacted, payload) == nil {
+		payload = compacted.Bytes()
+	}
+
+	entry.OrderUID = orderDTO.OrderUID
+	entry.Payload = payload
+	if err = q.resolve(ctx, entry, domain.QuarantineApproved, reviewer, note); err != nil {
+		// заказ уже сохранен: повторное одобрение вернет ErrOrderAlreadyExists, запись нужно отклонить
+		return uuid.Nil, err
+	}
+
+	return orderDTO.OrderUID, nil
+}
+
+// Reject закрывает запись без сохранения заказа
+func (q *QuarantineService) Reject(ctx context.Context, id uuid.UUID, reviewer, note string) error {
+	entry, err := q.pending(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return q.resolve(ctx, entry, domain.QuarantineRejected, reviewer, note)
+}
+
+func (q *QuarantineService) pending(ctx context.Context, id uuid.UUID) (*domain.QuarantinedOrder, error) {
+	entry, err := q.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != domain.QuarantinePending {
+		return nil, ErrQuarantineResolved
+	}
+	return entry, nil
+}
+
+func (q *QuarantineService) resolve(ctx context.Context, entry *domain.QuarantinedOrder, status domain.QuarantineStatus, reviewer, note string) error {
+	entry.Status = status
+	entry.ReviewedBy = reviewer
+	entry.ReviewNote = note
+	entry.ReviewedAt = time.Now()
+
+	if err := q.repo.Resolve(ctx, entry); err != nil {
+		return err
+	}
+
+	q.logger.InfoContext(ctx, "quarantined order resolved",
+		slog.String("id", entry.ID.String()),
+		slog.String("uuid", entry.OrderUID.String()),
+		slog.String("status", string(status)),
+		slog.String("reviewer", reviewer),
+	)
+
+	return nil
+}
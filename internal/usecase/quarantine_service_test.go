@@ -0,0 +1,214 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockQuarantineRepo struct {
+	mock.Mock
+}
+
+func (m *MockQuarantineRepo) Save(ctx context.Context, entry *domain.QuarantinedOrder) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockQuarantineRepo) Get(ctx context.Context, id uuid.UUID) (*domain.QuarantinedOrder, error) {
+	args := m.Called(ctx, id)
+	entry, _ := args.Get(0).(*domain.QuarantinedOrder)
+	return entry, args.Error(1)
+}
+
+func (m *MockQuarantineRepo) List(ctx context.Context, page domain.QuarantinePage) ([]*domain.QuarantinedOrder, error) {
+	args := m.Called(ctx, page)
+	entries, _ := args.Get(0).([]*domain.QuarantinedOrder)
+	return entries, args.Error(1)
+}
+
+func (m *MockQuarantineRepo) Resolve(ctx context.Context, entry *domain.QuarantinedOrder) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func newTestQuarantine(t *testing.T) (*usecase.QuarantineService, *MockRepo, *MockQuarantineRepo) {
+	t.Helper()
+
+	repo := new(MockRepo)
+	quarantineRepo := new(MockQuarantineRepo)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cfg := config.NewConfig(logger)
+
+	validator, err := usecase.NewOrderValidatorFromConfig(config.Config{ValidationReconcileMode: "strict"})
+	require.NoError(t, err)
+	service := usecase.NewOrderService(logger, cfg, repo, new(MockCache), nil, validator, nil)
+
+	return usecase.NewQuarantineService(logger, quarantineRepo, service), repo, quarantineRepo
+}
+
+func pendingEntry(t *testing.T, payload any) *domain.QuarantinedOrder {
+	t.Helper()
+	raw, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return &domain.QuarantinedOrder{ID: uuid.New(), Payload: raw, Status: domain.QuarantinePending}
+}
+
+func TestQuarantine_BuildsReport(t *testing.T) {
+	ctx := context.Background()
+	quarantine, _, quarantineRepo := newTestQuarantine(t)
+	quarantineRepo.On("Save", ctx, mock.Anything).Return(nil)
+
+	source := usecase.QuarantineSource{Source: "kafka", Topic: "get_orders", Partition: 2, Offset: 42}
+
+	cause := json.Unmarshal([]byte("{"), &struct{}{})
+	entry, err := quarantine.Quarantine(ctx, source, uuid.Nil, []byte("{"), cause)
+	require.NoError(t, err)
+	assert.Equal(t, usecase.QuarantineReasonInvalidJSON, entry.Reason)
+	assert.Equal(t, domain.QuarantinePending, entry.Status)
+	assert.Equal(t, int64(42), entry.Offset)
+	assert.Contains(t, string(entry.Report), `"error":`)
+
+	versionSource := source
+	versionSource.SchemaVersion = 7
+	entry, err = quarantine.Quarantine(ctx, versionSource, uuid.Nil, []byte("{}"), fmt.Errorf("%w: 7", usecase.ErrUnsupportedOrderVersion))
+	require.NoError(t, err)
+	assert.Equal(t, usecase.QuarantineReasonVersion, entry.Reason)
+	assert.Equal(t, 7, entry.SchemaVersion)
+
+	entry, err = quarantine.Quarantine(ctx, source, uuid.Nil, []byte{0, 0, 0, 0, 1}, fmt.Errorf("%w: schema 1", usecase.ErrOrderEncoding))
+	require.NoError(t, err)
+	assert.Equal(t, usecase.QuarantineReasonEncoding, entry.Reason)
+
+	order := reconciledOrder()
+	order.Payment.Amount = 1
+	validator, err := usecase.NewOrderValidatorFromConfig(config.Config{ValidationReconcileMode: "strict"})
+	require.NoError(t, err)
+	_, validateErr := validator.Validate(order)
+
+	entry, err = quarantine.Quarantine(ctx, source, order.OrderUID, []byte("{}"), validateErr)
+	require.NoError(t, err)
+	assert.Equal(t, usecase.QuarantineReasonReconciliation, entry.Reason)
+
+	var report usecase.QuarantineReport
+	require.NoError(t, json.Unmarshal(entry.Report, &report))
+	assert.Equal(t, "default", report.RuleSet)
+	assert.Equal(t, []string{"payment.amount"}, violationPaths(report.Violations))
+}
+
+func TestApprove_EditedOrderIsValidatedAndSaved(t *testing.T) {
+	ctx := context.Background()
+	quarantine, repo, quarantineRepo := newTestQuarantine(t)
+
+	broken := reconciledOrder()
+	broken.Payment.Amount = 1
+	entry := pendingEntry(t, broken)
+
+	fixed := reconciledOrder()
+	fixed.OrderUID = broken.OrderUID
+	edited, err := json.MarshalIndent(fixed, "", "  ")
+	require.NoError(t, err)
+
+	quarantineRepo.On("Get", ctx, entry.ID).Return(entry, nil)
+	repo.On("Save", ctx, mock.MatchedBy(func(order *domain.Order) bool {
+		return order.OrderUID == fixed.OrderUID && order.Payment.Amount.Amount == int64(fixed.Payment.Amount)
+	})).Return(nil)
+	quarantineRepo.On("Resolve", ctx, mock.MatchedBy(func(e *domain.QuarantinedOrder) bool {
+		return e.Status == domain.QuarantineApproved && e.ReviewedBy == "alice" && e.ReviewNote == "fixed amount" &&
+			e.OrderUID == fixed.OrderUID && !e.ReviewedAt.IsZero() && json.Valid(e.Payload) && len(e.Payload) < len(edited)
+	})).Return(nil)
+
+	uid, err := quarantine.Approve(ctx, entry.ID, edited, "alice", "fixed amount")
+
+	assert.NoError(t, err)
+	assert.Equal(t, fixed.OrderUID, uid)
+	repo.AssertExpectations(t)
+	quarantineRepo.AssertExpectations(t)
+}
+
+func TestApprove_StillInvalidStaysInQuarantine(t *testing.T) {
+	ctx := context.Background()
+	quarantine, repo, quarantineRepo := newTestQuarantine(t)
+
+	broken := reconciledOrder()
+	broken.Payment.Amount = 1
+	entry := pendingEntry(t, broken)
+	quarantineRepo.On("Get", ctx, entry.ID).Return(entry, nil)
+
+	_, err := quarantine.Approve(ctx, entry.ID, nil, "alice", "")
+	assert.ErrorIs(t, err, usecase.ErrOrderQuarantined)
+	assert.Equal(t, []string{"payment.amount"}, violationPaths(usecase.ValidationViolations(err)))
+
+	_, err = quarantine.Approve(ctx, entry.ID, []byte(`{"order_uid": 1}`), "alice", "")
+	assert.Contains(t, violationPaths(usecase.ValidationViolations(err)), "order_uid")
+
+	_, err = quarantine.Approve(ctx, entry.ID, []byte(`{"order_uid":`), "alice", "")
+	assert.ErrorIs(t, err, usecase.ErrQuarantinePayload)
+
+	// исправленный заказ разбирается в версии исходного сообщения
+	entry.SchemaVersion = usecase.OrderSchemaV2
+	withoutQuantity := reconciledOrder()
+	withoutQuantity.Items[0].Quantity = 0
+	edited, err := json.Marshal(withoutQuantity)
+	require.NoError(t, err)
+	_, err = quarantine.Approve(ctx, entry.ID, edited, "alice", "")
+	assert.Contains(t, violationPaths(usecase.ValidationViolations(err)), "items[0].quantity")
+
+	repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	quarantineRepo.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+}
+
+func TestApprove_SaveErrorKeepsEntryPending(t *testing.T) {
+	ctx := context.Background()
+	quarantine, repo, quarantineRepo := newTestQuarantine(t)
+
+	entry := pendingEntry(t, reconciledOrder())
+	saveErr := errors.New("db is down")
+	quarantineRepo.On("Get", ctx, entry.ID).Return(entry, nil)
+	repo.On("Save", ctx, mock.Anything).Return(saveErr)
+
+	_, err := quarantine.Approve(ctx, entry.ID, nil, "alice", "")
+
+	assert.ErrorIs(t, err, saveErr)
+	quarantineRepo.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+}
+
+func TestReview_ResolvedEntry(t *testing.T) {
+	ctx := context.Background()
+	quarantine, _, quarantineRepo := newTestQuarantine(t)
+
+	entry := pendingEntry(t, reconciledOrder())
+	entry.Status = domain.QuarantineRejected
+	quarantineRepo.On("Get", ctx, entry.ID).Return(entry, nil)
+
+	_, err := quarantine.Approve(ctx, entry.ID, nil, "alice", "")
+	assert.ErrorIs(t, err, usecase.ErrQuarantineResolved)
+	assert.ErrorIs(t, quarantine.Reject(ctx, entry.ID, "alice", ""), usecase.ErrQuarantineResolved)
+}
+
+func TestReject(t *testing.T) {
+	ctx := context.Background()
+	quarantine, repo, quarantineRepo := newTestQuarantine(t)
+
+	entry := pendingEntry(t, reconciledOrder())
+	quarantineRepo.On("Get", ctx, entry.ID).Return(entry, nil)
+	quarantineRepo.On("Resolve", ctx, mock.MatchedBy(func(e *domain.QuarantinedOrder) bool {
+		return e.Status == domain.QuarantineRejected && e.ReviewNote == "test order"
+	})).Return(nil)
+
+	assert.NoError(t, quarantine.Reject(ctx, entry.ID, "alice", "test order"))
+	repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	quarantineRepo.AssertExpectations(t)
+}
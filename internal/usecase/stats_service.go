@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+var ErrStatsRange = errors.New("invalid stats range")
+
+type StatsRepo interface {
+	OrderStats(ctx context.Context, query domain.OrderStatsQuery) (rows []domain.OrderStatsRow, err error)
+	// OrderStatsRollup - то же по почасовым агрегатам, From и To выровнены по часу
+	OrderStatsRollup(ctx context.Context, query domain.OrderStatsQuery) (rows []domain.OrderStatsRow, err error)
+	// RefreshRollup пересчитывает агрегаты не больше limit часов с новыми заказами
+	RefreshRollup(ctx context.Context, limit int) (refreshed int, err error)
+}
+
+// OrderStatsReport - статистика с выровненными границами запроса, Rollup - посчитана по агрегатам
+type OrderStatsReport struct {
+	Query  domain.OrderStatsQuery
+	Rollup bool
+	Rows   []domain.OrderStatsRow
+}
+
+// StatsService - статистика заказов и выручки для менеджеров
+type StatsService struct {
+	logger    *slog.Logger
+	cfg       config.Config
+	repo      StatsRepo
+	converter *CurrencyConverter
+}
+
+// NewStatsService - converter nil: выручка не пересчитывается и строки всегда разделены по валютам
+func NewStatsService(logger *slog.Logger, cfg config.Config, repo StatsRepo, converter *CurrencyConverter) *StatsService {
+	return &StatsService{
+		logger:    logger,
+		cfg:       cfg,
+		repo:      repo,
+		converter: converter,
+	}
+}
+
+// OrderStats выравнивает границы по интервалам (пустой To - сейчас, пустой From - To минус STATS_DEFAULT_RANGE)
+// и считает статистику по агрегатам, если они включены. Без группировки по валюте выручка переводится
+// в валюту отчетности, валюты без курса остаются отдельными строками
+func (s *StatsService) OrderStats(ctx context.Context, query domain.OrderStatsQuery) (*OrderStatsReport, error) {
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-s.cfg.StatsDefaultRange)
+	}
+
+	step := query.Bucket.Duration()
+	to := query.Bucket.Truncate(query.To)
+	if to.Before(query.To) {
+		to = to.Add(step)
+	}
+	query.From, query.To = query.Bucket.Truncate(query.From), to
+
+	if !query.From.Before(query.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrStatsRange)
+	}
+	if buckets := int(query.To.Sub(query.From) / step); buckets > s.cfg.StatsMaxBuckets {
+		return nil, fmt.Errorf("%w: %d buckets requested, max %d", ErrStatsRange, buckets, s.cfg.StatsMaxBuckets)
+	}
+
+	var (
+		rows []domain.OrderStatsRow
+		err  error
+	)
+	if s.cfg.StatsRollupEnabled {
+		rows, err = s.repo.OrderStatsRollup(ctx, query)
+	} else {
+		rows, err = s.repo.OrderStats(ctx, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.converter != nil && !query.Has(domain.StatsByCurrency) {
+		rows = s.mergeCurrencies(rows)
+	}
+
+	return &OrderStatsReport{
+		Query:  query,
+		Rollup: s.cfg.StatsRollupEnabled,
+		Rows:   rows,
+	}, nil
+}
+
+// mergeCurrencies складывает строки, различающиеся только валютой, в валюте отчетности.
+// Заказ оплачивается в одной валюте, поэтому число заказов не задваивается
+func (s *StatsService) mergeCurrencies(rows []domain.OrderStatsRow) []domain.OrderStatsRow {
+	merged := make([]domain.OrderStatsRow, 0, len(rows))
+	index := make(map[domain.OrderStatsRow]int, len(rows))
+
+	for _, row := range rows {
+		if revenue, err := s.converter.Convert(row.Revenue); err == nil {
+			row.Revenue, row.Currency = revenue, revenue.Currency
+		}
+
+		key := domain.OrderStatsRow{
+			Bucket:          row.Bucket,
+			DeliveryService: row.DeliveryService,
+			Region:          row.Region,
+			Brand:           row.Brand,
+			Currency:        row.Currency,
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, row)
+			continue
+		}
+
+		merged[i].Orders += row.Orders
+		merged[i].Items += row.Items
+		merged[i].Revenue.Amount += row.Revenue.Amount
+	}
+
+	slices.SortFunc(merged, func(a, b domain.OrderStatsRow) int {
+		return cmp.Or(
+			a.Bucket.Compare(b.Bucket),
+			cmp.Compare(a.DeliveryService, b.DeliveryService),
+			cmp.Compare(a.Region, b.Region),
+			cmp.Compare(a.Brand, b.Brand),
+			cmp.Compare(a.Currency, b.Currency),
+		)
+	})
+
+	return merged
+}
+
+// RunRollup пересчитывает агрегаты сразу и затем раз в STATS_ROLLUP_INTERVAL, пока не отменен ctx
+func (s *StatsService) RunRollup(ctx context.Context) {
+	s.logger.Info("stats rollup started",
+		slog.Duration("interval", s.cfg.StatsRollupInterval),
+	)
+
+	ticker := time.NewTicker(s.cfg.StatsRollupInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshRollup(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("stats rollup stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshRollup пересчитывает часы пачками, пока очередь не опустеет
+func (s *StatsService) refreshRollup(ctx context.Context) {
+	total := 0
+	for {
+		refreshed, err := s.repo.RefreshRollup(ctx, s.cfg.StatsRollupBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("failed to refresh stats rollup",
+					slog.Int("refreshed", total),
+					slog.String("error", err.Error()),
+				)
+			}
+			return
+		}
+
+		total += refreshed
+		if refreshed == 0 || refreshed < s.cfg.StatsRollupBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.logger.Info("stats rollup refreshed",
+			slog.Int("buckets", total),
+		)
+	}
+}
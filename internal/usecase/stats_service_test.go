@@ -0,0 +1,120 @@
+package usecase_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/folivorra/get_order/internal/config"
+	"github.com/folivorra/get_order/internal/domain"
+	"github.com/folivorra/get_order/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStatsRepo struct {
+	mock.Mock
+}
+
+func (m *MockStatsRepo) OrderStats(ctx context.Context, query domain.OrderStatsQuery) ([]domain.OrderStatsRow, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]domain.OrderStatsRow), args.Error(1)
+}
+
+func (m *MockStatsRepo) OrderStatsRollup(ctx context.Context, query domain.OrderStatsQuery) ([]domain.OrderStatsRow, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]domain.OrderStatsRow), args.Error(1)
+}
+
+func (m *MockStatsRepo) RefreshRollup(ctx context.Context, limit int) (int, error) {
+	args := m.Called(ctx, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func statsConfig() config.Config {
+	return config.Config{StatsDefaultRange: 30 * 24 * time.Hour, StatsMaxBuckets: 100}
+}
+
+func TestStatsBucket_Truncate(t *testing.T) {
+	at := time.Date(2026, 10, 22, 15, 42, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	assert.Equal(t, time.Date(2026, 10, 22, 12, 0, 0, 0, time.UTC), domain.StatsHour.Truncate(at))
+	assert.Equal(t, time.Date(2026, 10, 22, 0, 0, 0, 0, time.UTC), domain.StatsDay.Truncate(at))
+	// неделя с понедельника, как date_trunc('week') в Postgres
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), domain.StatsWeek.Truncate(at))
+}
+
+func TestOrderStats_AlignsRangeAndPicksSource(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := new(MockStatsRepo)
+
+	aligned := domain.OrderStatsQuery{
+		Bucket: domain.StatsDay,
+		From:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC),
+	}
+	repo.On("OrderStats", context.Background(), aligned).Return([]domain.OrderStatsRow(nil), nil).Once()
+	repo.On("OrderStatsRollup", context.Background(), aligned).Return([]domain.OrderStatsRow(nil), nil).Once()
+
+	query := domain.OrderStatsQuery{
+		Bucket: domain.StatsDay,
+		From:   time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+		To:     time.Date(2026, 10, 2, 10, 0, 0, 0, time.UTC),
+	}
+
+	report, err := usecase.NewStatsService(logger, statsConfig(), repo, nil).OrderStats(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, aligned, report.Query)
+	assert.False(t, report.Rollup)
+
+	cfg := statsConfig()
+	cfg.StatsRollupEnabled = true
+	report, err = usecase.NewStatsService(logger, cfg, repo, nil).OrderStats(context.Background(), query)
+	require.NoError(t, err)
+	assert.True(t, report.Rollup)
+
+	repo.AssertExpectations(t)
+}
+
+func TestOrderStats_InvalidRange(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := usecase.NewStatsService(logger, statsConfig(), new(MockStatsRepo), nil)
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.OrderStats(context.Background(), domain.OrderStatsQuery{Bucket: domain.StatsDay, From: from, To: from})
+	assert.ErrorIs(t, err, usecase.ErrStatsRange)
+
+	_, err = service.OrderStats(context.Background(), domain.OrderStatsQuery{Bucket: domain.StatsHour, From: from, To: from.Add(101 * time.Hour)})
+	assert.ErrorIs(t, err, usecase.ErrStatsRange)
+}
+
+func TestOrderStats_MergesCurrencies(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := new(MockStatsRepo)
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	repo.On("OrderStats", context.Background(), mock.Anything).Return([]domain.OrderStatsRow{
+		{Bucket: day, DeliveryService: "meest", Currency: "GBP", Orders: 1, Items: 1, Revenue: domain.NewMoney(500, "GBP")},
+		{Bucket: day, DeliveryService: "meest", Currency: "RUB", Orders: 2, Items: 3, Revenue: domain.NewMoney(100000, "RUB")},
+		{Bucket: day, DeliveryService: "meest", Currency: "USD", Orders: 1, Items: 2, Revenue: domain.NewMoney(1000, "USD")},
+		{Bucket: day, DeliveryService: "cdek", Currency: "USD", Orders: 1, Items: 1, Revenue: domain.NewMoney(100, "USD")},
+	}, nil)
+
+	report, err := usecase.NewStatsService(logger, statsConfig(), repo, testConverter(t)).OrderStats(context.Background(), domain.OrderStatsQuery{
+		Bucket:  domain.StatsDay,
+		GroupBy: []domain.StatsDimension{domain.StatsByDeliveryService},
+		From:    day,
+		To:      day.Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	// 10.00 USD = 800 RUB, у GBP нет курса - строка остается в своей валюте
+	assert.Equal(t, []domain.OrderStatsRow{
+		{Bucket: day, DeliveryService: "cdek", Currency: "RUB", Orders: 1, Items: 1, Revenue: domain.NewMoney(8000, "RUB")},
+		{Bucket: day, DeliveryService: "meest", Currency: "GBP", Orders: 1, Items: 1, Revenue: domain.NewMoney(500, "GBP")},
+		{Bucket: day, DeliveryService: "meest", Currency: "RUB", Orders: 3, Items: 5, Revenue: domain.NewMoney(180000, "RUB")},
+	}, report.Rows)
+}
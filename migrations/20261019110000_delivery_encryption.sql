@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- key_id IS NULL - строка еще не зашифрована (записана до включения шифрования)
+ALTER TABLE deliveries
+    ADD COLUMN key_id TEXT,
+    ADD COLUMN dek BYTEA,
+    ADD COLUMN phone_bidx TEXT,
+    ADD COLUMN email_bidx TEXT;
+
+CREATE INDEX deliveries_phone_bidx_idx ON deliveries (phone_bidx);
+CREATE INDEX deliveries_email_bidx_idx ON deliveries (email_bidx);
+CREATE INDEX deliveries_key_id_idx ON deliveries (key_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE deliveries
+    DROP COLUMN key_id,
+    DROP COLUMN dek,
+    DROP COLUMN phone_bidx,
+    DROP COLUMN email_bidx;
+
+-- +goose StatementEnd
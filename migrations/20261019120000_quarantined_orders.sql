@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- payload - сообщение в исходном виде, report - отчет валидации (JSON), topic/partition/offset - откуда пришло.
+-- order_uid может быть NULL, если сообщение не удалось разобрать
+CREATE TABLE quarantined_orders (
+    id UUID PRIMARY KEY,
+    order_uid UUID,
+    source TEXT NOT NULL,
+    topic TEXT NOT NULL DEFAULT '',
+    partition INT NOT NULL DEFAULT 0,
+    "offset" BIGINT NOT NULL DEFAULT 0,
+    payload BYTEA NOT NULL,
+    reason TEXT NOT NULL,
+    report JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    reviewed_by TEXT NOT NULL DEFAULT '',
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX quarantined_orders_status_created_idx ON quarantined_orders (status, created_at, id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE "quarantined_orders";
+
+-- +goose StatementEnd
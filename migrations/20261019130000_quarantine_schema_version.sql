@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- версия формата из заголовка сообщения, 0 - не указана
+ALTER TABLE quarantined_orders ADD COLUMN schema_version INT NOT NULL DEFAULT 0;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE quarantined_orders DROP COLUMN schema_version;
+
+-- +goose StatementEnd
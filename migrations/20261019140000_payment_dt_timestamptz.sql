@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- payment_dt хранился секундами unix
+ALTER TABLE payments ALTER COLUMN payment_dt TYPE TIMESTAMP WITH TIME ZONE USING to_timestamp(payment_dt);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE payments ALTER COLUMN payment_dt TYPE INTEGER USING extract(epoch FROM payment_dt)::INTEGER;
+
+-- +goose StatementEnd
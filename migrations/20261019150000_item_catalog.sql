@@ -0,0 +1,89 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- items остается снимком позиции на момент первого заказа, каталог ведется по артикулу nm_id и размеру chrt_id.
+-- valid_from версии - дата заказа, который принес атрибуты
+CREATE TABLE catalog_item_versions (
+    nm_id INTEGER NOT NULL,
+    chrt_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    size TEXT NOT NULL,
+    item_uid UUID NOT NULL,
+    order_uid UUID NOT NULL,
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (nm_id, chrt_id, version)
+);
+
+-- расхождение атрибутов заказа с текущей версией, applied = false - заказ старше версии и не применен
+CREATE TABLE catalog_item_conflicts (
+    id BIGSERIAL PRIMARY KEY,
+    nm_id INTEGER NOT NULL,
+    chrt_id INTEGER NOT NULL,
+    item_uid UUID NOT NULL,
+    order_uid UUID NOT NULL,
+    current_name TEXT NOT NULL,
+    current_brand TEXT NOT NULL,
+    current_size TEXT NOT NULL,
+    incoming_name TEXT NOT NULL,
+    incoming_brand TEXT NOT NULL,
+    incoming_size TEXT NOT NULL,
+    applied BOOLEAN NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX catalog_item_conflicts_item_idx ON catalog_item_conflicts (nm_id, chrt_id, id);
+
+-- версии из уже сохраненных заказов: каждый набор атрибутов по дате первого заказа с ним
+INSERT INTO catalog_item_versions (nm_id, chrt_id, version, name, brand, size, item_uid, order_uid, valid_from)
+SELECT nm_id, chrt_id,
+       ROW_NUMBER() OVER (PARTITION BY nm_id, chrt_id ORDER BY date_created, order_uid),
+       name, brand, size, item_uid, order_uid, date_created
+FROM (
+    SELECT DISTINCT ON (i.nm_id, i.chrt_id, i.name, i.brand, i.size)
+           i.nm_id, i.chrt_id, i.name, i.brand, i.size, i.item_uid, o.order_uid, o.date_created
+    FROM items i
+    JOIN order_item oi ON oi.item_uid = i.item_uid
+    JOIN orders o ON o.order_uid = oi.order_uid
+    ORDER BY i.nm_id, i.chrt_id, i.name, i.brand, i.size, o.date_created, o.order_uid
+) observed;
+
+CREATE TABLE catalog_items (
+    nm_id INTEGER NOT NULL,
+    chrt_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    size TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (nm_id, chrt_id)
+);
+
+INSERT INTO catalog_items (nm_id, chrt_id, name, brand, size, version, valid_from)
+SELECT DISTINCT ON (nm_id, chrt_id) nm_id, chrt_id, name, brand, size, version, valid_from
+FROM catalog_item_versions
+ORDER BY nm_id, chrt_id, version DESC;
+
+ALTER TABLE catalog_item_versions
+    ADD FOREIGN KEY (nm_id, chrt_id) REFERENCES catalog_items (nm_id, chrt_id) ON DELETE CASCADE;
+
+-- заказы по артикулу
+CREATE INDEX items_nm_id_idx ON items (nm_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX "items_nm_id_idx";
+
+DROP TABLE "catalog_item_conflicts";
+
+DROP TABLE "catalog_item_versions";
+
+DROP TABLE "catalog_items";
+
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE INDEX orders_date_created_idx ON orders (date_created);
+
+-- почасовые агрегаты для GET /stats/orders, bucket - начало часа в UTC. Заказы по брендам считаются
+-- отдельно: заказ с товарами нескольких брендов попадает в каждый из них
+CREATE TABLE order_stats_hourly (
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivery_service TEXT NOT NULL,
+    region TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    orders BIGINT NOT NULL,
+    items BIGINT NOT NULL,
+    revenue BIGINT NOT NULL,
+    PRIMARY KEY (bucket, delivery_service, region, currency)
+);
+
+CREATE TABLE order_brand_stats_hourly (
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivery_service TEXT NOT NULL,
+    region TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    orders BIGINT NOT NULL,
+    items BIGINT NOT NULL,
+    revenue BIGINT NOT NULL,
+    PRIMARY KEY (bucket, delivery_service, region, brand, currency)
+);
+
+-- часы с новыми заказами, их агрегаты пересчитывает фоновая задача
+CREATE TABLE order_stats_dirty (
+    bucket TIMESTAMP WITH TIME ZONE PRIMARY KEY
+);
+
+INSERT INTO order_stats_dirty (bucket)
+SELECT DISTINCT date_trunc('hour', date_created, 'UTC')
+FROM orders;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE "order_stats_dirty";
+
+DROP TABLE "order_brand_stats_hourly";
+
+DROP TABLE "order_stats_hourly";
+
+DROP INDEX "orders_date_created_idx";
+
+-- +goose StatementEnd
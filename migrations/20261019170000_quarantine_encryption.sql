@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- payload с персональными данными шифруется так же, как доставка: key_id IS NULL - запись в открытом виде
+ALTER TABLE quarantined_orders
+    ADD COLUMN key_id TEXT,
+    ADD COLUMN dek BYTEA;
+
+CREATE INDEX quarantined_orders_key_id_idx ON quarantined_orders (key_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE quarantined_orders
+    DROP COLUMN key_id,
+    DROP COLUMN dek;
+
+-- +goose StatementEnd
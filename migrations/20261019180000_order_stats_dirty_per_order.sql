@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- отметка на каждый заказ: сохранения в одном часе не ждут друг друга, а отметка незакоммиченного
+-- заказа не видна пересчету и остается до следующего прохода. Нулевой order_uid - отметка всего часа
+ALTER TABLE order_stats_dirty
+    ADD COLUMN order_uid UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000';
+
+ALTER TABLE order_stats_dirty
+    ALTER COLUMN order_uid DROP DEFAULT,
+    DROP CONSTRAINT order_stats_dirty_pkey,
+    ADD PRIMARY KEY (bucket, order_uid);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DELETE FROM order_stats_dirty d
+USING order_stats_dirty o
+WHERE d.bucket = o.bucket AND d.order_uid > o.order_uid;
+
+ALTER TABLE order_stats_dirty
+    DROP CONSTRAINT order_stats_dirty_pkey,
+    DROP COLUMN order_uid,
+    ADD PRIMARY KEY (bucket);
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- новые заказы сохраняются с кодом валюты в верхнем регистре, старые приводятся к тому же виду
+UPDATE payments
+SET currency = upper(trim(currency))
+WHERE currency <> upper(trim(currency));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- исходный регистр не сохранялся, откатывать нечего
+
+-- +goose StatementEnd